@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"time"
 
@@ -26,10 +27,17 @@ import (
 
 func main() {
 	silent := flag.Bool("silent", false, "Suppress server logs in terminal")
+	portRange := flag.String("port-range", "25565-26564", "Ports handed out to instances")
 	flag.Parse()
 
 	logger.Setup()
 
+	ports, err := instances.ParsePortRange(*portRange)
+	if err != nil {
+		logger.Error("Invalid -port-range", "error", err)
+		os.Exit(2)
+	}
+
 	database.ConnectDB()
 
 	authManager := auth.NewAuthManager(database.DB)
@@ -44,6 +52,7 @@ func main() {
 		templateManager,
 		*silent,
 	)
+	instanceManager.SetPortRange(ports)
 
 	taskExecutor := func(instanceID string, taskType string, payload string) error {
 		inst, err := instanceManager.GetInstance(instanceID)
@@ -59,12 +68,15 @@ func main() {
 		case "start":
 			return inst.Manager.Start()
 		case "stop":
-			return inst.Manager.Stop()
+			_, err := inst.Manager.Stop()
+			return err
 		case "backup":
-			// Assuming backup logic is accessible or via BackupManager
-			// For now, let's just log it or call a backup function if we have one exposed
-			logger.Warn("Backup schedule type not fully implemented in executor yet")
-			return nil
+			opts, err := instances.ParseBackupOptions(payload)
+			if err != nil {
+				return err
+			}
+			_, err = instanceManager.RunBackup(instanceID, opts, instances.BackupSchedule)
+			return err
 		default:
 			return fmt.Errorf("unknown task type: %s", taskType)
 		}
@@ -78,6 +90,10 @@ func main() {
 
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: true,
+		// Bodies over the default limit are streamed so that bundle imports
+		// can be larger; web.RegisterRoutes holds every other route to it.
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	web.RegisterRoutes(app, authManager, instanceManager, schedulerService, javaManager)
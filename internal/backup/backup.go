@@ -4,32 +4,109 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"jjmc/pkg/archiver"
 )
 
+// Kinds of backups.
+const (
+	KindZip      = "zip"
+	KindSnapshot = "snapshot"
+)
+
 type Backup struct {
-	Name      string    `json:"name"`
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"`
+	Kind  string `json:"kind"`
+	// Size is the disk space the backup takes. For snapshots that is only
+	// the data they added, so TotalSize gives the size of the files.
 	Size      int64     `json:"size"`
+	TotalSize int64     `json:"totalSize,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+const timestampLayout = "2006-01-02_15-04-05"
+
+// nameRe matches the end of a backup file name: the time it was taken and
+// the optional label.
+var nameRe = regexp.MustCompile(`_(\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2})(?:_([A-Za-z0-9_-]+))?\.(?:zip|snap)$`)
+
+// parseName reads the time and label from a backup file name. Files that
+// were renamed by hand fall back to their modification time.
+func parseName(name string, modTime time.Time) (time.Time, string) {
+	m := nameRe.FindStringSubmatch(name)
+	if m == nil {
+		return modTime, ""
+	}
+	t, err := time.ParseInLocation(timestampLayout, m[1], time.Local)
+	if err != nil {
+		return modTime, m[2]
+	}
+	return t, m[2]
+}
+
+// backupName names a new backup of the instance.
+func backupName(instanceName, label, ext string) string {
+	timestamp := time.Now().Format(timestampLayout)
+	if label := CleanLabel(label); label != "" {
+		return fmt.Sprintf("%s_%s_%s%s", instanceName, timestamp, label, ext)
+	}
+	return fmt.Sprintf("%s_%s%s", instanceName, timestamp, ext)
+}
+
 func GetBackupDir(instanceDir string) string {
 	return filepath.Join("data", "backups", filepath.Base(instanceDir))
 }
 
+// Options narrow down a backup.
+type Options struct {
+	// Label is appended to the file name so that backups can be told apart.
+	Label string
+	// Include, when set, decides which paths relative to the instance
+	// directory go into the backup.
+	Include func(rel string, info os.FileInfo) bool
+}
+
+var labelRe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// CleanLabel turns a label into something safe to put in a file name.
+func CleanLabel(label string) string {
+	label = strings.Trim(labelRe.ReplaceAllString(label, "-"), "-")
+	if len(label) > 32 {
+		label = label[:32]
+	}
+	return label
+}
+
 func Create(instanceDir string, backupDir string, instanceName string) error {
+	_, err := CreateWithOptions(instanceDir, backupDir, instanceName, Options{})
+	return err
+}
+
+// CreateWithOptions zips the instance into backupDir and returns the backup.
+// A partly written archive is removed when it fails.
+func CreateWithOptions(instanceDir, backupDir, instanceName string, opts Options) (Backup, error) {
 	if err := os.MkdirAll(backupDir, os.ModePerm); err != nil {
-		return err
+		return Backup{}, err
 	}
 
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := fmt.Sprintf("%s_%s.zip", instanceName, timestamp)
+	filename := backupName(instanceName, opts.Label, ".zip")
 	target := filepath.Join(backupDir, filename)
 
-	return archiver.ZipDirectory(instanceDir, target)
+	if err := archiver.ZipDirectoryFiltered(instanceDir, target, opts.Include); err != nil {
+		os.Remove(target)
+		return Backup{}, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return Backup{}, err
+	}
+	createdAt, label := parseName(filename, info.ModTime())
+	return Backup{Name: filename, Label: label, Kind: KindZip, Size: info.Size(), CreatedAt: createdAt}, nil
 }
 
 func List(backupDir string) ([]Backup, error) {
@@ -43,17 +120,35 @@ func List(backupDir string) ([]Backup, error) {
 
 	backups := []Backup{}
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".zip" {
-			info, err := entry.Info()
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if ext != ".zip" && ext != SnapshotExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		createdAt, label := parseName(entry.Name(), info.ModTime())
+		b := Backup{
+			Name:      entry.Name(),
+			Label:     label,
+			Kind:      KindZip,
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		}
+		if ext == SnapshotExt {
+			s, err := ReadSnapshot(filepath.Join(backupDir, entry.Name()))
 			if err != nil {
 				continue
 			}
-			backups = append(backups, Backup{
-				Name:      entry.Name(),
-				Size:      info.Size(),
-				CreatedAt: info.ModTime(),
-			})
+			b.Kind = KindSnapshot
+			b.Size = info.Size() + s.AddedSize
+			b.TotalSize = s.TotalSize
 		}
+		backups = append(backups, b)
 	}
 
 	sort.Slice(backups, func(i, j int) bool {
@@ -67,10 +162,32 @@ func Restore(backupPath string, restoreDir string) error {
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
 		return fmt.Errorf("backup not found")
 	}
+	if IsSnapshot(backupPath) {
+		s, err := ReadSnapshot(backupPath)
+		if err != nil {
+			return err
+		}
+		return RestoreSnapshot(backupPath, filepath.Join(restoreDir, s.Root), nil)
+	}
 
 	return archiver.Unzip(backupPath, restoreDir)
 }
 
+// RestoreInto restores a backup into instanceDir itself rather than into
+// the folder the instance had when the backup was taken, which differs once
+// its ID has changed.
+func RestoreInto(backupPath string, instanceDir string) error {
+	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+		return fmt.Errorf("backup not found")
+	}
+	if IsSnapshot(backupPath) {
+		return RestoreSnapshot(backupPath, instanceDir, nil)
+	}
+	return archiver.UnzipStripped(backupPath, instanceDir)
+}
+
+// Delete removes a backup. The chunks only a deleted snapshot used stay in
+// the store until GC.
 func Delete(backupPath string) error {
 	return os.Remove(backupPath)
 }
@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultExcludes are left out of backups of instances that haven't set
+// their own patterns: what the server downloads again by itself, and logs.
+var DefaultExcludes = []string{
+	"/libraries/",
+	"/logs/",
+	"/*.jar",
+	"/*.mrpack",
+	"cache/",
+}
+
+// Excludes matches paths against gitignore-style patterns:
+//
+//   - A pattern with a slash at the start or in the middle is relative to
+//     the instance directory; otherwise it matches at any depth.
+//   - A trailing slash only matches directories.
+//   - * and ? don't match slashes, ** matches any number of directories.
+//   - ! re-includes what an earlier pattern excluded.
+//   - Blank lines and lines starting with # are ignored.
+//
+// The last matching pattern wins. As with gitignore, nothing inside an
+// excluded directory can be re-included, since the directory is skipped.
+type Excludes struct {
+	rules []excludeRule
+}
+
+type excludeRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ParseExcludes checks and compiles exclude patterns.
+func ParseExcludes(patterns []string) (*Excludes, error) {
+	e := &Excludes{}
+	for _, pattern := range patterns {
+		p := strings.TrimSpace(pattern)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		rule := excludeRule{}
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		anchored := strings.Contains(p, "/")
+		p = strings.TrimLeft(p, "/")
+		if p == "" {
+			return nil, fmt.Errorf("invalid exclude pattern %q", pattern)
+		}
+
+		rule.segments = strings.Split(p, "/")
+		if !anchored {
+			rule.segments = append([]string{"**"}, rule.segments...)
+		}
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid exclude pattern %q", pattern)
+			}
+		}
+		e.rules = append(e.rules, rule)
+	}
+	return e, nil
+}
+
+// Excluded reports whether the slash-separated path rel, relative to the
+// instance directory, is excluded.
+func (e *Excludes) Excluded(rel string, isDir bool) bool {
+	segments := strings.Split(rel, "/")
+	excluded := false
+	for _, rule := range e.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
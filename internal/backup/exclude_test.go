@@ -0,0 +1,51 @@
+package backup
+
+import "testing"
+
+func TestExcludes(t *testing.T) {
+	patterns := append(append([]string{}, DefaultExcludes...),
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"world/**/*.tmp",
+	)
+	e, err := ParseExcludes(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"libraries", true, true},
+		{"libraries", false, false},
+		{"plugins/libraries", true, false},
+		{"logs", true, true},
+		{"paper.jar", false, true},
+		{"plugins/Essentials.jar", false, false},
+		{"modpack.mrpack", false, true},
+		{"cache", true, true},
+		{"plugins/dynmap/cache", true, true},
+		{"debug.log", false, true},
+		{"plugins/x/errors.log", false, true},
+		{"keep.log", false, false},
+		{"world/region/r.0.0.tmp", false, true},
+		{"world/a.tmp", false, true},
+		{"other/a.tmp", false, false},
+		{"world/level.dat", false, false},
+	}
+	for _, tt := range tests {
+		if got := e.Excluded(tt.path, tt.isDir); got != tt.excluded {
+			t.Errorf("Excluded(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.excluded)
+		}
+	}
+
+	if _, err := ParseExcludes([]string{"[abc"}); err == nil {
+		t.Error("expected a malformed pattern to be rejected")
+	}
+	if _, err := ParseExcludes([]string{"/"}); err == nil {
+		t.Error("expected an empty pattern to be rejected")
+	}
+}
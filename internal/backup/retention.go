@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Retention decides which backups to keep. A backup is kept if any rule
+// keeps it: it is among the KeepLast newest, or the newest of one of the
+// Daily most recent days with a backup, and likewise for weeks and months.
+// Rules apply to each label separately, so that labelled backups such as
+// "pre-update" don't push out the regular ones. With every rule at 0 all
+// backups are kept. MaxSizeMB then drops the oldest kept backups until the
+// total fits, though never the newest one.
+type Retention struct {
+	KeepLast  int `json:"keepLast"`
+	Daily     int `json:"daily"`
+	Weekly    int `json:"weekly"`
+	Monthly   int `json:"monthly"`
+	MaxSizeMB int `json:"maxSizeMb"`
+}
+
+func (r Retention) Validate() error {
+	if r.KeepLast < 0 || r.Daily < 0 || r.Weekly < 0 || r.Monthly < 0 || r.MaxSizeMB < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+	return nil
+}
+
+func (r Retention) hasRules() bool {
+	return r.KeepLast > 0 || r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+// Plan splits backups into those the policy keeps and those it prunes, both
+// newest first.
+func Plan(backups []Backup, r Retention) (keep, prune []Backup) {
+	sorted := append([]Backup(nil), backups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	kept := make(map[int]bool, len(sorted))
+	if !r.hasRules() {
+		for i := range sorted {
+			kept[i] = true
+		}
+	} else {
+		byLabel := map[string][]int{}
+		for i, b := range sorted {
+			byLabel[b.Label] = append(byLabel[b.Label], i)
+		}
+		for _, indexes := range byLabel {
+			for n, i := range indexes {
+				if n < r.KeepLast {
+					kept[i] = true
+				}
+			}
+			keepPeriods(sorted, indexes, r.Daily, kept, func(t time.Time) string {
+				return t.Format("2006-01-02")
+			})
+			keepPeriods(sorted, indexes, r.Weekly, kept, func(t time.Time) string {
+				year, week := t.ISOWeek()
+				return fmt.Sprintf("%d-W%02d", year, week)
+			})
+			keepPeriods(sorted, indexes, r.Monthly, kept, func(t time.Time) string {
+				return t.Format("2006-01")
+			})
+		}
+	}
+
+	if r.MaxSizeMB > 0 {
+		limit := int64(r.MaxSizeMB) * 1024 * 1024
+		var total int64
+		first := true
+		for i, b := range sorted {
+			if !kept[i] {
+				continue
+			}
+			total += b.Size
+			if total > limit && !first {
+				kept[i] = false
+			}
+			first = false
+		}
+	}
+
+	keep, prune = []Backup{}, []Backup{}
+	for i, b := range sorted {
+		if kept[i] {
+			keep = append(keep, b)
+		} else {
+			prune = append(prune, b)
+		}
+	}
+	return keep, prune
+}
+
+// keepPeriods keeps the newest backup of each of the n most recent periods
+// that have one. indexes are newest first.
+func keepPeriods(sorted []Backup, indexes []int, n int, kept map[int]bool, period func(time.Time) string) {
+	if n <= 0 {
+		return
+	}
+	seen := map[string]bool{}
+	for _, i := range indexes {
+		p := period(sorted[i].CreatedAt)
+		if seen[p] {
+			continue
+		}
+		if len(seen) == n {
+			return
+		}
+		seen[p] = true
+		kept[i] = true
+	}
+}
+
+// Prune applies the policy to the backups in backupDir and returns the
+// backups it removed, or with dryRun the ones it would remove.
+func Prune(backupDir string, r Retention, dryRun bool) ([]Backup, error) {
+	backups, err := List(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	_, prune := Plan(backups, r)
+	if dryRun {
+		return prune, nil
+	}
+
+	removed := []Backup{}
+	for _, b := range prune {
+		if err := Delete(filepath.Join(backupDir, b.Name)); err != nil {
+			return removed, fmt.Errorf("failed to delete %s: %v", b.Name, err)
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
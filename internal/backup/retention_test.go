@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// daily returns one backup a day at noon for n days before 2024-03-31,
+// newest first.
+func daily(n int, label string) []Backup {
+	end := time.Date(2024, 3, 31, 12, 0, 0, 0, time.Local)
+	backups := make([]Backup, n)
+	for i := range backups {
+		at := end.AddDate(0, 0, -i)
+		backups[i] = Backup{Name: at.Format(timestampLayout) + label, Label: label, Size: 10 * 1024 * 1024, CreatedAt: at}
+	}
+	return backups
+}
+
+func names(backups []Backup) map[string]bool {
+	m := map[string]bool{}
+	for _, b := range backups {
+		m[b.Name] = true
+	}
+	return m
+}
+
+func TestPlanKeepLast(t *testing.T) {
+	backups := daily(10, "")
+	keep, prune := Plan(backups, Retention{KeepLast: 3})
+	if len(keep) != 3 || len(prune) != 7 {
+		t.Fatalf("kept %d, pruned %d", len(keep), len(prune))
+	}
+	if keep[0].Name != backups[0].Name || keep[2].Name != backups[2].Name {
+		t.Errorf("expected the newest to be kept, got %v", keep)
+	}
+}
+
+func TestPlanNoRulesKeepsAll(t *testing.T) {
+	keep, prune := Plan(daily(5, ""), Retention{})
+	if len(keep) != 5 || len(prune) != 0 {
+		t.Errorf("kept %d, pruned %d", len(keep), len(prune))
+	}
+}
+
+func TestPlanGFS(t *testing.T) {
+	// Two backups a day for 90 days.
+	var backups []Backup
+	for _, b := range daily(90, "") {
+		early := b
+		early.CreatedAt = b.CreatedAt.Add(-6 * time.Hour)
+		early.Name = early.CreatedAt.Format(timestampLayout)
+		backups = append(backups, b, early)
+	}
+
+	keep, _ := Plan(backups, Retention{Daily: 7, Weekly: 4, Monthly: 3})
+	kept := names(keep)
+
+	for i := 0; i < 7; i++ {
+		day := time.Date(2024, 3, 31-i, 12, 0, 0, 0, time.Local)
+		if !kept[day.Format(timestampLayout)] {
+			t.Errorf("daily backup of %s not kept", day.Format("01-02"))
+		}
+		if kept[day.Add(-6*time.Hour).Format(timestampLayout)] {
+			t.Errorf("second backup of %s kept", day.Format("01-02"))
+		}
+	}
+	// The last day of February and of January are the newest of their months.
+	for _, day := range []time.Time{
+		time.Date(2024, 2, 29, 12, 0, 0, 0, time.Local),
+		time.Date(2024, 1, 31, 12, 0, 0, 0, time.Local),
+	} {
+		if !kept[day.Format(timestampLayout)] {
+			t.Errorf("monthly backup of %s not kept", day.Format("01-02"))
+		}
+	}
+	// 7 days, plus up to 4 week and 3 month ends that aren't among them.
+	if len(keep) > 14 || len(keep) < 10 {
+		t.Errorf("kept %d backups", len(keep))
+	}
+}
+
+func TestPlanLabelsAreSeparate(t *testing.T) {
+	backups := append(daily(5, ""), daily(3, "pre-update")...)
+	keep, _ := Plan(backups, Retention{KeepLast: 2})
+	labels := map[string]int{}
+	for _, b := range keep {
+		labels[b.Label]++
+	}
+	if labels[""] != 2 || labels["pre-update"] != 2 {
+		t.Errorf("unexpected kept labels %v", labels)
+	}
+}
+
+func TestPlanMaxSize(t *testing.T) {
+	backups := daily(10, "")
+	keep, prune := Plan(backups, Retention{MaxSizeMB: 35})
+	if len(keep) != 3 || len(prune) != 7 {
+		t.Errorf("kept %d, pruned %d", len(keep), len(prune))
+	}
+
+	// The newest backup is kept even when it alone is too big.
+	keep, _ = Plan(backups, Retention{MaxSizeMB: 1})
+	if len(keep) != 1 || keep[0].Name != backups[0].Name {
+		t.Errorf("expected only the newest to be kept, got %v", keep)
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, b := range daily(4, "") {
+		os.WriteFile(filepath.Join(dir, "SMP_"+b.Name+".zip"), []byte("x"), 0644)
+	}
+
+	would, err := Prune(dir, Retention{KeepLast: 1}, true)
+	if err != nil || len(would) != 3 {
+		t.Fatalf("dry run: %v, %v", would, err)
+	}
+	if backups, _ := List(dir); len(backups) != 4 {
+		t.Errorf("dry run deleted backups")
+	}
+
+	removed, err := Prune(dir, Retention{KeepLast: 1}, false)
+	if err != nil || len(removed) != 3 {
+		t.Fatalf("prune: %v, %v", removed, err)
+	}
+	backups, _ := List(dir)
+	if len(backups) != 1 || backups[0].Name != "SMP_2024-03-31_12-00-00.zip" {
+		t.Errorf("unexpected backups left %v", backups)
+	}
+}
@@ -0,0 +1,456 @@
+package backup
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshots are the deduplicating alternative to zip backups. The files of
+// an instance are cut into fixed-size chunks, and each chunk is stored once
+// under its SHA-256 in the blob store next to the backups. A snapshot is a
+// manifest listing the chunks of every file, so a backup only costs the
+// chunks that changed since any earlier one. Fixed-size chunks suit region
+// files well, as the server rewrites them in place sector by sector.
+const (
+	SnapshotExt    = ".snap"
+	blobDir        = ".blobs"
+	chunkSize      = 1 << 20
+	snapshotFormat = 1
+)
+
+// SnapshotFile is a file in a snapshot.
+type SnapshotFile struct {
+	Path    string      `json:"path"` // Slash-separated, relative to the instance
+	Mode    os.FileMode `json:"mode"`
+	ModTime int64       `json:"modTime"` // Nanoseconds
+	Size    int64       `json:"size"`
+	Chunks  []string    `json:"chunks"`
+}
+
+// Snapshot is the manifest of one snapshot backup.
+type Snapshot struct {
+	Format    int            `json:"format"`
+	Root      string         `json:"root"` // Directory name of the instance
+	Label     string         `json:"label,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	TotalSize int64          `json:"totalSize"` // Size of the files
+	AddedSize int64          `json:"addedSize"` // Bytes of new chunks stored for it
+	Dirs      []string       `json:"dirs"`
+	Files     []SnapshotFile `json:"files"`
+}
+
+// SnapshotEntry is a file or folder when browsing a snapshot.
+type SnapshotEntry struct {
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Diff lists the paths that differ between two snapshots.
+type Diff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+func IsSnapshot(name string) bool {
+	return strings.HasSuffix(name, SnapshotExt)
+}
+
+func blobPath(backupDir, hash string) string {
+	return filepath.Join(backupDir, blobDir, hash[:2], hash)
+}
+
+func ReadSnapshot(snapshotPath string) (*Snapshot, error) {
+	f, err := os.Open(snapshotPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("backup not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var s Snapshot
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if s.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d", s.Format)
+	}
+	return &s, nil
+}
+
+// latestSnapshot returns the newest snapshot in backupDir, if any, so that
+// files that haven't changed since don't have to be read again.
+func latestSnapshot(backupDir string) *Snapshot {
+	backups, err := List(backupDir)
+	if err != nil {
+		return nil
+	}
+	for _, b := range backups {
+		if IsSnapshot(b.Name) {
+			if s, err := ReadSnapshot(filepath.Join(backupDir, b.Name)); err == nil {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// storeChunk saves a chunk unless the store already has it, and returns
+// its hash and the bytes written.
+func storeChunk(backupDir string, data []byte) (string, int64, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	target := blobPath(backupDir, hash)
+	if _, err := os.Stat(target); err == nil {
+		return hash, 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), hash+".tmp*")
+	if err != nil {
+		return "", 0, err
+	}
+	zw := gzip.NewWriter(tmp)
+	_, err = zw.Write(data)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	info, _ := os.Stat(tmp.Name())
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	return hash, info.Size(), nil
+}
+
+func blobsExist(backupDir string, hashes []string) bool {
+	for _, hash := range hashes {
+		if _, err := os.Stat(blobPath(backupDir, hash)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateSnapshot stores a snapshot of the instance in backupDir and returns
+// it as a backup, whose size is what the snapshot added to the store.
+func CreateSnapshot(instanceDir, backupDir, instanceName string, opts Options) (Backup, error) {
+	if _, err := os.Stat(instanceDir); err != nil {
+		return Backup{}, err
+	}
+	if err := os.MkdirAll(backupDir, os.ModePerm); err != nil {
+		return Backup{}, err
+	}
+
+	previous := map[string]SnapshotFile{}
+	if last := latestSnapshot(backupDir); last != nil {
+		for _, f := range last.Files {
+			previous[f.Path] = f
+		}
+	}
+
+	filename := backupName(instanceName, opts.Label, SnapshotExt)
+	snapshot := &Snapshot{
+		Format:    snapshotFormat,
+		Root:      filepath.Base(instanceDir),
+		Label:     CleanLabel(opts.Label),
+		CreatedAt: time.Now(),
+		Dirs:      []string{},
+		Files:     []SnapshotFile{},
+	}
+
+	buf := make([]byte, chunkSize)
+	err := filepath.Walk(instanceDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == instanceDir {
+			return nil
+		}
+		rel, err := filepath.Rel(instanceDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if opts.Include != nil && !opts.Include(rel, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			snapshot.Dirs = append(snapshot.Dirs, rel)
+			return nil
+		}
+		// Opening a FIFO such as console.in would block forever.
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file := SnapshotFile{Path: rel, Mode: info.Mode().Perm(), ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+		snapshot.TotalSize += file.Size
+		if prev, ok := previous[rel]; ok && prev.Size == file.Size && prev.ModTime == file.ModTime && blobsExist(backupDir, prev.Chunks) {
+			file.Chunks = prev.Chunks
+			snapshot.Files = append(snapshot.Files, file)
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file.Chunks = []string{}
+		for {
+			n, err := io.ReadFull(f, buf)
+			if n > 0 {
+				hash, added, err := storeChunk(backupDir, buf[:n])
+				if err != nil {
+					return err
+				}
+				file.Chunks = append(file.Chunks, hash)
+				snapshot.AddedSize += added
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		snapshot.Files = append(snapshot.Files, file)
+		return nil
+	})
+	if err != nil {
+		// Chunks already stored are left for GC.
+		return Backup{}, err
+	}
+
+	target := filepath.Join(backupDir, filename)
+	tmp := target + ".tmp"
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return Backup{}, err
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return Backup{}, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return Backup{}, err
+	}
+	createdAt, label := parseName(filename, snapshot.CreatedAt)
+	size := snapshot.AddedSize + int64(len(data))
+	return Backup{Name: filename, Label: label, Kind: KindSnapshot, Size: size, TotalSize: snapshot.TotalSize, CreatedAt: createdAt}, nil
+}
+
+// Browse lists the files and folders directly inside dir of a snapshot.
+func (s *Snapshot) Browse(dir string) []SnapshotEntry {
+	dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	entries := []SnapshotEntry{}
+	for _, d := range s.Dirs {
+		if strings.HasPrefix(d, prefix) && !strings.Contains(d[len(prefix):], "/") {
+			entries = append(entries, SnapshotEntry{Name: d[len(prefix):], IsDir: true})
+		}
+	}
+	for _, f := range s.Files {
+		if strings.HasPrefix(f.Path, prefix) && !strings.Contains(f.Path[len(prefix):], "/") {
+			entries = append(entries, SnapshotEntry{Name: f.Path[len(prefix):], Size: f.Size, ModTime: time.Unix(0, f.ModTime)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Diff lists what changed from old to s.
+func (s *Snapshot) Diff(old *Snapshot) Diff {
+	before := make(map[string]SnapshotFile, len(old.Files))
+	for _, f := range old.Files {
+		before[f.Path] = f
+	}
+
+	d := Diff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	for _, f := range s.Files {
+		prev, ok := before[f.Path]
+		delete(before, f.Path)
+		switch {
+		case !ok:
+			d.Added = append(d.Added, f.Path)
+		case strings.Join(prev.Chunks, ",") != strings.Join(f.Chunks, ","):
+			d.Modified = append(d.Modified, f.Path)
+		}
+	}
+	for p := range before {
+		d.Removed = append(d.Removed, p)
+	}
+	sort.Strings(d.Removed)
+	return d
+}
+
+func selected(p string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, sel := range paths {
+		sel = strings.Trim(path.Clean("/"+filepath.ToSlash(sel)), "/")
+		if sel == "" || p == sel || strings.HasPrefix(p, sel+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreSnapshot writes the files of a snapshot into instanceDir. With
+// paths, only those files and folders are restored.
+func RestoreSnapshot(snapshotPath, instanceDir string, paths []string) error {
+	s, err := ReadSnapshot(snapshotPath)
+	if err != nil {
+		return err
+	}
+	backupDir := filepath.Dir(snapshotPath)
+	root := filepath.Clean(instanceDir)
+	rootPrefix := root + string(os.PathSeparator)
+
+	for _, d := range s.Dirs {
+		if selected(d, paths) {
+			target := filepath.Join(root, filepath.FromSlash(d))
+			if !strings.HasPrefix(target, rootPrefix) {
+				continue
+			}
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range s.Files {
+		if !selected(f.Path, paths) {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(f.Path))
+		if !strings.HasPrefix(target, rootPrefix) {
+			continue
+		}
+		if err := restoreFile(backupDir, f, target); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func restoreFile(backupDir string, f SnapshotFile, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode|0600)
+	if err != nil {
+		return err
+	}
+	for _, hash := range f.Chunks {
+		if err := copyBlob(out, backupDir, hash); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	modTime := time.Unix(0, f.ModTime)
+	return os.Chtimes(target, modTime, modTime)
+}
+
+func copyBlob(w io.Writer, backupDir, hash string) error {
+	blob, err := os.Open(blobPath(backupDir, hash))
+	if err != nil {
+		return fmt.Errorf("missing chunk %s", hash)
+	}
+	defer blob.Close()
+	zr, err := gzip.NewReader(blob)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(w, zr)
+	return err
+}
+
+// GC removes the chunks no snapshot refers to anymore and returns how many
+// it removed and how many bytes that freed. It must not run while a
+// snapshot is being taken in backupDir.
+func GC(backupDir string) (int, int64, error) {
+	blobs := filepath.Join(backupDir, blobDir)
+	if _, err := os.Stat(blobs); os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	// Not List, which leaves out snapshots it can't read.
+	manifests, err := filepath.Glob(filepath.Join(backupDir, "*"+SnapshotExt))
+	if err != nil {
+		return 0, 0, err
+	}
+	referenced := map[string]bool{}
+	for _, manifest := range manifests {
+		s, err := ReadSnapshot(manifest)
+		if err != nil {
+			// Better to keep garbage than to break a snapshot we can't read.
+			return 0, 0, fmt.Errorf("failed to read %s: %v", filepath.Base(manifest), err)
+		}
+		for _, f := range s.Files {
+			for _, hash := range f.Chunks {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	removed, freed := 0, int64(0)
+	err = filepath.Walk(blobs, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if referenced[info.Name()] {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	root := t.TempDir()
+	instanceDir := filepath.Join(root, "survival")
+	backupDir := filepath.Join(root, "backups")
+
+	// Random data, so compression doesn't hide how much is stored.
+	region := make([]byte, 3*chunkSize+100)
+	rand.New(rand.NewSource(1)).Read(region)
+	writeFile(t, filepath.Join(instanceDir, "world", "region", "r.0.0.mca"), region)
+	writeFile(t, filepath.Join(instanceDir, "server.properties"), []byte("motd=hello\n"))
+	if err := os.MkdirAll(filepath.Join(instanceDir, "plugins"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := CreateSnapshot(instanceDir, backupDir, "Survival", Options{Label: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Kind != KindSnapshot || first.Size < int64(len(region)) {
+		t.Fatalf("unexpected first snapshot %+v", first)
+	}
+
+	// Change one chunk of the region file and add a file.
+	region[chunkSize+5] ^= 0xff
+	writeFile(t, filepath.Join(instanceDir, "world", "region", "r.0.0.mca"), region)
+	writeFile(t, filepath.Join(instanceDir, "world", "level.dat"), []byte("level"))
+
+	second, err := CreateSnapshot(instanceDir, backupDir, "Survival", Options{Label: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Size > chunkSize+chunkSize/2 {
+		t.Errorf("second snapshot should only store the changed chunk, took %d bytes", second.Size)
+	}
+	if second.TotalSize != int64(len(region))+int64(len("motd=hello\n"))+int64(len("level")) {
+		t.Errorf("unexpected total size %d", second.TotalSize)
+	}
+
+	backups, err := List(backupDir)
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v (%v)", backups, err)
+	}
+
+	s1, err := ReadSnapshot(filepath.Join(backupDir, first.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := ReadSnapshot(filepath.Join(backupDir, second.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := s2.Diff(s1)
+	if len(diff.Added) != 1 || diff.Added[0] != "world/level.dat" {
+		t.Errorf("unexpected added %v", diff.Added)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0] != "world/region/r.0.0.mca" {
+		t.Errorf("unexpected modified %v", diff.Modified)
+	}
+	if len(diff.Removed) != 0 {
+		t.Errorf("unexpected removed %v", diff.Removed)
+	}
+
+	entries := s2.Browse("world")
+	if len(entries) != 2 || !entries[0].IsDir || entries[0].Name != "region" || entries[1].Name != "level.dat" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	// Restore the first snapshot over the changed files.
+	if err := Restore(filepath.Join(backupDir, first.Name), root); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := os.ReadFile(filepath.Join(instanceDir, "world", "region", "r.0.0.mca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	region[chunkSize+5] ^= 0xff
+	if !bytes.Equal(restored, region) {
+		t.Error("restored region file differs from the first snapshot")
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, "plugins")); err != nil {
+		t.Errorf("empty folder was not restored: %v", err)
+	}
+
+	// Only the second snapshot uses the changed chunk and level.dat.
+	if err := Delete(filepath.Join(backupDir, second.Name)); err != nil {
+		t.Fatal(err)
+	}
+	removed, freed, err := GC(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed == 0 {
+		t.Errorf("expected 2 chunks to be collected, got %d (%d bytes)", removed, freed)
+	}
+	if err := RestoreSnapshot(filepath.Join(backupDir, first.Name), filepath.Join(root, "copy"), []string{"world/region"}); err != nil {
+		t.Fatalf("first snapshot broken after gc: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "copy", "server.properties")); !os.IsNotExist(err) {
+		t.Error("partial restore wrote files outside of the given paths")
+	}
+}
+
+func TestGCKeepsChunksOfUnreadableSnapshots(t *testing.T) {
+	root := t.TempDir()
+	instanceDir := filepath.Join(root, "survival")
+	backupDir := filepath.Join(root, "backups")
+	writeFile(t, filepath.Join(instanceDir, "world", "level.dat"), []byte("level"))
+
+	first, err := CreateSnapshot(instanceDir, backupDir, "Survival", Options{Label: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(instanceDir, "world", "level.dat"), []byte("changed"))
+	if _, err := CreateSnapshot(instanceDir, backupDir, "Survival", Options{Label: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first snapshot is the only one using its chunk, and can't be read.
+	if err := os.WriteFile(filepath.Join(backupDir, first.Name), []byte(`{"format": 2`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chunks, _ := filepath.Glob(filepath.Join(backupDir, blobDir, "*", "*"))
+
+	if _, _, err := GC(backupDir); err == nil {
+		t.Error("expected gc to fail on an unreadable snapshot")
+	}
+	after, _ := filepath.Glob(filepath.Join(backupDir, blobDir, "*", "*"))
+	if len(after) != len(chunks) || len(chunks) != 2 {
+		t.Errorf("gc removed chunks: %d before, %d after", len(chunks), len(after))
+	}
+}
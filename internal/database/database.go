@@ -10,6 +10,21 @@ import (
 
 var DB *gorm.DB
 
+// Models are the tables JJMC keeps in its database.
+var Models = []interface{}{
+	&models.InstanceModel{},
+	&models.Schedule{},
+	&models.Folder{},
+	&models.PlayerSession{},
+	&models.PlayerEvent{},
+	&models.SharedList{},
+	&models.SharedListEntry{},
+	&models.SharedListChange{},
+	&models.SharedListRemoval{},
+	&models.PortAllocation{},
+	&models.BackupRun{},
+}
+
 func ConnectDB() {
 	var err error
 	DB, err = gorm.Open(sqlite.Open("data/jjmc.db"), &gorm.Config{})
@@ -17,5 +32,5 @@ func ConnectDB() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	DB.AutoMigrate(&models.InstanceModel{}, &models.Schedule{}, &models.Folder{})
+	DB.AutoMigrate(Models...)
 }
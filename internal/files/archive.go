@@ -42,6 +42,10 @@ func Compress(rootDir string, relPaths []string, destRelPath string) error {
 			if err != nil {
 				return err
 			}
+			// Opening a FIFO such as console.in would block forever.
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
 
 			relInInstance, err := filepath.Rel(rootDir, path)
 			if err != nil {
@@ -0,0 +1,481 @@
+package instances
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jjmc/internal/manager"
+	"jjmc/internal/players"
+	"jjmc/internal/properties"
+)
+
+const (
+	WhitelistFile     = "whitelist.json"
+	OpsFile           = "ops.json"
+	BannedPlayersFile = "banned-players.json"
+	BannedIPsFile     = "banned-ips.json"
+
+	// Format of the "created" and "expires" fields in the ban lists.
+	banTimeLayout = "2006-01-02 15:04:05 -0700"
+	banForever    = "forever"
+	banSource     = "JJMC"
+
+	accessCommandTimeout = 2 * time.Second
+)
+
+// ErrNeedsOffline is returned for changes that have no console command, such
+// as an op level, while the server is running.
+var ErrNeedsOffline = errors.New("this change can only be made while the server is offline")
+
+var ErrInvalidIP = errors.New("invalid IP address")
+
+var ErrInvalidOpLevel = errors.New("op level must be between 1 and 4, or 0 for the server's op-permission-level")
+
+type WhitelistEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+type OpEntry struct {
+	UUID                string `json:"uuid"`
+	Name                string `json:"name"`
+	Level               int    `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+}
+
+type BanEntry struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+type IPBanEntry struct {
+	IP      string `json:"ip"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// AccessResult tells how a change was applied: through the console of the
+// running server (with its response) or by editing the file.
+type AccessResult struct {
+	Live   bool   `json:"live"`
+	Output string `json:"output,omitempty"`
+}
+
+func readAccessList(dir, file string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	return nil
+}
+
+func writeAccessList(dir, file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, file), data, 0644)
+}
+
+func (inst *Instance) onlineMode() bool {
+	props, err := properties.Read(filepath.Join(inst.Directory, PropertiesFile))
+	if err != nil {
+		return true
+	}
+	return props["online-mode"] != "false"
+}
+
+// resolvePlayer resolves name the way the server would: through Mojang in
+// online mode, or to the offline UUID otherwise.
+func (inst *Instance) resolvePlayer(name string) (players.Profile, error) {
+	return players.ResolveProfile(name, inst.onlineMode())
+}
+
+// acceptsCommands reports whether list changes go through the console. A
+// starting server queues them until it has loaded. One that is stopping would
+// drop them, and no longer writes its lists, so its files are edited instead.
+func (inst *Instance) acceptsCommands() bool {
+	state := inst.Manager.GetState()
+	return state == manager.StateOnline || state == manager.StateStarting
+}
+
+// liveCommand runs cmd on the server if it takes commands. It reports false
+// when the caller should edit the file instead.
+func (inst *Instance) liveCommand(cmd string) (AccessResult, bool, error) {
+	if !inst.acceptsCommands() {
+		return AccessResult{}, false, nil
+	}
+	output, err := inst.Manager.ExecuteCommand(cmd, accessCommandTimeout)
+	return AccessResult{Live: true, Output: output}, true, err
+}
+
+// sanitizeReason keeps a ban reason on one console line.
+func sanitizeReason(reason string) string {
+	return strings.Join(strings.Fields(reason), " ")
+}
+
+func banReason(reason string) string {
+	if reason == "" {
+		return "Banned by an operator."
+	}
+	return reason
+}
+
+func banExpiry(expires time.Time) string {
+	if expires.IsZero() {
+		return banForever
+	}
+	return expires.Format(banTimeLayout)
+}
+
+// Whitelist
+
+func (inst *Instance) GetWhitelist() ([]WhitelistEntry, error) {
+	list := []WhitelistEntry{}
+	err := readAccessList(inst.Directory, WhitelistFile, &list)
+	return list, err
+}
+
+func (inst *Instance) AddToWhitelist(name string) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	if result, live, err := inst.liveCommand("whitelist add " + name); live {
+		return result, err
+	}
+
+	profile, err := inst.resolvePlayer(name)
+	if err != nil {
+		return AccessResult{}, err
+	}
+	return AccessResult{}, inst.putWhitelistEntry(WhitelistEntry{UUID: profile.UUID, Name: profile.Name})
+}
+
+func (inst *Instance) RemoveFromWhitelist(name string) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	if result, live, err := inst.liveCommand("whitelist remove " + name); live {
+		return result, err
+	}
+	return AccessResult{}, inst.removeWhitelistEntry(name)
+}
+
+func (inst *Instance) putWhitelistEntry(entry WhitelistEntry) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetWhitelist()
+	if err != nil {
+		return err
+	}
+	for _, existing := range list {
+		if strings.EqualFold(existing.UUID, entry.UUID) {
+			return nil
+		}
+	}
+	return writeAccessList(inst.Directory, WhitelistFile, append(list, entry))
+}
+
+func (inst *Instance) removeWhitelistEntry(name string) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetWhitelist()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, entry := range list {
+		if !strings.EqualFold(entry.Name, name) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(list) {
+		return nil
+	}
+	return writeAccessList(inst.Directory, WhitelistFile, kept)
+}
+
+// Ops
+
+func (inst *Instance) GetOps() ([]OpEntry, error) {
+	list := []OpEntry{}
+	err := readAccessList(inst.Directory, OpsFile, &list)
+	return list, err
+}
+
+// AddOp makes name an operator. A level of 0 uses the server's
+// op-permission-level. Since the op command can't set the level or
+// bypassesPlayerLimit, those need the server to be offline.
+func (inst *Instance) AddOp(name string, level int, bypassesPlayerLimit bool) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	if level < 0 || level > 4 {
+		return AccessResult{}, ErrInvalidOpLevel
+	}
+	if inst.acceptsCommands() && (level != 0 || bypassesPlayerLimit) {
+		return AccessResult{}, ErrNeedsOffline
+	}
+	if result, live, err := inst.liveCommand("op " + name); live {
+		return result, err
+	}
+
+	profile, err := inst.resolvePlayer(name)
+	if err != nil {
+		return AccessResult{}, err
+	}
+	entry := OpEntry{UUID: profile.UUID, Name: profile.Name, Level: level, BypassesPlayerLimit: bypassesPlayerLimit}
+	return AccessResult{}, inst.putOpEntry(entry)
+}
+
+func (inst *Instance) RemoveOp(name string) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	if result, live, err := inst.liveCommand("deop " + name); live {
+		return result, err
+	}
+	return AccessResult{}, inst.removeOpEntry(name)
+}
+
+// putOpEntry adds or replaces an operator. A level of 0 uses the server's
+// op-permission-level.
+func (inst *Instance) putOpEntry(entry OpEntry) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	if entry.Level == 0 {
+		entry.Level = 4
+		if props, err := properties.Read(filepath.Join(inst.Directory, PropertiesFile)); err == nil {
+			fmt.Sscanf(props["op-permission-level"], "%d", &entry.Level)
+		}
+	}
+
+	list, err := inst.GetOps()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range list {
+		if strings.EqualFold(list[i].UUID, entry.UUID) {
+			if list[i] == entry {
+				return nil
+			}
+			list[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		list = append(list, entry)
+	}
+	return writeAccessList(inst.Directory, OpsFile, list)
+}
+
+func (inst *Instance) removeOpEntry(name string) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetOps()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, entry := range list {
+		if !strings.EqualFold(entry.Name, name) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(list) {
+		return nil
+	}
+	return writeAccessList(inst.Directory, OpsFile, kept)
+}
+
+// Player bans
+
+func (inst *Instance) GetBans() ([]BanEntry, error) {
+	list := []BanEntry{}
+	err := readAccessList(inst.Directory, BannedPlayersFile, &list)
+	return list, err
+}
+
+// BanPlayer bans name. A non-zero expires makes it a temporary ban, which
+// the ban command can't express, so it needs the server to be offline.
+func (inst *Instance) BanPlayer(name, reason string, expires time.Time) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	reason = sanitizeReason(reason)
+	if inst.acceptsCommands() && !expires.IsZero() {
+		return AccessResult{}, ErrNeedsOffline
+	}
+	if result, live, err := inst.liveCommand(strings.TrimSpace("ban " + name + " " + reason)); live {
+		return result, err
+	}
+
+	profile, err := inst.resolvePlayer(name)
+	if err != nil {
+		return AccessResult{}, err
+	}
+	entry := BanEntry{
+		UUID:    profile.UUID,
+		Name:    profile.Name,
+		Created: time.Now().Format(banTimeLayout),
+		Source:  banSource,
+		Expires: banExpiry(expires),
+		Reason:  banReason(reason),
+	}
+	return AccessResult{}, inst.putBanEntry(entry)
+}
+
+func (inst *Instance) PardonPlayer(name string) (AccessResult, error) {
+	if !players.ValidName(name) {
+		return AccessResult{}, players.ErrInvalidName
+	}
+	if result, live, err := inst.liveCommand("pardon " + name); live {
+		return result, err
+	}
+	return AccessResult{}, inst.removeBanEntry(name)
+}
+
+func (inst *Instance) putBanEntry(entry BanEntry) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetBans()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, existing := range list {
+		if existing == entry {
+			return nil
+		}
+		if !strings.EqualFold(existing.UUID, entry.UUID) {
+			kept = append(kept, existing)
+		}
+	}
+	return writeAccessList(inst.Directory, BannedPlayersFile, append(kept, entry))
+}
+
+func (inst *Instance) removeBanEntry(name string) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetBans()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, entry := range list {
+		if !strings.EqualFold(entry.Name, name) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(list) {
+		return nil
+	}
+	return writeAccessList(inst.Directory, BannedPlayersFile, kept)
+}
+
+// IP bans
+
+func (inst *Instance) GetIPBans() ([]IPBanEntry, error) {
+	list := []IPBanEntry{}
+	err := readAccessList(inst.Directory, BannedIPsFile, &list)
+	return list, err
+}
+
+func (inst *Instance) BanIP(ip, reason string, expires time.Time) (AccessResult, error) {
+	if net.ParseIP(ip) == nil {
+		return AccessResult{}, ErrInvalidIP
+	}
+	reason = sanitizeReason(reason)
+	if inst.acceptsCommands() && !expires.IsZero() {
+		return AccessResult{}, ErrNeedsOffline
+	}
+	if result, live, err := inst.liveCommand(strings.TrimSpace("ban-ip " + ip + " " + reason)); live {
+		return result, err
+	}
+
+	entry := IPBanEntry{
+		IP:      ip,
+		Created: time.Now().Format(banTimeLayout),
+		Source:  banSource,
+		Expires: banExpiry(expires),
+		Reason:  banReason(reason),
+	}
+	return AccessResult{}, inst.putIPBanEntry(entry)
+}
+
+func (inst *Instance) PardonIP(ip string) (AccessResult, error) {
+	if net.ParseIP(ip) == nil {
+		return AccessResult{}, ErrInvalidIP
+	}
+	if result, live, err := inst.liveCommand("pardon-ip " + ip); live {
+		return result, err
+	}
+	return AccessResult{}, inst.removeIPBanEntry(ip)
+}
+
+func (inst *Instance) putIPBanEntry(entry IPBanEntry) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetIPBans()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, existing := range list {
+		if existing == entry {
+			return nil
+		}
+		if existing.IP != entry.IP {
+			kept = append(kept, existing)
+		}
+	}
+	return writeAccessList(inst.Directory, BannedIPsFile, append(kept, entry))
+}
+
+func (inst *Instance) removeIPBanEntry(ip string) error {
+	inst.accessMu.Lock()
+	defer inst.accessMu.Unlock()
+
+	list, err := inst.GetIPBans()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, entry := range list {
+		if entry.IP != ip {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(list) {
+		return nil
+	}
+	return writeAccessList(inst.Directory, BannedIPsFile, kept)
+}
@@ -0,0 +1,157 @@
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+// offlineInstance loads a stopped offline-mode instance, so that names
+// resolve without going to Mojang.
+func offlineInstance(t *testing.T) *Instance {
+	t.Helper()
+	useTestDB(t)
+	dir := t.TempDir()
+	im := &InstanceManager{instances: map[string]*Instance{}, baseDir: filepath.Dir(dir), Players: players.NewTracker(database.DB)}
+	os.WriteFile(filepath.Join(dir, PropertiesFile), []byte("online-mode=false\nop-permission-level=2\n"), 0644)
+	inst := im.loadInstance(models.InstanceModel{ID: filepath.Base(dir), Name: "SMP", Type: "paper"})
+	inst.Manager.SetSilent(true)
+	return inst
+}
+
+func readList(t *testing.T, inst *Instance, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(inst.Directory, file))
+	if err != nil {
+		t.Fatalf("%s not written: %v", file, err)
+	}
+	return string(data)
+}
+
+func TestOfflineWhitelistEdits(t *testing.T) {
+	inst := offlineInstance(t)
+
+	result, err := inst.AddToWhitelist("Steve")
+	if err != nil || result.Live {
+		t.Fatalf("expected the file to be edited, got %+v (%v)", result, err)
+	}
+	list, _ := inst.GetWhitelist()
+	if len(list) != 1 || list[0].Name != "Steve" || list[0].UUID != players.OfflineUUID("Steve") {
+		t.Fatalf("unexpected whitelist %+v", list)
+	}
+	// Adding again doesn't duplicate the entry.
+	inst.AddToWhitelist("Steve")
+	if list, _ := inst.GetWhitelist(); len(list) != 1 {
+		t.Errorf("expected 1 entry, got %+v", list)
+	}
+
+	if _, err := inst.RemoveFromWhitelist("steve"); err != nil {
+		t.Fatal(err)
+	}
+	if data := readList(t, inst, WhitelistFile); strings.Contains(data, "Steve") {
+		t.Errorf("Steve still whitelisted: %s", data)
+	}
+	if _, err := inst.AddToWhitelist("not a name"); err != players.ErrInvalidName {
+		t.Errorf("expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestOfflineOpEdits(t *testing.T) {
+	inst := offlineInstance(t)
+
+	if _, err := inst.AddOp("Steve", 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.AddOp("Alex", 4, true); err != nil {
+		t.Fatal(err)
+	}
+	ops, _ := inst.GetOps()
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 ops, got %+v", ops)
+	}
+	// Level 0 takes the server's op-permission-level.
+	if ops[0].Name != "Steve" || ops[0].Level != 2 || ops[0].BypassesPlayerLimit {
+		t.Errorf("unexpected op %+v", ops[0])
+	}
+	if ops[1].Name != "Alex" || ops[1].Level != 4 || !ops[1].BypassesPlayerLimit {
+		t.Errorf("unexpected op %+v", ops[1])
+	}
+
+	// Changing the level replaces the entry.
+	inst.AddOp("Steve", 3, false)
+	if ops, _ := inst.GetOps(); len(ops) != 2 || ops[0].Level != 3 {
+		t.Errorf("expected Steve at level 3, got %+v", ops)
+	}
+
+	if _, err := inst.RemoveOp("Steve"); err != nil {
+		t.Fatal(err)
+	}
+	if ops, _ := inst.GetOps(); len(ops) != 1 || ops[0].Name != "Alex" {
+		t.Errorf("expected only Alex left, got %+v", ops)
+	}
+	for _, level := range []int{-1, 5} {
+		if _, err := inst.AddOp("Steve", level, false); err != ErrInvalidOpLevel {
+			t.Errorf("level %d: expected ErrInvalidOpLevel, got %v", level, err)
+		}
+	}
+}
+
+func TestOfflineBanEdits(t *testing.T) {
+	inst := offlineInstance(t)
+
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	if _, err := inst.BanPlayer("Griefer", "spam", expires); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.BanPlayer("Cheater", "", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	bans, _ := inst.GetBans()
+	if len(bans) != 2 {
+		t.Fatalf("expected 2 bans, got %+v", bans)
+	}
+	if bans[0].Name != "Griefer" || bans[0].Reason != "spam" || bans[0].Expires != expires.Format(banTimeLayout) || bans[0].Source != banSource {
+		t.Errorf("unexpected ban %+v", bans[0])
+	}
+	if bans[1].Expires != banForever || bans[1].Reason == "" {
+		t.Errorf("expected a permanent ban with the default reason, got %+v", bans[1])
+	}
+
+	if _, err := inst.PardonPlayer("griefer"); err != nil {
+		t.Fatal(err)
+	}
+	if data := readList(t, inst, BannedPlayersFile); strings.Contains(data, "Griefer") || !strings.Contains(data, "Cheater") {
+		t.Errorf("unexpected banned-players.json: %s", data)
+	}
+}
+
+func TestOfflineIPBanEdits(t *testing.T) {
+	inst := offlineInstance(t)
+
+	if _, err := inst.BanIP("10.0.0.7", "proxy", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.BanIP("2001:db8::1", "", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	bans, _ := inst.GetIPBans()
+	if len(bans) != 2 || bans[0].IP != "10.0.0.7" || bans[0].Reason != "proxy" || bans[1].IP != "2001:db8::1" {
+		t.Fatalf("unexpected ip bans %+v", bans)
+	}
+
+	if _, err := inst.PardonIP("10.0.0.7"); err != nil {
+		t.Fatal(err)
+	}
+	if data := readList(t, inst, BannedIPsFile); strings.Contains(data, "10.0.0.7") {
+		t.Errorf("10.0.0.7 still banned: %s", data)
+	}
+	if _, err := inst.BanIP("10.0.0", "", time.Time{}); err != ErrInvalidIP {
+		t.Errorf("expected ErrInvalidIP, got %v", err)
+	}
+}
@@ -1,27 +1,396 @@
 package instances
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"jjmc/internal/backup"
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
 )
 
 // Re-export Backup type for compatibility
 type Backup = backup.Backup
 
-func (im *InstanceManager) GetBackupDir(instanceID string) string {
-	return filepath.Join("data", "backups", instanceID)
+// What started a backup.
+const (
+	BackupManual   = "manual"
+	BackupSchedule = "schedule"
+)
+
+// Backup modes: zip archives, or snapshots in the deduplicating store.
+const (
+	BackupModeZip      = "zip"
+	BackupModeSnapshot = "snapshot"
+)
+
+// BackupOptions are the options of a backup, also used as the payload of
+// backup schedules.
+type BackupOptions struct {
+	WorldOnly bool   `json:"worldOnly"`
+	Label     string `json:"label"`
 }
 
-func (im *InstanceManager) CreateBackup(instanceID string) error {
+// ParseBackupOptions reads the payload of a backup schedule. An empty payload
+// means a full backup without a label.
+func ParseBackupOptions(payload string) (BackupOptions, error) {
+	var opts BackupOptions
+	if strings.TrimSpace(payload) == "" {
+		return opts, nil
+	}
+	if err := json.Unmarshal([]byte(payload), &opts); err != nil {
+		return opts, fmt.Errorf("invalid backup options: %v", err)
+	}
+	return opts, nil
+}
+
+// worldFilter keeps only the world folders of the server in dir. It fails
+// when there are none, rather than making an empty backup.
+func worldFilter(dir string) (func(rel string, info os.FileInfo) bool, error) {
+	worlds := []string{}
+	for _, world := range worldDirs(dir) {
+		if info, err := os.Stat(filepath.Join(dir, world)); err == nil && info.IsDir() {
+			worlds = append(worlds, world)
+		}
+	}
+	if len(worlds) == 0 {
+		return nil, fmt.Errorf("no world folders found")
+	}
+	return func(rel string, info os.FileInfo) bool {
+		top, _, _ := strings.Cut(rel, "/")
+		for _, world := range worlds {
+			if top == world {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// decodeExcludes reads the exclude patterns saved for an instance.
+func decodeExcludes(data string) []string {
+	if data == "" {
+		return nil
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(data), &patterns); err != nil {
+		return nil
+	}
+	if patterns == nil {
+		patterns = []string{}
+	}
+	return patterns
+}
+
+func excludesOf(inst *Instance) []string {
+	if inst.BackupExcludes == nil {
+		return backup.DefaultExcludes
+	}
+	return inst.BackupExcludes
+}
+
+// backupFilter decides what goes into a backup of the instance: never the
+// files of the running server, nor what its exclude patterns match, and
+// with worldOnly only its worlds.
+func backupFilter(inst *Instance, worldOnly bool) (func(rel string, info os.FileInfo) bool, error) {
+	excludes, err := backup.ParseExcludes(excludesOf(inst))
+	if err != nil {
+		return nil, err
+	}
+	var worlds func(rel string, info os.FileInfo) bool
+	if worldOnly {
+		if worlds, err = worldFilter(inst.Directory); err != nil {
+			return nil, err
+		}
+	}
+	return func(rel string, info os.FileInfo) bool {
+		if runtimeFiles[rel] || excludes.Excluded(rel, info.IsDir()) {
+			return false
+		}
+		return worlds == nil || worlds(rel, info)
+	}, nil
+}
+
+var (
+	saveOffRe = regexp.MustCompile(`Automatic saving is now disabled|Saving is already turned off`)
+	saveOnRe  = regexp.MustCompile(`Automatic saving is now enabled|Saving is already turned on`)
+	// Older servers say "world" rather than "game".
+	savedRe = regexp.MustCompile(`Saved the (game|world)`)
+)
+
+var (
+	saveCommandTimeout = 10 * time.Second
+	saveAllTimeout     = 2 * time.Minute
+)
+
+// withSavingPaused runs fn while the server has written everything to disk
+// and stopped saving, so that the region files don't change under a backup.
+// Saving is turned back on however fn or the flush turn out. Servers that
+// aren't online, and proxies, which have no world, just run fn.
+func (inst *Instance) withSavingPaused(fn func() error) error {
+	if inst.Manager.GetState() != manager.StateOnline || isProxyType(inst.Type) {
+		return fn()
+	}
+
+	inst.Manager.Broadcast("Pausing world saving for backup...")
+	defer func() {
+		if _, err := inst.Manager.ExecuteAndWait("save-on", saveOnRe, saveCommandTimeout); err != nil {
+			inst.Manager.Broadcast(fmt.Sprintf("Failed to turn saving back on, run save-on by hand: %v", err))
+			return
+		}
+		inst.Manager.Broadcast("World saving resumed")
+	}()
+
+	if _, err := inst.Manager.ExecuteAndWait("save-off", saveOffRe, saveCommandTimeout); err != nil {
+		return fmt.Errorf("failed to turn off saving: %v", err)
+	}
+	if _, err := inst.Manager.ExecuteAndWait("save-all flush", savedRe, saveAllTimeout); err != nil {
+		return fmt.Errorf("failed to save the world: %v", err)
+	}
+	return fn()
+}
+
+// RunBackup backs up an instance and records the run. Failures are broadcast
+// to the console and sent to the instance's webhook.
+func (im *InstanceManager) RunBackup(instanceID string, opts BackupOptions, trigger string) (*models.BackupRun, error) {
+	inst, err := im.GetInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	run := &models.BackupRun{
+		InstanceID: instanceID,
+		Trigger:    trigger,
+		Label:      backup.CleanLabel(opts.Label),
+		WorldOnly:  opts.WorldOnly,
+		Status:     "running",
+		StartedAt:  started.Unix(),
+	}
+	database.DB.Create(run)
+
+	backupOpts := backup.Options{Label: opts.Label}
+	backupOpts.Include, err = backupFilter(inst, opts.WorldOnly)
+
+	create := backup.CreateWithOptions
+	if inst.BackupMode == BackupModeSnapshot {
+		create = backup.CreateSnapshot
+	}
+
+	inst.backupMu.Lock()
+	defer inst.backupMu.Unlock()
+	var created Backup
+	if err == nil {
+		err = inst.withSavingPaused(func() error {
+			var err error
+			created, err = create(inst.Directory, im.GetBackupDir(instanceID), inst.Name, backupOpts)
+			return err
+		})
+	}
+
+	run.DurationMs = time.Since(started).Milliseconds()
+	run.FinishedAt = time.Now().Unix()
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		database.DB.Save(run)
+		inst.Manager.Broadcast(fmt.Sprintf("Backup failed: %v", err))
+		inst.Manager.NotifyFailure("Backup Failed", fmt.Sprintf("The %s backup of **%s** failed.", trigger, inst.Name), err.Error())
+		return run, err
+	}
+	run.Status = "success"
+	run.Backup = created.Name
+	run.Size = created.Size
+
+	pruned, err := backup.Prune(im.GetBackupDir(instanceID), retentionOf(inst), false)
+	if err == nil && len(pruned) > 0 {
+		_, _, err = backup.GC(im.GetBackupDir(instanceID))
+	}
+	if err != nil {
+		inst.Manager.Broadcast(fmt.Sprintf("Failed to prune old backups: %v", err))
+	}
+	run.Pruned = len(pruned)
+	if run.Pruned > 0 {
+		inst.Manager.Broadcast(fmt.Sprintf("Removed %d old backup(s) per the retention policy", run.Pruned))
+	}
+	database.DB.Save(run)
+	return run, nil
+}
+
+func retentionOf(inst *Instance) backup.Retention {
+	return backup.Retention{
+		KeepLast:  inst.BackupKeepLast,
+		Daily:     inst.BackupKeepDaily,
+		Weekly:    inst.BackupKeepWeekly,
+		Monthly:   inst.BackupKeepMonthly,
+		MaxSizeMB: inst.BackupMaxSizeMB,
+	}
+}
+
+// BackupRetention returns the retention policy of an instance.
+func (im *InstanceManager) BackupRetention(instanceID string) (backup.Retention, error) {
 	inst, err := im.GetInstance(instanceID)
 	if err != nil {
+		return backup.Retention{}, err
+	}
+	return retentionOf(inst), nil
+}
+
+func (im *InstanceManager) UpdateBackupRetention(instanceID string, r backup.Retention) error {
+	if err := r.Validate(); err != nil {
 		return err
 	}
 
-	backupDir := im.GetBackupDir(instanceID)
-	return backup.Create(inst.Directory, backupDir, inst.Name)
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+
+	err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", instanceID).Updates(map[string]interface{}{
+		"backup_keep_last":    r.KeepLast,
+		"backup_keep_daily":   r.Daily,
+		"backup_keep_weekly":  r.Weekly,
+		"backup_keep_monthly": r.Monthly,
+		"backup_max_size_mb":  r.MaxSizeMB,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+
+	inst.BackupKeepLast = r.KeepLast
+	inst.BackupKeepDaily = r.Daily
+	inst.BackupKeepWeekly = r.Weekly
+	inst.BackupKeepMonthly = r.Monthly
+	inst.BackupMaxSizeMB = r.MaxSizeMB
+	return nil
+}
+
+// UpdateBackupMode sets whether new backups of an instance are zip archives
+// or snapshots. Existing backups stay as they are.
+func (im *InstanceManager) UpdateBackupMode(instanceID, mode string) error {
+	if mode != BackupModeZip && mode != BackupModeSnapshot {
+		return fmt.Errorf("invalid backup mode: %s", mode)
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+	if err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", instanceID).Update("backup_mode", mode).Error; err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+	inst.BackupMode = mode
+	return nil
+}
+
+// BackupExcludes returns the exclude patterns of an instance and whether
+// they are the defaults.
+func (im *InstanceManager) BackupExcludes(instanceID string) ([]string, bool, error) {
+	inst, err := im.GetInstance(instanceID)
+	if err != nil {
+		return nil, false, err
+	}
+	return excludesOf(inst), inst.BackupExcludes == nil, nil
+}
+
+// UpdateBackupExcludes sets the exclude patterns of an instance. nil goes
+// back to the defaults, an empty list excludes nothing.
+func (im *InstanceManager) UpdateBackupExcludes(instanceID string, patterns []string) error {
+	if _, err := backup.ParseExcludes(patterns); err != nil {
+		return err
+	}
+	data := ""
+	if patterns != nil {
+		encoded, err := json.Marshal(patterns)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+	if err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", instanceID).Update("backup_excludes", data).Error; err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+	inst.BackupExcludes = decodeExcludes(data)
+	return nil
+}
+
+// PruneBackups removes the backups the instance's retention policy doesn't
+// keep. With dryRun nothing is removed and the backups that would be are
+// returned.
+func (im *InstanceManager) PruneBackups(instanceID string, dryRun bool) ([]Backup, error) {
+	inst, err := im.GetInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return backup.Prune(im.GetBackupDir(instanceID), retentionOf(inst), true)
+	}
+
+	inst.backupMu.Lock()
+	defer inst.backupMu.Unlock()
+	pruned, err := backup.Prune(im.GetBackupDir(instanceID), retentionOf(inst), false)
+	if err != nil {
+		return pruned, err
+	}
+	if len(pruned) > 0 {
+		_, _, err = backup.GC(im.GetBackupDir(instanceID))
+	}
+	return pruned, err
+}
+
+// GCResult is what GCBackups cleaned up.
+type GCResult struct {
+	Removed int   `json:"removed"` // Chunks
+	Freed   int64 `json:"freed"`   // Bytes
+}
+
+// GCBackups removes the snapshot chunks no backup of the instance uses.
+func (im *InstanceManager) GCBackups(instanceID string) (GCResult, error) {
+	inst, err := im.GetInstance(instanceID)
+	if err != nil {
+		return GCResult{}, err
+	}
+	// A snapshot being taken has chunks no manifest refers to yet.
+	inst.backupMu.Lock()
+	defer inst.backupMu.Unlock()
+	removed, freed, err := backup.GC(im.GetBackupDir(instanceID))
+	return GCResult{Removed: removed, Freed: freed}, err
+}
+
+// BackupRuns returns the latest backup runs of an instance, newest first.
+func (im *InstanceManager) BackupRuns(instanceID string, limit int) ([]models.BackupRun, error) {
+	runs := []models.BackupRun{}
+	err := database.DB.Where("instance_id = ?", instanceID).Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+func (im *InstanceManager) GetBackupDir(instanceID string) string {
+	return filepath.Join("data", "backups", instanceID)
+}
+
+func (im *InstanceManager) CreateBackup(instanceID string) error {
+	_, err := im.RunBackup(instanceID, BackupOptions{}, BackupManual)
+	return err
 }
 
 func (im *InstanceManager) ListBackups(instanceID string) ([]Backup, error) {
@@ -29,21 +398,92 @@ func (im *InstanceManager) ListBackups(instanceID string) ([]Backup, error) {
 	return backup.List(backupDir)
 }
 
+// backupPath returns the path of a backup, refusing names that would point
+// outside of the instance's backups.
+func (im *InstanceManager) backupPath(instanceID, backupName string) (string, error) {
+	if backupName == "" || backupName != filepath.Base(backupName) || strings.ContainsAny(backupName, `/\`) || strings.HasPrefix(backupName, ".") {
+		return "", fmt.Errorf("invalid backup name")
+	}
+	return filepath.Join(im.GetBackupDir(instanceID), backupName), nil
+}
+
+func (im *InstanceManager) snapshot(instanceID, backupName string) (*backup.Snapshot, error) {
+	path, err := im.backupPath(instanceID, backupName)
+	if err != nil {
+		return nil, err
+	}
+	if !backup.IsSnapshot(backupName) {
+		return nil, fmt.Errorf("%s is not a snapshot", backupName)
+	}
+	return backup.ReadSnapshot(path)
+}
+
+// BrowseBackup lists the files and folders in dir of a snapshot backup.
+func (im *InstanceManager) BrowseBackup(instanceID, backupName, dir string) ([]backup.SnapshotEntry, error) {
+	s, err := im.snapshot(instanceID, backupName)
+	if err != nil {
+		return nil, err
+	}
+	return s.Browse(dir), nil
+}
+
+// DiffBackups lists the files that changed from snapshot against to
+// snapshot backupName.
+func (im *InstanceManager) DiffBackups(instanceID, backupName, against string) (backup.Diff, error) {
+	s, err := im.snapshot(instanceID, backupName)
+	if err != nil {
+		return backup.Diff{}, err
+	}
+	old, err := im.snapshot(instanceID, against)
+	if err != nil {
+		return backup.Diff{}, err
+	}
+	return s.Diff(old), nil
+}
+
 func (im *InstanceManager) RestoreBackup(instanceID, backupName string) error {
+	return im.RestoreBackupPaths(instanceID, backupName, nil)
+}
+
+// RestoreBackupPaths restores a backup. For snapshots, paths can limit the
+// restore to some files and folders.
+func (im *InstanceManager) RestoreBackupPaths(instanceID, backupName string, paths []string) error {
 	inst, err := im.GetInstance(instanceID)
 	if err != nil {
 		return err
 	}
+	// Pruning or GC must not take chunks out from under the restore.
+	inst.backupMu.Lock()
+	defer inst.backupMu.Unlock()
 
 	if inst.IsRunning() {
 		return fmt.Errorf("instance must be offline to restore backup")
 	}
 
-	backupPath := filepath.Join(im.GetBackupDir(instanceID), backupName)
-	return backup.Restore(backupPath, filepath.Dir(inst.Directory))
+	backupPath, err := im.backupPath(instanceID, backupName)
+	if err != nil {
+		return err
+	}
+	// Into the directory as it is named now, in case the ID changed.
+	if backup.IsSnapshot(backupName) {
+		return backup.RestoreSnapshot(backupPath, inst.Directory, paths)
+	}
+	if len(paths) > 0 {
+		return fmt.Errorf("only snapshots can be restored in part")
+	}
+	return backup.RestoreInto(backupPath, inst.Directory)
 }
 
 func (im *InstanceManager) DeleteBackup(instanceID, backupName string) error {
-	backupPath := filepath.Join(im.GetBackupDir(instanceID), backupName)
+	inst, err := im.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+	backupPath, err := im.backupPath(instanceID, backupName)
+	if err != nil {
+		return err
+	}
+	inst.backupMu.Lock()
+	defer inst.backupMu.Unlock()
 	return backup.Delete(backupPath)
 }
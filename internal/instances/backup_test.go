@@ -0,0 +1,216 @@
+package instances
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+func TestParseBackupOptions(t *testing.T) {
+	if opts, err := ParseBackupOptions(""); err != nil || opts.WorldOnly || opts.Label != "" {
+		t.Errorf("empty payload: %+v, %v", opts, err)
+	}
+	opts, err := ParseBackupOptions(`{"worldOnly": true, "label": "nightly"}`)
+	if err != nil || !opts.WorldOnly || opts.Label != "nightly" {
+		t.Errorf("got %+v, %v", opts, err)
+	}
+	if _, err := ParseBackupOptions("not json"); err == nil {
+		t.Error("expected an error for an invalid payload")
+	}
+}
+
+func TestRunBackup(t *testing.T) {
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41600, Last: 41700},
+	}
+
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", Version: "1.20.4", MaxMemory: 2048}
+	database.DB.Create(&model)
+	dir := filepath.Join(base, "smp")
+	for _, d := range []string{"skyblock/region", "skyblock_nether", "plugins"} {
+		os.MkdirAll(filepath.Join(dir, d), 0755)
+	}
+	os.WriteFile(filepath.Join(dir, PropertiesFile), []byte("level-name=skyblock\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "skyblock", "level.dat"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "plugins", "Essentials.jar"), []byte("x"), 0644)
+	im.instances["smp"] = im.loadInstance(model)
+
+	run, err := im.RunBackup("smp", BackupOptions{WorldOnly: true, Label: "pre update!"}, BackupSchedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "success" || run.Label != "pre-update" || run.Size == 0 || run.Trigger != BackupSchedule {
+		t.Errorf("unexpected run %+v", run)
+	}
+
+	zr, err := zip.OpenReader(filepath.Join(im.GetBackupDir("smp"), run.Backup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, filepath.ToSlash(f.Name))
+	}
+	sort.Strings(names)
+	want := []string{"smp/", "smp/skyblock/", "smp/skyblock/level.dat", "smp/skyblock/region/", "smp/skyblock_nether/"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("got %v, want %v", names, want)
+			break
+		}
+	}
+
+	// A missing directory fails and is recorded as such.
+	os.RemoveAll(dir)
+	if _, err := im.RunBackup("smp", BackupOptions{}, BackupManual); err == nil {
+		t.Error("expected backup of a missing directory to fail")
+	}
+	runs, _ := im.BackupRuns("smp", 10)
+	if len(runs) != 2 || runs[0].Status != "failed" || runs[0].Error == "" || runs[1].Status != "success" {
+		t.Errorf("unexpected history %+v", runs)
+	}
+}
+
+func TestRunBackupExcludes(t *testing.T) {
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41600, Last: 41700},
+	}
+
+	model := models.InstanceModel{ID: "lobby", Name: "Lobby", Type: "paper", Version: "1.20.4", MaxMemory: 2048}
+	database.DB.Create(&model)
+	dir := filepath.Join(base, "lobby")
+	for _, d := range []string{"libraries/org", "logs", "plugins", "cache"} {
+		os.MkdirAll(filepath.Join(dir, d), 0755)
+	}
+	for _, f := range []string{"paper.jar", "server.log", "libraries/org/lib.jar", "logs/latest.log", "plugins/Essentials.jar", "cache/mojang.jar", "ops.json"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("x"), 0644)
+	}
+	im.instances["lobby"] = im.loadInstance(model)
+
+	backupNames := func(run *models.BackupRun) []string {
+		t.Helper()
+		zr, err := zip.OpenReader(filepath.Join(im.GetBackupDir("lobby"), run.Backup))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, filepath.ToSlash(f.Name))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	run, err := im.RunBackup("lobby", BackupOptions{Label: "defaults"}, BackupManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"lobby/", "lobby/ops.json", "lobby/plugins/", "lobby/plugins/Essentials.jar"}
+	if got := backupNames(run); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := im.UpdateBackupExcludes("lobby", []string{"plugins/", "[bad"}); err == nil {
+		t.Error("expected a malformed pattern to be rejected")
+	}
+	if err := im.UpdateBackupExcludes("lobby", []string{"plugins/", "*.log"}); err != nil {
+		t.Fatal(err)
+	}
+	// The patterns are saved, not only kept in memory.
+	var saved models.InstanceModel
+	database.DB.First(&saved, "id = ?", "lobby")
+	im.instances["lobby"] = im.loadInstance(saved)
+	patterns, isDefault, _ := im.BackupExcludes("lobby")
+	if isDefault || len(patterns) != 2 {
+		t.Fatalf("unexpected patterns %v (default %v)", patterns, isDefault)
+	}
+
+	run, err = im.RunBackup("lobby", BackupOptions{Label: "custom"}, BackupManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"lobby/", "lobby/cache/", "lobby/cache/mojang.jar", "lobby/libraries/", "lobby/libraries/org/", "lobby/libraries/org/lib.jar", "lobby/logs/", "lobby/ops.json", "lobby/paper.jar"}
+	if got := backupNames(run); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := im.RunBackup("lobby", BackupOptions{WorldOnly: true}, BackupManual); err == nil {
+		t.Error("expected a world-only backup without worlds to fail")
+	}
+}
+
+func TestRestoreAndDeleteWaitForBackups(t *testing.T) {
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41600, Last: 41700},
+	}
+
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", Version: "1.20.4", MaxMemory: 2048, BackupMode: BackupModeSnapshot}
+	database.DB.Create(&model)
+	dir := filepath.Join(base, "smp")
+	os.MkdirAll(filepath.Join(dir, "world"), 0755)
+	os.WriteFile(filepath.Join(dir, "world", "level.dat"), []byte("x"), 0644)
+	inst := im.loadInstance(model)
+	im.instances["smp"] = inst
+
+	run, err := im.RunBackup("smp", BackupOptions{}, BackupManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// While a backup, prune or GC holds the lock, neither may touch the
+	// backups.
+	inst.backupMu.Lock()
+	done := make(chan string, 2)
+	go func() {
+		im.RestoreBackup("smp", run.Backup)
+		done <- "restore"
+	}()
+	go func() {
+		im.DeleteBackup("smp", run.Backup)
+		done <- "delete"
+	}()
+	select {
+	case op := <-done:
+		t.Fatalf("%s did not wait for the backup lock", op)
+	case <-time.After(100 * time.Millisecond):
+	}
+	inst.backupMu.Unlock()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("restore or delete did not finish")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+//go:build !windows
+
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+// A stand-in server that answers the save commands. With flushFails it never
+// confirms the save.
+func saveServer(flushFails bool) string {
+	flush := `echo "Saving the game (this may take a moment!)"; echo "Saved the game"`
+	if flushFails {
+		flush = `echo "Saving the game (this may take a moment!)"`
+	}
+	return `echo 'Done (1.0s)! For help, type "help"'; while read line; do case "$line" in ` +
+		`stop) exit 0;; ` +
+		`save-off) echo "Automatic saving is now disabled";; ` +
+		`"save-all flush") ` + flush + `;; ` +
+		`save-on) echo "Automatic saving is now enabled";; ` +
+		`esac; done`
+}
+
+func consoleOrder(m *manager.Manager, texts ...string) bool {
+	next := 0
+	for _, line := range m.QueryScrollback(manager.ScrollbackQuery{}).Lines {
+		if next < len(texts) && line.Text == texts[next] {
+			next++
+		}
+	}
+	return next == len(texts)
+}
+
+func startSaveServer(t *testing.T, flushFails bool) (*InstanceManager, *Instance) {
+	t.Helper()
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41800, Last: 41900},
+	}
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", StartCommand: saveServer(flushFails)}
+	database.DB.Create(&model)
+	os.MkdirAll(filepath.Join(base, "smp"), 0755)
+	if err := im.assignPorts("smp", filepath.Join(base, "smp"), false); err != nil {
+		t.Fatal(err)
+	}
+	inst := im.loadInstance(model)
+	inst.Manager.SetSilent(true)
+	im.instances["smp"] = inst
+
+	if err := inst.Manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { inst.Manager.Stop() })
+	waitOnline(t, inst)
+	return im, inst
+}
+
+func waitOnline(t *testing.T, inst *Instance) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for inst.Manager.GetState() != manager.StateOnline {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not come online")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBackupPausesSaving(t *testing.T) {
+	im, inst := startSaveServer(t, false)
+
+	run, err := im.RunBackup("smp", BackupOptions{}, BackupManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "success" {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if !consoleOrder(inst.Manager, "Automatic saving is now disabled", "Saved the game", "Automatic saving is now enabled") {
+		t.Error("expected save-off, save-all flush and save-on around the backup")
+	}
+}
+
+func TestBackupResumesSavingOnFailure(t *testing.T) {
+	im, inst := startSaveServer(t, true)
+	prev := saveAllTimeout
+	saveAllTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { saveAllTimeout = prev })
+
+	if _, err := im.RunBackup("smp", BackupOptions{}, BackupManual); err == nil {
+		t.Fatal("expected the backup to fail when the save is never confirmed")
+	}
+	if !consoleOrder(inst.Manager, "Automatic saving is now disabled", "Automatic saving is now enabled") {
+		t.Error("saving was not turned back on after the failure")
+	}
+	if backups, _ := im.ListBackups("smp"); len(backups) != 0 {
+		t.Errorf("expected no backup, got %v", backups)
+	}
+}
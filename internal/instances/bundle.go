@@ -0,0 +1,333 @@
+package instances
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/properties"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BundleManifestName is the manifest at the root of an export bundle;
+	// the instance's files sit under bundleFilesDir next to it.
+	BundleManifestName = "jjmc-bundle.json"
+	bundleFilesDir     = "files/"
+	bundleFormat       = 1
+
+	tunnelConfigFile = "tunnel.json"
+)
+
+// BundleManifest describes an exported instance well enough to recreate it
+// on another JJMC host.
+type BundleManifest struct {
+	Format     int                  `json:"format"`
+	ExportedAt int64                `json:"exportedAt"`
+	TemplateID string               `json:"templateId,omitempty"`
+	Instance   models.InstanceModel `json:"instance"`
+	Schedules  []models.Schedule    `json:"schedules"`
+	Tunnel     *TunnelConfig        `json:"tunnel,omitempty"`  // Without the token
+	Webhook    bool                 `json:"webhook,omitempty"` // Whether a webhook URL was left out
+	Plugins    []InstalledPlugin    `json:"plugins"`
+}
+
+// BundleImport is the result of ImportBundle. Schedules are saved but still
+// need to be handed to the scheduler.
+type BundleImport struct {
+	Instance  *Instance         `json:"instance"`
+	Schedules []models.Schedule `json:"schedules"`
+	Warnings  []string          `json:"warnings"`
+}
+
+func (im *InstanceManager) bundleManifest(inst *Instance) (*BundleManifest, error) {
+	manifest := &BundleManifest{
+		Format:     bundleFormat,
+		ExportedAt: time.Now().Unix(),
+		Schedules:  []models.Schedule{},
+		Plugins:    []InstalledPlugin{},
+	}
+	if err := database.DB.First(&manifest.Instance, "id = ?", inst.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load instance: %v", err)
+	}
+	if err := database.DB.Where("instance_id = ?", inst.ID).Find(&manifest.Schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schedules: %v", err)
+	}
+	// Webhook URLs carry the token that lets anyone post to them.
+	manifest.Webhook = manifest.Instance.WebhookURL != ""
+	manifest.Instance.WebhookURL = ""
+	if im.TemplateMgr != nil {
+		if _, ok := im.TemplateMgr.GetTemplate(inst.Type); ok {
+			manifest.TemplateID = inst.Type
+		}
+	}
+
+	tunnel := inst.Tunnel.GetStatus().Config
+	if tunnel.Provider != "" {
+		tunnel.Token = ""
+		manifest.Tunnel = &tunnel
+	}
+
+	if data, err := os.ReadFile(filepath.Join(inst.Directory, "installed_plugins.json")); err == nil {
+		json.Unmarshal(data, &manifest.Plugins)
+	}
+	return manifest, nil
+}
+
+// ExportBundle writes the instance as a zip bundle to w: a manifest with its
+// settings and schedules, then its files. Files only the running server
+// needs and credentials (the tunnel token, webhook URL and RCON password)
+// are left out.
+func (im *InstanceManager) ExportBundle(id string, w io.Writer) error {
+	inst, err := im.GetInstance(id)
+	if err != nil {
+		return err
+	}
+	manifest, err := im.bundleManifest(inst)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	mw, err := zw.Create(BundleManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	err = filepath.Walk(inst.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(inst.Directory, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if runtimeFiles[rel] || rel == tunnelConfigFile {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			_, err := zw.Create(bundleFilesDir + rel + "/")
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = bundleFilesDir + rel
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if rel == PropertiesFile {
+			props, err := properties.Load(path)
+			if err != nil {
+				return err
+			}
+			if _, ok := props.Get("rcon.password"); ok {
+				props.Set("rcon.password", "")
+			}
+			_, err = fw.Write(props.Bytes())
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(fw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func readBundleManifest(r *zip.Reader) (*BundleManifest, error) {
+	for _, f := range r.File {
+		if f.Name != BundleManifestName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var manifest BundleManifest
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %v", err)
+		}
+		if manifest.Format != bundleFormat {
+			return nil, fmt.Errorf("unsupported bundle format %d", manifest.Format)
+		}
+		return &manifest, nil
+	}
+	return nil, fmt.Errorf("not a JJMC bundle: %s is missing", BundleManifestName)
+}
+
+func extractBundleFiles(r *zip.Reader, dir string) error {
+	return extractZip(r, dir, bundleFilesDir)
+}
+
+// extractZip extracts the entries under prefix into dir, refusing entries
+// that would land outside of it.
+func extractZip(r *zip.Reader, dir, prefix string) error {
+	root := filepath.Clean(dir) + string(os.PathSeparator)
+	for _, f := range r.File {
+		rel := strings.TrimPrefix(f.Name, prefix)
+		if (prefix != "" && rel == f.Name) || rel == "" || runtimeFiles[strings.TrimSuffix(rel, "/")] {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, root) {
+			return fmt.Errorf("illegal path in archive: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractZipFile(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ImportBundle recreates an instance from a bundle made by ExportBundle. The
+// ID and name from the manifest are used unless id or name are given. Ports
+// that clash with instances on this host are replaced.
+func (im *InstanceManager) ImportBundle(bundlePath, id, name string) (*BundleImport, error) {
+	zr, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %v", err)
+	}
+	defer zr.Close()
+
+	manifest, err := readBundleManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	model := manifest.Instance
+	if id != "" {
+		model.ID = id
+	}
+	if name != "" {
+		model.Name = name
+	}
+	if !instanceIDRe.MatchString(model.ID) {
+		return nil, fmt.Errorf("invalid instance id: %s", model.ID)
+	}
+	// Folders are local to the host the bundle came from.
+	model.FolderID = ""
+	model.CreatedAt = time.Now().Unix()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if _, exists := im.instances[model.ID]; exists {
+		return nil, fmt.Errorf("instance with id %s already exists", model.ID)
+	}
+	dir := filepath.Join(im.baseDir, model.ID)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("directory for %s already exists", model.ID)
+	}
+
+	result := &BundleImport{Schedules: []models.Schedule{}, Warnings: []string{}}
+	fail := func(err error) (*BundleImport, error) {
+		os.RemoveAll(dir)
+		releasePorts(model.ID)
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := extractBundleFiles(&zr.Reader, dir); err != nil {
+		return fail(fmt.Errorf("failed to extract bundle: %v", err))
+	}
+	if manifest.Tunnel != nil {
+		data, _ := json.MarshalIndent(manifest.Tunnel, "", "  ")
+		os.WriteFile(filepath.Join(dir, tunnelConfigFile), data, 0644)
+		result.Warnings = append(result.Warnings, "The tunnel token was not exported and has to be entered again")
+	}
+	if manifest.Webhook {
+		result.Warnings = append(result.Warnings, "The webhook URL was not exported and has to be entered again")
+	}
+	if err := renewRCONPassword(dir); err != nil {
+		return fail(fmt.Errorf("failed to set RCON password: %v", err))
+	}
+	if err := im.assignInstancePorts(model.ID, dir, model.Type, false); err != nil {
+		return fail(fmt.Errorf("failed to assign ports: %v", err))
+	}
+	if manifest.TemplateID != "" {
+		if im.TemplateMgr == nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Template %s is not available on this host", manifest.TemplateID))
+		} else if _, ok := im.TemplateMgr.GetTemplate(manifest.TemplateID); !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Template %s is not available on this host", manifest.TemplateID))
+		}
+	}
+
+	if err := createModel(&model); err != nil {
+		return fail(fmt.Errorf("failed to save to db: %v", err))
+	}
+
+	for _, schedule := range manifest.Schedules {
+		schedule.ID = uuid.New().String()
+		schedule.InstanceID = model.ID
+		schedule.LastRun = 0
+		if err := database.DB.Create(&schedule).Error; err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to restore schedule %s: %v", schedule.Name, err))
+			continue
+		}
+		result.Schedules = append(result.Schedules, schedule)
+	}
+
+	result.Instance = im.loadInstance(model)
+	im.instances[model.ID] = result.Instance
+	return result, nil
+}
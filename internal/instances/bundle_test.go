@@ -0,0 +1,124 @@
+package instances
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+	"jjmc/internal/properties"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	useTestDB(t)
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41200, Last: 41300},
+	}
+
+	model := models.InstanceModel{ID: "lobby", Name: "Lobby", Type: "paper", Version: "1.20.4", MaxMemory: 2048, WebhookURL: "https://example.com/hook"}
+	if err := database.DB.Create(&model).Error; err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Create(&models.Schedule{ID: "s1", InstanceID: "lobby", Name: "Nightly", CronExpression: "0 4 * * *", Type: "restart", Enabled: true, LastRun: 1700000000})
+
+	src := filepath.Join(base, "lobby")
+	os.MkdirAll(filepath.Join(src, "world"), 0755)
+	for _, file := range []string{"world/level.dat", "paper.jar", "server.pid"} {
+		os.WriteFile(filepath.Join(src, file), []byte("x"), 0644)
+	}
+	if err := im.assignPorts("lobby", src, true); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(src, tunnelConfigFile), []byte(`{"provider":"playit","token":"tunnel-secret"}`), 0644)
+	im.instances["lobby"] = im.loadInstance(model)
+	props, _ := properties.Read(filepath.Join(src, PropertiesFile))
+	password := props["rcon.password"]
+
+	bundle := filepath.Join(t.TempDir(), "lobby.zip")
+	f, err := os.Create(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := im.ExportBundle("lobby", f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// No credential may end up anywhere in the bundle.
+	zr, err := zip.OpenReader(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range zr.File {
+		rc, _ := entry.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		for _, secret := range []string{"https://example.com/hook", "tunnel-secret", password} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("%s contains %q", entry.Name, secret)
+			}
+		}
+	}
+	zr.Close()
+
+	if _, err := im.ImportBundle(bundle, "", ""); err == nil {
+		t.Error("importing over an existing instance should fail")
+	}
+	result, err := im.ImportBundle(bundle, "lobby2", "Lobby 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst := result.Instance
+	if inst.Name != "Lobby 2" || inst.MaxMemory != 2048 || inst.WebhookURL != "" {
+		t.Errorf("settings not restored: %+v", inst.Instance)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("expected warnings about the tunnel token and webhook, got %v", result.Warnings)
+	}
+	if tunnel := inst.Tunnel.GetStatus().Config; tunnel.Provider != "playit" || tunnel.Token != "" {
+		t.Errorf("unexpected tunnel config %+v", tunnel)
+	}
+	imported, _ := properties.Read(filepath.Join(inst.Directory, PropertiesFile))
+	if imported["enable-rcon"] != "true" || imported["rcon.password"] == "" || imported["rcon.password"] == password {
+		t.Errorf("imported instance needs a new RCON password, got %q", imported["rcon.password"])
+	}
+	if len(result.Schedules) != 1 || result.Schedules[0].ID == "s1" || result.Schedules[0].InstanceID != "lobby2" || result.Schedules[0].LastRun != 0 {
+		t.Errorf("unexpected schedules %+v", result.Schedules)
+	}
+	if _, err := os.Stat(filepath.Join(inst.Directory, "world", "level.dat")); err != nil {
+		t.Error("world not restored")
+	}
+	if _, err := os.Stat(filepath.Join(inst.Directory, "server.pid")); err == nil {
+		t.Error("server.pid should not be exported")
+	}
+	if GetServerPort(inst.Directory) == GetServerPort(src) {
+		t.Error("imported instance shares the server port")
+	}
+}
+
+func TestExtractBundleRejectsEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create(bundleFilesDir + "../evil.txt")
+	w.Write([]byte("x"))
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "target")
+	if err := extractBundleFiles(zr, dir); err == nil {
+		t.Error("expected an error for a path outside the instance")
+	}
+}
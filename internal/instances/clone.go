@@ -0,0 +1,126 @@
+package instances
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+	"jjmc/internal/properties"
+)
+
+type CloneOptions struct {
+	ID            string // Generated from the source ID if empty
+	Name          string // "<source name> (copy)" if empty
+	IncludeWorlds bool
+	IncludeMods   bool // mods/ and plugins/
+}
+
+var instanceIDRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// runtimeFiles belong to the running server of an instance alone and are
+// left behind by clones and exports.
+var runtimeFiles = map[string]bool{
+	"server.pid":          true,
+	"console.in":          true,
+	"server.log":          true,
+	manager.StderrLogFile: true,
+	manager.LogArchiveDir: true,
+}
+
+// worldDirs returns the world folders of the server in dir, including the
+// separate Nether and End folders Bukkit-based servers use.
+func worldDirs(dir string) []string {
+	level := "world"
+	if props, err := properties.Read(filepath.Join(dir, PropertiesFile)); err == nil && props["level-name"] != "" {
+		level = props["level-name"]
+	}
+	return []string{level, level + "_nether", level + "_the_end"}
+}
+
+// CloneInstance copies an instance, its files and its settings, into a new
+// one with ports of its own.
+func (im *InstanceManager) CloneInstance(sourceID string, opts CloneOptions) (*Instance, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	source, ok := im.instances[sourceID]
+	if !ok {
+		return nil, fmt.Errorf("instance not found")
+	}
+
+	id := opts.ID
+	if id == "" {
+		id = sourceID + "-copy"
+		for i := 2; im.instances[id] != nil; i++ {
+			id = fmt.Sprintf("%s-copy-%d", sourceID, i)
+		}
+	}
+	if !instanceIDRe.MatchString(id) {
+		return nil, fmt.Errorf("invalid instance id: %s", id)
+	}
+	if _, exists := im.instances[id]; exists {
+		return nil, fmt.Errorf("instance with id %s already exists", id)
+	}
+	name := opts.Name
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+
+	var model models.InstanceModel
+	if err := database.DB.First(&model, "id = ?", sourceID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load instance: %v", err)
+	}
+
+	skipped := make(map[string]bool)
+	if !opts.IncludeWorlds {
+		for _, world := range worldDirs(source.Directory) {
+			skipped[world] = true
+		}
+	}
+	if !opts.IncludeMods {
+		skipped["mods"] = true
+		skipped["plugins"] = true
+	}
+
+	dir := filepath.Join(im.baseDir, id)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("directory for %s already exists", id)
+	}
+	err := copyTree(source.Directory, dir, func(rel string, info os.FileInfo) bool {
+		return runtimeFiles[rel] || skipped[rel]
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to copy files: %v", err)
+	}
+
+	if err := im.assignInstancePorts(id, dir, source.Type, false); err != nil {
+		os.RemoveAll(dir)
+		releasePorts(id)
+		return nil, fmt.Errorf("failed to assign ports: %v", err)
+	}
+
+	model.ID = id
+	model.Name = name
+	model.CreatedAt = time.Now().Unix()
+	if err := createModel(&model); err != nil {
+		os.RemoveAll(dir)
+		releasePorts(id)
+		return nil, fmt.Errorf("failed to save to db: %v", err)
+	}
+
+	instance := im.loadInstance(model)
+	im.instances[id] = instance
+	return instance, nil
+}
+
+// createModel inserts a copied instance row as is. A plain Create would
+// replace zero values, such as a disabled log rotation, with column defaults.
+func createModel(model *models.InstanceModel) error {
+	return database.DB.Select("*").Create(model).Error
+}
@@ -0,0 +1,74 @@
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+	"jjmc/internal/properties"
+)
+
+func TestCloneInstance(t *testing.T) {
+	useTestDB(t)
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41000, Last: 41100},
+	}
+
+	model := models.InstanceModel{ID: "survival", Name: "Survival", Type: "paper", Version: "1.20.4", MaxMemory: 4096, JavaArgs: "-XX:+UseG1GC", StartCommand: "java -jar paper.jar"}
+	if err := database.DB.Create(&model).Error; err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(base, "survival")
+	for _, dir := range []string{"world/region", "plugins", "config"} {
+		os.MkdirAll(filepath.Join(src, dir), 0755)
+	}
+	for _, file := range []string{"world/level.dat", "plugins/Essentials.jar", "config/paper.yml", "server.pid", "server.log", "paper.jar"} {
+		os.WriteFile(filepath.Join(src, file), []byte("x"), 0644)
+	}
+	if err := im.assignPorts("survival", src, true); err != nil {
+		t.Fatal(err)
+	}
+	im.instances["survival"] = im.loadInstance(model)
+
+	clone, err := im.CloneInstance("survival", CloneOptions{IncludeMods: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.ID != "survival-copy" || clone.Name != "Survival (copy)" {
+		t.Errorf("unexpected id/name %s/%s", clone.ID, clone.Name)
+	}
+	if clone.MaxMemory != 4096 || clone.JavaArgs != "-XX:+UseG1GC" || clone.StartCommand != "java -jar paper.jar" {
+		t.Errorf("settings not copied: %+v", clone.Instance)
+	}
+
+	for file, want := range map[string]bool{
+		"paper.jar":              true,
+		"config/paper.yml":       true,
+		"plugins/Essentials.jar": true,
+		"world/level.dat":        false,
+		"server.pid":             false,
+		"server.log":             false,
+	} {
+		_, err := os.Stat(filepath.Join(clone.Directory, file))
+		if (err == nil) != want {
+			t.Errorf("%s copied: %v, want %v", file, err == nil, want)
+		}
+	}
+
+	srcPort := GetServerPort(src)
+	if port := GetServerPort(clone.Directory); port == srcPort {
+		t.Errorf("clone shares server port %d with the source", port)
+	}
+	props, _ := properties.Read(filepath.Join(clone.Directory, PropertiesFile))
+	srcProps, _ := properties.Read(filepath.Join(src, PropertiesFile))
+	if props["rcon.port"] == srcProps["rcon.port"] {
+		t.Errorf("clone shares the RCON port %s", props["rcon.port"])
+	}
+}
@@ -0,0 +1,89 @@
+package instances
+
+import (
+	"fmt"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+)
+
+// Defaults for new instances, mirrored by the gorm tags on InstanceModel.
+const (
+	defaultLogMaxSizeMB   = 100
+	defaultLogMaxAgeHours = 24
+	defaultLogRetention   = 14
+)
+
+func applyConsoleDefaults(inst *models.Instance) {
+	inst.ScrollbackLines = manager.DefaultScrollbackSize
+	inst.LogMaxSizeMB = defaultLogMaxSizeMB
+	inst.LogMaxAgeHours = defaultLogMaxAgeHours
+	inst.LogRetention = defaultLogRetention
+}
+
+func (inst *Instance) applyConsoleSettings() {
+	if inst.ScrollbackLines <= 0 {
+		inst.ScrollbackLines = manager.DefaultScrollbackSize
+	}
+	inst.Manager.SetScrollbackSize(inst.ScrollbackLines)
+	inst.Manager.SetLogRotation(manager.LogRotation{
+		MaxSize:   int64(inst.LogMaxSizeMB) * 1024 * 1024,
+		MaxAge:    time.Duration(inst.LogMaxAgeHours) * time.Hour,
+		Retention: inst.LogRetention,
+	})
+}
+
+func (im *InstanceManager) UpdateConsoleSettings(id string, scrollbackLines int) error {
+	if scrollbackLines <= 0 || scrollbackLines > 100000 {
+		return fmt.Errorf("scrollback must be between 1 and 100000 lines")
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+
+	if err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Update("scrollback_lines", scrollbackLines).Error; err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+
+	inst.ScrollbackLines = scrollbackLines
+	inst.applyConsoleSettings()
+
+	return nil
+}
+
+func (im *InstanceManager) UpdateLogRotation(id string, maxSizeMB, maxAgeHours, retention int) error {
+	if maxSizeMB < 0 || maxAgeHours < 0 || retention < 0 {
+		return fmt.Errorf("log rotation limits must not be negative")
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+
+	err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"log_max_size_mb":   maxSizeMB,
+		"log_max_age_hours": maxAgeHours,
+		"log_retention":     retention,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+
+	inst.LogMaxSizeMB = maxSizeMB
+	inst.LogMaxAgeHours = maxAgeHours
+	inst.LogRetention = retention
+	inst.applyConsoleSettings()
+
+	return nil
+}
@@ -1,10 +1,12 @@
 package instances
 
 import (
+	"archive/zip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"jjmc/internal/database"
@@ -31,6 +33,8 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 				CreatedAt:    time.Now().Unix(),
 				MaxMemory:    2048,
 				StartCommand: tmpl.Run.Command,
+				StopCommand:  tmpl.Run.Stop,
+				StopTimeout:  defaultStopTimeout,
 			}
 			if err := database.DB.Create(&model).Error; err != nil {
 				return nil, fmt.Errorf("failed to save to db: %v", err)
@@ -41,6 +45,9 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 				return nil, err
 			}
 			os.WriteFile(filepath.Join(dir, "eula.txt"), []byte("eula=true"), 0644)
+			if err := im.assignInstancePorts(id, dir, serverType, true); err != nil {
+				fmt.Printf("Failed to assign ports for %s: %v\n", id, err)
+			}
 
 			mgr := manager.NewManager()
 			mgr.SetSilent(im.silent)
@@ -54,6 +61,8 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 					MaxMemory:    2048,
 					JarFile:      "server.jar",
 					StartCommand: tmpl.Run.Command,
+					StopCommand:  tmpl.Run.Stop,
+					StopTimeout:  defaultStopTimeout,
 				},
 				Manager: mgr,
 				Tunnel:  NewTunnelManager(dir),
@@ -64,6 +73,12 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 				instance.Manager.SetStartCommand(tmpl.Run.Command)
 			}
 			instance.Manager.SetMaxMemory(2048)
+			instance.applyStopSettings()
+			applyConsoleDefaults(instance.Instance)
+			instance.applyConsoleSettings()
+			applyRestartDefaults(instance.Instance)
+			instance.applyRestartPolicy()
+			im.watch(instance)
 
 			im.instances[id] = instance
 
@@ -84,16 +99,21 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 	}
 
 	os.WriteFile(filepath.Join(dir, "eula.txt"), []byte("eula=true"), 0644)
+	if err := im.assignInstancePorts(id, dir, serverType, true); err != nil {
+		fmt.Printf("Failed to assign ports for %s: %v\n", id, err)
+	}
 
 	model := models.InstanceModel{
-		ID:        id,
-		Name:      name,
-		Type:      serverType,
-		Version:   version,
-		CreatedAt: time.Now().Unix(),
-		MaxMemory: 2048,
+		ID:          id,
+		Name:        name,
+		Type:        serverType,
+		Version:     version,
+		CreatedAt:   time.Now().Unix(),
+		MaxMemory:   2048,
+		StopTimeout: defaultStopTimeout,
 	}
 	if err := database.DB.Create(&model).Error; err != nil {
+		releasePorts(id)
 		return nil, fmt.Errorf("failed to save to db: %v", err)
 	}
 
@@ -101,13 +121,14 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 	mgr.SetSilent(im.silent)
 	instance := &Instance{
 		Instance: &models.Instance{
-			ID:        id,
-			Name:      name,
-			Directory: dir,
-			Type:      serverType,
-			Version:   version,
-			MaxMemory: 2048,
-			JarFile:   "server.jar",
+			ID:          id,
+			Name:        name,
+			Directory:   dir,
+			Type:        serverType,
+			Version:     version,
+			MaxMemory:   2048,
+			JarFile:     "server.jar",
+			StopTimeout: defaultStopTimeout,
 		},
 		Manager: mgr,
 		Tunnel:  NewTunnelManager(dir),
@@ -116,6 +137,12 @@ func (im *InstanceManager) CreateInstance(id, name, serverType, version string)
 	instance.Manager.SetWorkDir(dir)
 	instance.Manager.SetJar("server.jar")
 	instance.Manager.SetMaxMemory(2048)
+	instance.applyStopSettings()
+	applyConsoleDefaults(instance.Instance)
+	instance.applyConsoleSettings()
+	applyRestartDefaults(instance.Instance)
+	instance.applyRestartPolicy()
+	im.watch(instance)
 
 	im.instances[id] = instance
 	return instance, nil
@@ -139,7 +166,9 @@ func (im *InstanceManager) DeleteInstance(id string) error {
 	}
 
 	os.RemoveAll(inst.Directory)
+	releasePorts(id)
 	delete(im.instances, id)
+	inst.Manager.Close()
 	return nil
 }
 
@@ -165,16 +194,93 @@ func (im *InstanceManager) ImportInstance(id, name, sourcePath string) (*Instanc
 	if err := copyDir(sourcePath, dir); err != nil {
 		return nil, fmt.Errorf("failed to copy files: %v", err)
 	}
+	return im.registerImport(id, name, dir)
+}
+
+// ImportArchive creates an instance from a zip of a server folder. Zips
+// holding a single top-level folder are unpacked from inside it.
+func (im *InstanceManager) ImportArchive(id, name, archivePath string) (*Instance, error) {
+	if !instanceIDRe.MatchString(id) {
+		return nil, fmt.Errorf("invalid instance id: %s", id)
+	}
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer zr.Close()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if _, exists := im.instances[id]; exists {
+		return nil, fmt.Errorf("instance with id %s already exists", id)
+	}
+	dir := filepath.Join(im.baseDir, id)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("directory for %s already exists", id)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := extractZip(&zr.Reader, dir, archiveRoot(&zr.Reader)); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to extract archive: %v", err)
+	}
 
+	inst, err := im.registerImport(id, name, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+	}
+	return inst, err
+}
+
+// archiveRoot returns the folder every entry of the zip is in, if there is
+// exactly one, such as "survival/".
+func archiveRoot(r *zip.Reader) string {
+	root := ""
+	for _, f := range r.File {
+		top, _, nested := strings.Cut(f.Name, "/")
+		if !nested || top == "" || top == "__MACOSX" {
+			if !nested {
+				return ""
+			}
+			continue
+		}
+		if root != "" && root != top {
+			return ""
+		}
+		root = top
+	}
+	if root == "" {
+		return ""
+	}
+	return root + "/"
+}
+
+// registerImport saves and loads a server copied into dir, detecting its
+// type, version and jar. Callers must hold im.mu.
+func (im *InstanceManager) registerImport(id, name, dir string) (*Instance, error) {
+	detected := DetectServer(dir)
+	// Imported servers keep their ports unless another instance has them.
+	if err := im.assignInstancePorts(id, dir, detected.Type, false); err != nil {
+		fmt.Printf("Failed to assign ports for %s: %v\n", id, err)
+	}
 	model := models.InstanceModel{
-		ID:        id,
-		Name:      name,
-		Type:      "custom",
-		Version:   "imported",
-		CreatedAt: time.Now().Unix(),
-		MaxMemory: 2048,
+		ID:           id,
+		Name:         name,
+		Type:         detected.Type,
+		Version:      detected.Version,
+		JarFile:      detected.JarFile,
+		StartCommand: detected.StartCommand,
+		CreatedAt:    time.Now().Unix(),
+		MaxMemory:    2048,
+		StopTimeout:  defaultStopTimeout,
 	}
+	model.StopCommand = im.stopCommandFor(model)
 	if err := database.DB.Create(&model).Error; err != nil {
+		releasePorts(id)
 		return nil, fmt.Errorf("failed to save to db: %v", err)
 	}
 
@@ -182,36 +288,46 @@ func (im *InstanceManager) ImportInstance(id, name, sourcePath string) (*Instanc
 	mgr.SetSilent(im.silent)
 	instance := &Instance{
 		Instance: &models.Instance{
-			ID:        id,
-			Name:      name,
-			Directory: dir,
-			Type:      "custom",
-			Version:   "imported",
-			MaxMemory: 2048,
+			ID:           id,
+			Name:         name,
+			Directory:    dir,
+			Type:         detected.Type,
+			Version:      detected.Version,
+			JarFile:      detected.JarFile,
+			StartCommand: detected.StartCommand,
+			StopCommand:  model.StopCommand,
+			MaxMemory:    2048,
+			StopTimeout:  defaultStopTimeout,
 		},
 		Manager: mgr,
 		Tunnel:  NewTunnelManager(dir),
 	}
 
 	instance.Manager.SetWorkDir(dir)
-
-	jars, _ := filepath.Glob(filepath.Join(dir, "*.jar"))
-	jarName := "server.jar"
-	if len(jars) > 0 {
-		jarName = filepath.Base(jars[0])
+	instance.Manager.SetJar(detected.JarFile)
+	if detected.StartCommand != "" {
+		instance.Manager.SetStartCommand(detected.StartCommand)
 	}
-	instance.Manager.SetJar(jarName)
-	instance.JarFile = jarName
-
-	database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Update("jar_file", jarName)
-
 	instance.Manager.SetMaxMemory(2048)
+	instance.Manager.SetInstanceInfo(id, name, detected.Type, detected.Version)
+	instance.applyStopSettings()
+	applyConsoleDefaults(instance.Instance)
+	instance.applyConsoleSettings()
+	applyRestartDefaults(instance.Instance)
+	instance.applyRestartPolicy()
+	im.watch(instance)
 
 	im.instances[id] = instance
 	return instance, nil
 }
 
 func copyDir(src, dst string) error {
+	return copyTree(src, dst, nil)
+}
+
+// copyTree copies src into dst, leaving out the paths, relative to src, for
+// which skip returns true.
+func copyTree(src, dst string, skip func(rel string, info os.FileInfo) bool) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -222,9 +338,20 @@ func copyDir(src, dst string) error {
 		}
 		destPath := filepath.Join(dst, relPath)
 
+		if skip != nil && relPath != "." && skip(filepath.ToSlash(relPath), info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		if info.IsDir() {
 			return os.MkdirAll(destPath, info.Mode())
 		}
+		// Skip console.in and other special files.
+		if !info.Mode().IsRegular() {
+			return nil
+		}
 
 		srcFile, err := os.Open(path)
 		if err != nil {
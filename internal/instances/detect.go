@@ -0,0 +1,314 @@
+package instances
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"jjmc/internal/properties"
+)
+
+// Detection is what DetectServer found out about a server folder.
+type Detection struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	JarFile      string `json:"jarFile"`
+	StartCommand string `json:"startCommand,omitempty"`
+	Source       string `json:"source"` // What the type was recognized by
+}
+
+// jarInfo is what a server jar says about itself.
+type jarInfo struct {
+	Name     string
+	Type     string
+	Version  string // Minecraft version
+	Manifest map[string]string
+}
+
+var (
+	mcVersionRe = regexp.MustCompile(`\(MC: ([^)]+)\)`)
+	releaseRe   = regexp.MustCompile(`^\d+\.\d+(\.\d+)?`)
+)
+
+// jarMainClasses maps the main class of a jar to the server type it starts.
+var jarMainClasses = []struct {
+	prefix, typ string
+}{
+	{"com.velocitypowered.", "velocity"},
+	{"net.md_5.bungee.", "bungeecord"},
+	{"io.papermc.paperclip.", "paper"},
+	{"org.bukkit.craftbukkit.", "spigot"},
+	{"net.fabricmc.", "fabric"},
+	{"org.quiltmc.", "quilt"},
+	{"net.neoforged.", "neoforge"},
+	{"net.minecraftforge.", "forge"},
+	{"cpw.mods.", "forge"},
+	{"net.minecraft.", "vanilla"},
+}
+
+// readManifest parses META-INF/MANIFEST.MF, joining continuation lines.
+func readManifest(r io.Reader) map[string]string {
+	manifest := map[string]string{}
+	var last string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") && last != "" {
+			manifest[last] += line[1:]
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			last = ""
+			continue
+		}
+		last = strings.TrimSpace(key)
+		manifest[last] = strings.TrimSpace(value)
+	}
+	return manifest
+}
+
+func inspectJar(path string) (*jarInfo, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	info := &jarInfo{Name: filepath.Base(path), Manifest: map[string]string{}}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "META-INF/MANIFEST.MF":
+			if rc, err := f.Open(); err == nil {
+				info.Manifest = readManifest(rc)
+				rc.Close()
+			}
+		case "version.json":
+			// Vanilla jars, and the Paper launcher which wraps one.
+			if rc, err := f.Open(); err == nil {
+				var v struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				}
+				if json.NewDecoder(rc).Decode(&v) == nil {
+					info.Version = v.ID
+					if info.Version == "" {
+						info.Version = v.Name
+					}
+				}
+				rc.Close()
+			}
+		}
+	}
+
+	main := info.Manifest["Main-Class"]
+	for _, m := range jarMainClasses {
+		if strings.HasPrefix(main, m.prefix) {
+			info.Type = m.typ
+			break
+		}
+	}
+
+	title := strings.ToLower(info.Manifest["Implementation-Title"] + " " + info.Manifest["Implementation-Version"])
+	switch {
+	case info.Type == "bungeecord" && strings.Contains(title, "waterfall"):
+		info.Type = "waterfall"
+	case info.Type == "spigot" && strings.Contains(title, "paper"):
+		info.Type = "paper"
+	case info.Type == "spigot" && !strings.Contains(title, "spigot") && strings.Contains(title, "bukkit"):
+		info.Type = "bukkit"
+	}
+
+	if info.Version == "" {
+		if m := mcVersionRe.FindStringSubmatch(info.Manifest["Implementation-Version"]); m != nil {
+			info.Version = m[1]
+		}
+	}
+	if info.Type == "velocity" || info.Type == "bungeecord" || info.Type == "waterfall" {
+		// Proxies have their own version rather than a Minecraft one.
+		if v := strings.Fields(info.Manifest["Implementation-Version"]); len(v) > 0 {
+			info.Version = v[0]
+		}
+	}
+	return info, nil
+}
+
+// subdirs returns the names of the folders in dir, sorted.
+func subdirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// librariesVersion finds the Minecraft version in the libraries folder that
+// Forge and NeoForge install, where folders are named like 1.20.4-20231207.
+func librariesVersion(dir string) string {
+	versions := subdirs(filepath.Join(dir, "libraries", "net", "minecraft", "server"))
+	if len(versions) == 0 {
+		return ""
+	}
+	v, _, _ := strings.Cut(versions[len(versions)-1], "-")
+	return v
+}
+
+// neoForgeMinecraftVersion turns a NeoForge version such as 20.4.80 into the
+// Minecraft version it is for, 1.20.4.
+func neoForgeMinecraftVersion(v string) string {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	if parts[1] == "0" {
+		return "1." + parts[0]
+	}
+	return "1." + parts[0] + "." + parts[1]
+}
+
+// DetectServer works out the server type, Minecraft version and jar of an
+// existing server folder. It returns type "custom" when nothing is recognized.
+func DetectServer(dir string) Detection {
+	var jars []*jarInfo
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.jar"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		name := strings.ToLower(filepath.Base(path))
+		if strings.Contains(name, "installer") {
+			continue
+		}
+		if info, err := inspectJar(path); err == nil {
+			jars = append(jars, info)
+		}
+	}
+	jarOf := func(types ...string) *jarInfo {
+		for _, j := range jars {
+			for _, t := range types {
+				if j.Type == t {
+					return j
+				}
+			}
+		}
+		return nil
+	}
+
+	d := Detection{}
+	var jar *jarInfo
+	libs := filepath.Join(dir, "libraries")
+
+	switch {
+	case fileExists(filepath.Join(dir, "velocity.toml")):
+		d.Type, d.Source = "velocity", "velocity.toml"
+		jar = jarOf("velocity")
+
+	case len(subdirs(filepath.Join(libs, "net", "neoforged", "neoforge"))) > 0,
+		len(subdirs(filepath.Join(libs, "net", "neoforged", "forge"))) > 0:
+		d.Type, d.Source = "neoforge", "libraries/net/neoforged"
+		jar = jarOf("neoforge")
+		d.Version = librariesVersion(dir)
+		if d.Version == "" {
+			if versions := subdirs(filepath.Join(libs, "net", "neoforged", "neoforge")); len(versions) > 0 {
+				d.Version = neoForgeMinecraftVersion(versions[len(versions)-1])
+			}
+		}
+
+	case len(subdirs(filepath.Join(libs, "net", "minecraftforge", "forge"))) > 0:
+		d.Type, d.Source = "forge", "libraries/net/minecraftforge"
+		jar = jarOf("forge")
+		d.Version = librariesVersion(dir)
+		if d.Version == "" {
+			versions := subdirs(filepath.Join(libs, "net", "minecraftforge", "forge"))
+			d.Version, _, _ = strings.Cut(versions[len(versions)-1], "-")
+		}
+
+	case fileExists(filepath.Join(dir, "fabric-server-launcher.properties")):
+		d.Type, d.Source = "fabric", "fabric-server-launcher.properties"
+		jar = jarOf("fabric")
+		// The launcher starts the vanilla jar named in its properties.
+		launcher, _ := properties.Read(filepath.Join(dir, "fabric-server-launcher.properties"))
+		serverJar := launcher["serverJar"]
+		if serverJar == "" {
+			serverJar = "server.jar"
+		}
+		if info, err := inspectJar(filepath.Join(dir, serverJar)); err == nil {
+			d.Version = info.Version
+		}
+
+	case fileExists(filepath.Join(dir, "config", "paper-global.yml")):
+		d.Type, d.Source = "paper", "config/paper-global.yml"
+		jar = jarOf("paper", "spigot")
+
+	case fileExists(filepath.Join(dir, "paper.yml")):
+		d.Type, d.Source = "paper", "paper.yml"
+		jar = jarOf("paper", "spigot")
+
+	case fileExists(filepath.Join(dir, "spigot.yml")):
+		d.Type, d.Source = "spigot", "spigot.yml"
+		jar = jarOf("spigot", "paper")
+
+	default:
+		for _, t := range []string{"velocity", "waterfall", "bungeecord", "paper", "spigot", "bukkit", "neoforge", "forge", "quilt", "fabric", "vanilla"} {
+			if jar = jarOf(t); jar != nil {
+				d.Type, d.Source = t, jar.Name
+				break
+			}
+		}
+	}
+
+	if d.Type == "" {
+		d.Type, d.Source = "custom", ""
+		if len(jars) > 0 {
+			jar = jars[0]
+		}
+	}
+	if jar != nil {
+		d.JarFile = jar.Name
+		if d.Version == "" {
+			d.Version = jar.Version
+		}
+	}
+	if d.Version == "" && d.Type != "velocity" && d.Type != "waterfall" && d.Type != "bungeecord" {
+		// Paper keeps the vanilla jar it patched in versions/<version>.
+		for _, v := range subdirs(filepath.Join(dir, "versions")) {
+			if releaseRe.MatchString(v) {
+				d.Version = v
+			}
+		}
+		if d.Version == "" {
+			if vanilla := jarOf("vanilla"); vanilla != nil {
+				d.Version = vanilla.Version
+			}
+		}
+	}
+
+	// Forge and NeoForge from 1.17 on have no server jar and start through
+	// the script their installer writes, like the templates do.
+	if d.JarFile == "" && (d.Type == "forge" || d.Type == "neoforge") && fileExists(filepath.Join(dir, "run.sh")) {
+		d.StartCommand = "./run.sh"
+	}
+	if d.JarFile == "" {
+		d.JarFile = "server.jar"
+	}
+	if d.Version == "" {
+		d.Version = "imported"
+	}
+	return d
+}
@@ -0,0 +1,127 @@
+package instances
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func manifest(mainClass, extra string) string {
+	return "Manifest-Version: 1.0\r\nMain-Class: " + mainClass + "\r\n" + extra
+}
+
+func TestDetectServer(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(dir string)
+		want  Detection
+	}{
+		{
+			name: "vanilla",
+			setup: func(dir string) {
+				writeJar(t, filepath.Join(dir, "minecraft_server.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("net.minecraft.bundler.Main", ""),
+					"version.json":         `{"id": "1.20.4", "name": "1.20.4"}`,
+				})
+			},
+			want: Detection{Type: "vanilla", Version: "1.20.4", JarFile: "minecraft_server.jar"},
+		},
+		{
+			name: "paper",
+			setup: func(dir string) {
+				os.MkdirAll(filepath.Join(dir, "config"), 0755)
+				os.WriteFile(filepath.Join(dir, "config", "paper-global.yml"), nil, 0644)
+				writeJar(t, filepath.Join(dir, "paper-1.20.4-400.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("io.papermc.paperclip.Main", ""),
+					"version.json":         `{"id": "1.20.4"}`,
+				})
+			},
+			want: Detection{Type: "paper", Version: "1.20.4", JarFile: "paper-1.20.4-400.jar"},
+		},
+		{
+			name: "spigot from manifest",
+			setup: func(dir string) {
+				writeJar(t, filepath.Join(dir, "spigot.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("org.bukkit.craftbukkit.Main", "Implementation-Title: CraftBukkit\r\nImplementation-Version: 3871-Spigot-d2eba2c-3f9263b\r\n (MC: 1.19.4)\r\n"),
+				})
+			},
+			want: Detection{Type: "spigot", Version: "1.19.4", JarFile: "spigot.jar"},
+		},
+		{
+			name: "fabric",
+			setup: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "fabric-server-launcher.properties"), []byte("serverJar=vanilla.jar\n"), 0644)
+				writeJar(t, filepath.Join(dir, "fabric-server-launch.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("net.fabricmc.loader.impl.launch.server.FabricServerLauncher", ""),
+				})
+				writeJar(t, filepath.Join(dir, "vanilla.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("net.minecraft.bundler.Main", ""),
+					"version.json":         `{"id": "1.21.1"}`,
+				})
+			},
+			want: Detection{Type: "fabric", Version: "1.21.1", JarFile: "fabric-server-launch.jar"},
+		},
+		{
+			name: "forge with run script",
+			setup: func(dir string) {
+				os.MkdirAll(filepath.Join(dir, "libraries", "net", "minecraftforge", "forge", "1.20.1-47.2.0"), 0755)
+				os.MkdirAll(filepath.Join(dir, "libraries", "net", "minecraft", "server", "1.20.1-20230612.114412"), 0755)
+				os.WriteFile(filepath.Join(dir, "run.sh"), nil, 0755)
+				writeJar(t, filepath.Join(dir, "forge-1.20.1-47.2.0-installer.jar"), map[string]string{})
+			},
+			want: Detection{Type: "forge", Version: "1.20.1", JarFile: "server.jar", StartCommand: "./run.sh"},
+		},
+		{
+			name: "neoforge",
+			setup: func(dir string) {
+				os.MkdirAll(filepath.Join(dir, "libraries", "net", "neoforged", "neoforge", "21.1.65"), 0755)
+			},
+			want: Detection{Type: "neoforge", Version: "1.21.1", JarFile: "server.jar"},
+		},
+		{
+			name: "velocity",
+			setup: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "velocity.toml"), nil, 0644)
+				writeJar(t, filepath.Join(dir, "velocity.jar"), map[string]string{
+					"META-INF/MANIFEST.MF": manifest("com.velocitypowered.proxy.Velocity", "Implementation-Title: Velocity\r\nImplementation-Version: 3.3.0-SNAPSHOT (git-b9a7d40d-b400)\r\n"),
+				})
+			},
+			want: Detection{Type: "velocity", Version: "3.3.0-SNAPSHOT", JarFile: "velocity.jar"},
+		},
+		{
+			name:  "unknown",
+			setup: func(dir string) { os.WriteFile(filepath.Join(dir, "start.sh"), nil, 0755) },
+			want:  Detection{Type: "custom", Version: "imported", JarFile: "server.jar"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(dir)
+			got := DetectServer(dir)
+			got.Source = ""
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package instances
 
 import (
+	"sync"
+
 	"jjmc/internal/manager"
 	"jjmc/internal/models"
 )
@@ -9,6 +11,10 @@ type Instance struct {
 	*models.Instance
 	Manager *manager.Manager `json:"-"`
 	Tunnel  *TunnelManager   `json:"-"`
+
+	health   healthState
+	backupMu sync.Mutex // One backup at a time, so saving is resumed only after the last one
+	accessMu sync.Mutex // Guards edits of the player list files
 }
 
 func NewInstance(base *models.Instance, mgr *manager.Manager) *Instance {
@@ -0,0 +1,136 @@
+package instances
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"jjmc/internal/manager"
+)
+
+const (
+	// LogSourceJJMC covers server.log, the stderr log and the archives JJMC
+	// rotates them into.
+	LogSourceJJMC = "jjmc"
+	// LogSourceServer covers the server's own logs/ folder.
+	LogSourceServer = "server"
+)
+
+type LogFile struct {
+	Source     string    `json:"source"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+	Compressed bool      `json:"compressed"`
+}
+
+func (inst *Instance) logDir(source string) (string, error) {
+	switch source {
+	case LogSourceJJMC:
+		return filepath.Join(inst.Directory, manager.LogArchiveDir), nil
+	case LogSourceServer:
+		return filepath.Join(inst.Directory, "logs"), nil
+	}
+	return "", fmt.Errorf("unknown log source: %s", source)
+}
+
+// ListLogs returns the current server.log and stderr log, JJMC's rotated
+// archives and the server's own log files, newest first.
+func (inst *Instance) ListLogs() ([]LogFile, error) {
+	logs := []LogFile{}
+
+	for _, name := range []string{"server.log", manager.StderrLogFile} {
+		if info, err := os.Stat(filepath.Join(inst.Directory, name)); err == nil {
+			logs = append(logs, LogFile{
+				Source:     LogSourceJJMC,
+				Name:       name,
+				Size:       info.Size(),
+				ModifiedAt: info.ModTime(),
+			})
+		}
+	}
+
+	for _, source := range []string{LogSourceJJMC, LogSourceServer} {
+		dir, _ := inst.logDir(source)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			logs = append(logs, LogFile{
+				Source:     source,
+				Name:       name,
+				Size:       info.Size(),
+				ModifiedAt: info.ModTime(),
+				Compressed: strings.HasSuffix(name, ".gz"),
+			})
+		}
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].ModifiedAt.After(logs[j].ModifiedAt)
+	})
+
+	return logs, nil
+}
+
+// OpenLog opens a log file from ListLogs for reading, decompressing gzip
+// archives on the fly.
+func (inst *Instance) OpenLog(source, name string) (io.ReadCloser, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid log name")
+	}
+	if !(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+		return nil, fmt.Errorf("not a log file: %s", name)
+	}
+
+	var path string
+	if source == LogSourceJJMC && (name == "server.log" || name == manager.StderrLogFile) {
+		path = filepath.Join(inst.Directory, name)
+	} else {
+		dir, err := inst.logDir(source)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, name)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(name, ".gz") {
+		return file, nil
+	}
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: zr, file: file}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
@@ -7,11 +7,11 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"time"
 
 	"jjmc/internal/database"
 	"jjmc/internal/manager"
 	"jjmc/internal/models"
+	"jjmc/internal/players"
 	"jjmc/internal/services"
 )
 
@@ -20,7 +20,13 @@ type InstanceManager struct {
 	baseDir     string
 	mu          sync.RWMutex
 	TemplateMgr *services.TemplateManager
+	Players     *players.Tracker
 	silent      bool
+
+	sharedMu    sync.Mutex
+	sharedQueue chan func()
+
+	portRange PortRange
 }
 
 func NewInstanceManager(baseDir string, tm *services.TemplateManager, silent bool) *InstanceManager {
@@ -31,7 +37,10 @@ func NewInstanceManager(baseDir string, tm *services.TemplateManager, silent boo
 		instances:   make(map[string]*Instance),
 		baseDir:     baseDir,
 		TemplateMgr: tm,
+		Players:     players.NewTracker(database.DB),
 		silent:      silent,
+		sharedQueue: make(chan func(), sharedQueueSize),
+		portRange:   DefaultPortRange,
 	}
 
 	var dbModels []models.InstanceModel
@@ -41,44 +50,96 @@ func NewInstanceManager(baseDir string, tm *services.TemplateManager, silent boo
 	}
 
 	for _, model := range dbModels {
-		dir := filepath.Join(baseDir, model.ID)
-		mgr := manager.NewManager()
-		mgr.SetSilent(silent)
-
-		instModel := model
-		instance := NewInstance(&models.Instance{
-			ID:         instModel.ID,
-			Name:       instModel.Name,
-			Directory:  dir,
-			Type:       instModel.Type,
-			Version:    instModel.Version,
-			MaxMemory:  instModel.MaxMemory,
-			JavaArgs:   instModel.JavaArgs,
-			JarFile:    instModel.JarFile,
-			JavaPath:   instModel.JavaPath,
-			WebhookURL: instModel.WebhookURL,
-			Group:      instModel.Group,
-			FolderID:   instModel.FolderID,
-		}, mgr)
-
-		instance.Manager.SetWorkDir(dir)
-		if model.JarFile != "" {
-			instance.Manager.SetJar(model.JarFile)
-		} else {
-			instance.Manager.SetJar("server.jar")
-		}
-		instance.Manager.SetMaxMemory(model.MaxMemory)
-		instance.Manager.SetJavaArgs(model.JavaArgs)
-		instance.Manager.SetJavaPath(model.JavaPath)
-		instance.Manager.SetWebhookURL(model.WebhookURL)
-		instance.Manager.SetInstanceInfo(model.ID, model.Name, model.Type, model.Version)
-
+		instance := im.loadInstance(model)
+		recordPorts(model.ID, instance.Directory, model.Type)
 		im.instances[model.ID] = instance
+		if instance.Manager.GetState().IsActive() {
+			go im.reconcilePlayers(instance)
+		}
 	}
 
+	go im.runHealthProbes()
+	go im.runSharedQueue()
+
 	return im
 }
 
+// loadInstance builds an instance and its manager from its database row.
+func (im *InstanceManager) loadInstance(model models.InstanceModel) *Instance {
+	dir := filepath.Join(im.baseDir, model.ID)
+	mgr := manager.NewManager()
+	mgr.SetSilent(im.silent)
+
+	instModel := model
+	instance := NewInstance(&models.Instance{
+		ID:         instModel.ID,
+		Name:       instModel.Name,
+		Directory:  dir,
+		Type:       instModel.Type,
+		Version:    instModel.Version,
+		MaxMemory:  instModel.MaxMemory,
+		JavaArgs:   instModel.JavaArgs,
+		JarFile:    instModel.JarFile,
+		JavaPath:   instModel.JavaPath,
+		WebhookURL: instModel.WebhookURL,
+		Group:      instModel.Group,
+		FolderID:   instModel.FolderID,
+
+		StartCommand: instModel.StartCommand,
+		StopCommand:  im.stopCommandFor(instModel),
+		StopTimeout:  instModel.StopTimeout,
+
+		ScrollbackLines: instModel.ScrollbackLines,
+		LogMaxSizeMB:    instModel.LogMaxSizeMB,
+		LogMaxAgeHours:  instModel.LogMaxAgeHours,
+		LogRetention:    instModel.LogRetention,
+
+		RestartPolicy:     instModel.RestartPolicy,
+		RestartMaxRetries: instModel.RestartMaxRetries,
+		RestartWindow:     instModel.RestartWindow,
+		RestartBackoff:    instModel.RestartBackoff,
+
+		BackupKeepLast:    instModel.BackupKeepLast,
+		BackupKeepDaily:   instModel.BackupKeepDaily,
+		BackupKeepWeekly:  instModel.BackupKeepWeekly,
+		BackupKeepMonthly: instModel.BackupKeepMonthly,
+		BackupMaxSizeMB:   instModel.BackupMaxSizeMB,
+
+		BackupMode:     instModel.BackupMode,
+		BackupExcludes: decodeExcludes(instModel.BackupExcludes),
+	}, mgr)
+
+	instance.Manager.SetWorkDir(dir)
+	if model.JarFile != "" {
+		instance.Manager.SetJar(model.JarFile)
+	} else {
+		instance.Manager.SetJar("server.jar")
+	}
+	if model.StartCommand != "" {
+		instance.Manager.SetStartCommand(model.StartCommand)
+	}
+	instance.Manager.SetMaxMemory(model.MaxMemory)
+	instance.Manager.SetJavaArgs(model.JavaArgs)
+	instance.Manager.SetJavaPath(model.JavaPath)
+	instance.Manager.SetWebhookURL(model.WebhookURL)
+	instance.Manager.SetInstanceInfo(model.ID, model.Name, model.Type, model.Version)
+	instance.applyRestartPolicy()
+	instance.applyStopSettings()
+	instance.applyConsoleSettings()
+	im.watch(instance)
+	return instance
+}
+
+// watch hooks the per-instance trackers up to a newly loaded or created
+// instance.
+func (im *InstanceManager) watch(inst *Instance) {
+	im.Players.Watch(inst.ID, inst.Manager)
+	im.watchSharedLists(inst)
+	inst.Manager.AddStartCheck(func() error {
+		return im.checkPorts(inst)
+	})
+}
+
 func (im *InstanceManager) GetInstance(id string) (*Instance, error) {
 	im.mu.RLock()
 	defer im.mu.RUnlock()
@@ -88,11 +149,7 @@ func (im *InstanceManager) GetInstance(id string) (*Instance, error) {
 		return nil, fmt.Errorf("instance not found")
 	}
 
-	if inst.Manager.IsRunning() {
-		inst.Status = "Online"
-	} else {
-		inst.Status = "Offline"
-	}
+	inst.Status = string(inst.Manager.GetState())
 
 	return inst, nil
 }
@@ -103,12 +160,7 @@ func (im *InstanceManager) ListInstances() []*Instance {
 
 	list := make([]*Instance, 0, len(im.instances))
 	for _, inst := range im.instances {
-
-		if inst.Manager.IsRunning() {
-			inst.Status = "Online"
-		} else {
-			inst.Status = "Offline"
-		}
+		inst.Status = string(inst.Manager.GetState())
 		list = append(list, inst)
 	}
 
@@ -168,10 +220,9 @@ func (im *InstanceManager) UpdateSettings(id string, maxMemory int, javaArgs, ja
 func (inst *Instance) Reset(serverType, version string) error {
 
 	if inst.Manager.IsRunning() {
-		if err := inst.Manager.Stop(); err != nil {
+		if _, err := inst.Manager.Stop(); err != nil {
 			return fmt.Errorf("failed to stop server: %v", err)
 		}
-		time.Sleep(1 * time.Second)
 	}
 
 	toRemove := []string{
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"jjmc/internal/properties"
 )
 
 func (im *InstanceManager) CreateNetwork(name string, proxyType string, backendType string, backendVersion string) error {
@@ -40,7 +42,6 @@ func (im *InstanceManager) CreateNetwork(name string, proxyType string, backendT
 	if err != nil {
 		return fmt.Errorf("failed to create lobby: %v", err)
 	}
-	// Configure port (e.g. 25566)
 	// Apply forwarding-secret (paper-global.yml or spigot.yml?)
 	// For modern Paper, it's config/paper-global.yml -> velocity -> secret
 
@@ -50,13 +51,6 @@ func (im *InstanceManager) CreateNetwork(name string, proxyType string, backendT
 	if err != nil {
 		return fmt.Errorf("failed to create survival: %v", err)
 	}
-	// Configure port (e.g. 25567)
-
-	// Update ports to avoid conflict.
-	// We need a Port Manager really.
-	// For MVP, let's just pick random ports or increment?
-	// im.CreateInstance defaults to 25565 in server.properties?
-	// We need to edit server.properties.
 
 	// Install ViaVersion, ViaBackwards, ViaRewind
 	// P1OZGk5p = ViaVersion
@@ -68,35 +62,44 @@ func (im *InstanceManager) CreateNetwork(name string, proxyType string, backendT
 	proxyInst.InstallMod("lz8f2WQI", "mod", "") // ViaBackwards
 	proxyInst.InstallMod("5aaWibGx", "mod", "") // ViaRewind
 
-	setupVelocity(proxyInst.Directory, forwardingSecret, []string{lobbyId, survivalId})
-	setupBackend(lobbyInst.Directory, 25566, forwardingSecret, backendVersion)
-	setupBackend(survivalInst.Directory, 25567, forwardingSecret, backendVersion)
+	// The proxy and backends got ports of their own from the allocator.
+	proxyPort, err := readProxyPort(proxyInst.Directory, proxyInst.Type)
+	if err != nil {
+		proxyPort = defaultProxyPort
+	}
+	lobbyPort := GetServerPort(lobbyInst.Directory)
+	survivalPort := GetServerPort(survivalInst.Directory)
+	setupVelocity(proxyInst.Directory, forwardingSecret, proxyPort, lobbyPort, survivalPort)
+	setupBackend(lobbyInst.Directory, forwardingSecret, backendVersion)
+	setupBackend(survivalInst.Directory, forwardingSecret, backendVersion)
 
 	return nil
 }
 
-func setupVelocity(dir string, secret string, backends []string) {
+func setupVelocity(dir string, secret string, port, lobbyPort, survivalPort int) {
 	// Write velocity.toml
 	// This is very simplified. In real app, we'd parse TOML.
-	content := fmt.Sprintf(`
+	content := fmt.Sprintf(`bind = "0.0.0.0:%d"
+
 [servers]
-lobby = "127.0.0.1:25566"
-survival = "127.0.0.1:25567"
+lobby = "127.0.0.1:%d"
+survival = "127.0.0.1:%d"
 try = ["lobby"]
 
 [advanced]
 forwarding-secret = "%s"
 forwarding-mode = "MODERN"
-`, secret)
+`, port, lobbyPort, survivalPort, secret)
 
 	os.WriteFile(filepath.Join(dir, "velocity.toml"), []byte(content), 0644)
 }
 
-func setupBackend(dir string, port int, secret string, version string) {
+func setupBackend(dir string, secret string, version string) {
 	// 1. server.properties
-	propsPath := filepath.Join(dir, "server.properties")
-	props := fmt.Sprintf("server-port=%d\nonline-mode=false\n", port)
-	os.WriteFile(propsPath, []byte(props), 0644)
+	propsPath := filepath.Join(dir, PropertiesFile)
+	properties.Update(propsPath, map[string]string{
+		"online-mode": "false",
+	})
 
 	// 2. Paper Config
 	// Check version for config location
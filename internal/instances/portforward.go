@@ -8,8 +8,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+
+	"jjmc/internal/properties"
 )
 
 type TunnelConfig struct {
@@ -205,30 +209,16 @@ func (tm *TunnelManager) GetStatus() TunnelStatus {
 	return status
 }
 
+// GetServerPort returns the server-port from server.properties, or the
+// default port if it isn't set.
 func GetServerPort(instanceDir string) int {
-	path := filepath.Join(instanceDir, "server.properties")
-	file, err := os.Open(path)
+	props, err := properties.Read(filepath.Join(instanceDir, PropertiesFile))
 	if err != nil {
 		return 25565
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) > 0 && line[0] == '#' {
-			continue
-		}
-
-		if match, _ := regexp.MatchString(`^server-port=(\d+)`, line); match {
-			re := regexp.MustCompile(`^server-port=(\d+)`)
-			parts := re.FindStringSubmatch(line)
-			if len(parts) > 1 {
-				var port int
-				fmt.Sscanf(parts[1], "%d", &port)
-				return port
-			}
-		}
+	port, err := strconv.Atoi(strings.TrimSpace(props["server-port"]))
+	if err != nil || port <= 0 || port > 65535 {
+		return 25565
 	}
-	return 25565
+	return port
 }
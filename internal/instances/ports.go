@@ -0,0 +1,306 @@
+package instances
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/properties"
+)
+
+// Purposes of the ports JJMC manages in server.properties.
+const (
+	PortServer = "server"
+	PortRCON   = "rcon"
+	PortQuery  = "query"
+)
+
+// PortRange is the span of ports handed out to instances.
+type PortRange struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+var DefaultPortRange = PortRange{First: 25565, Last: 26564}
+
+var ErrPortTaken = errors.New("port is already in use")
+
+// ParsePortRange parses a range such as "25565-26564".
+func ParsePortRange(s string) (PortRange, error) {
+	first, last, ok := strings.Cut(s, "-")
+	if !ok {
+		return PortRange{}, fmt.Errorf("port range must look like 25565-26564")
+	}
+	r := PortRange{}
+	var err1, err2 error
+	r.First, err1 = strconv.Atoi(strings.TrimSpace(first))
+	r.Last, err2 = strconv.Atoi(strings.TrimSpace(last))
+	if err1 != nil || err2 != nil || r.First < 1 || r.Last > 65535 || r.First > r.Last {
+		return PortRange{}, fmt.Errorf("invalid port range: %s", s)
+	}
+	return r, nil
+}
+
+func (im *InstanceManager) SetPortRange(r PortRange) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.portRange = r
+}
+
+// defaultRCONPort is the RCON port of a server.properties without rcon.port.
+const defaultRCONPort = 25575
+
+// propertyPorts are the ports in server.properties, each only bound when
+// its enabledBy key is true. Unset ports take defaultPort, or the server
+// port when that is 0.
+var propertyPorts = []struct {
+	purpose, key, protocol, enabledBy string
+	defaultPort                       int
+}{
+	{PortServer, "server-port", "tcp", "", 25565},
+	{PortRCON, "rcon.port", "tcp", "enable-rcon", defaultRCONPort},
+	{PortQuery, "query.port", "udp", "enable-query", 0},
+}
+
+// Ports returns the ports allocated to the instance.
+func (im *InstanceManager) Ports(id string) ([]models.PortAllocation, error) {
+	ports := []models.PortAllocation{}
+	err := database.DB.Where("instance_id = ?", id).Order("port").Find(&ports).Error
+	return ports, err
+}
+
+// portUsed reports whether port is allocated to anything but the given
+// purpose of instance id.
+func portUsed(id, purpose string, port int) bool {
+	var count int64
+	database.DB.Model(&models.PortAllocation{}).
+		Where("port = ? AND NOT (instance_id = ? AND purpose = ?)", port, id, purpose).
+		Count(&count)
+	return count > 0
+}
+
+func recordPort(id, purpose, protocol string, port int) error {
+	if err := database.DB.Where("instance_id = ? AND purpose = ?", id, purpose).Delete(&models.PortAllocation{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Create(&models.PortAllocation{
+		InstanceID: id,
+		Purpose:    purpose,
+		Protocol:   protocol,
+		Port:       port,
+		CreatedAt:  time.Now().Unix(),
+	}).Error
+}
+
+func releasePorts(id string) {
+	database.DB.Where("instance_id = ?", id).Delete(&models.PortAllocation{})
+}
+
+// allocatePort hands out the lowest port of the range that no instance has
+// and nothing on the machine is listening on. Callers must hold im.mu.
+func (im *InstanceManager) allocatePort(id, purpose, protocol string) (int, error) {
+	for port := im.portRange.First; port <= im.portRange.Last; port++ {
+		if portUsed(id, purpose, port) || !portAvailable(protocol, port) {
+			continue
+		}
+		if err := recordPort(id, purpose, protocol, port); err != nil {
+			return 0, fmt.Errorf("failed to save port: %v", err)
+		}
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free %s port between %d and %d", protocol, im.portRange.First, im.portRange.Last)
+}
+
+// assignPorts gives the instance in dir its own server, RCON and query ports.
+// Ports already in its server.properties are kept unless another instance or
+// program has them. With enable, RCON and query are turned on as well.
+// Callers must hold im.mu.
+func (im *InstanceManager) assignPorts(id, dir string, enable bool) error {
+	path := filepath.Join(dir, PropertiesFile)
+	current, _ := properties.Read(path)
+
+	values := map[string]string{}
+	if enable {
+		if err := enableRCON(current, values); err != nil {
+			return err
+		}
+		values["enable-query"] = "true"
+	}
+
+	for _, p := range propertyPorts {
+		if p.enabledBy != "" && current[p.enabledBy] != "true" && values[p.enabledBy] != "true" {
+			continue
+		}
+		if port, err := strconv.Atoi(current[p.key]); err == nil && !portUsed(id, p.purpose, port) && portAvailable(p.protocol, port) {
+			if err := recordPort(id, p.purpose, p.protocol, port); err != nil {
+				return err
+			}
+			continue
+		}
+		port, err := im.allocatePort(id, p.purpose, p.protocol)
+		if err != nil {
+			return err
+		}
+		values[p.key] = strconv.Itoa(port)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return properties.Update(path, values)
+}
+
+// recordPorts brings the allocations of an instance in line with what its
+// server.properties says, which may have been edited by hand.
+func recordPorts(id, dir, serverType string) {
+	if isProxyType(serverType) {
+		recordProxyPort(id, dir, serverType)
+		return
+	}
+	props, err := properties.Read(filepath.Join(dir, PropertiesFile))
+	if err != nil {
+		return
+	}
+	for _, p := range propertyPorts {
+		if p.enabledBy != "" && props[p.enabledBy] != "true" {
+			database.DB.Where("instance_id = ? AND purpose = ?", id, p.purpose).Delete(&models.PortAllocation{})
+			continue
+		}
+		port, err := strconv.Atoi(props[p.key])
+		if err != nil {
+			port = p.defaultPort
+			if port == 0 {
+				port = GetServerPort(dir)
+			}
+		}
+		recordPort(id, p.purpose, p.protocol, port)
+	}
+}
+
+// assignInstancePorts gives an instance of serverType its ports, in
+// server.properties or, for proxies, in their own config.
+// Callers must hold im.mu.
+func (im *InstanceManager) assignInstancePorts(id, dir, serverType string, enable bool) error {
+	if isProxyType(serverType) {
+		return im.assignProxyPort(id, dir, serverType)
+	}
+	return im.assignPorts(id, dir, enable)
+}
+
+// ReservePort sets aside an extra port for the instance, such as one for a
+// web map. A port of 0 picks a free one from the range.
+func (im *InstanceManager) ReservePort(id, purpose, protocol string, port int) (models.PortAllocation, error) {
+	switch purpose {
+	case "":
+		return models.PortAllocation{}, fmt.Errorf("a name for the port is required")
+	case PortServer, PortRCON, PortQuery:
+		return models.PortAllocation{}, fmt.Errorf("%s ports are managed through server.properties", purpose)
+	}
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return models.PortAllocation{}, fmt.Errorf("protocol must be tcp or udp")
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if _, ok := im.instances[id]; !ok {
+		return models.PortAllocation{}, fmt.Errorf("instance not found")
+	}
+
+	if port == 0 {
+		var err error
+		if port, err = im.allocatePort(id, purpose, protocol); err != nil {
+			return models.PortAllocation{}, err
+		}
+	} else {
+		if port < 1 || port > 65535 {
+			return models.PortAllocation{}, fmt.Errorf("port must be between 1 and 65535")
+		}
+		if portUsed(id, purpose, port) || !portAvailable(protocol, port) {
+			return models.PortAllocation{}, ErrPortTaken
+		}
+		if err := recordPort(id, purpose, protocol, port); err != nil {
+			return models.PortAllocation{}, err
+		}
+	}
+
+	var allocation models.PortAllocation
+	err := database.DB.Where("instance_id = ? AND purpose = ?", id, purpose).First(&allocation).Error
+	return allocation, err
+}
+
+func (im *InstanceManager) ReleasePort(id, purpose string) error {
+	switch purpose {
+	case PortServer, PortRCON, PortQuery:
+		return fmt.Errorf("%s ports are managed through server.properties", purpose)
+	}
+	result := database.DB.Where("instance_id = ? AND purpose = ?", id, purpose).Delete(&models.PortAllocation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no port named %s", purpose)
+	}
+	return nil
+}
+
+// checkPorts runs before an instance starts. It refuses to start when a
+// running instance or another program already has one of its ports.
+func (im *InstanceManager) checkPorts(inst *Instance) error {
+	recordPorts(inst.ID, inst.Directory, inst.Type)
+
+	ports, err := im.Ports(inst.ID)
+	if err != nil {
+		return nil
+	}
+
+	im.mu.RLock()
+	others := make(map[string]*Instance, len(im.instances))
+	for id, other := range im.instances {
+		if id != inst.ID {
+			others[id] = other
+		}
+	}
+	im.mu.RUnlock()
+
+	for _, p := range ports {
+		var holders []models.PortAllocation
+		database.DB.Where("port = ? AND protocol = ? AND instance_id <> ?", p.Port, p.Protocol, inst.ID).Find(&holders)
+		for _, holder := range holders {
+			if other, ok := others[holder.InstanceID]; ok && other.Manager.GetState().IsActive() {
+				return fmt.Errorf("%s port %d is already used by running instance %s (%s)", p.Purpose, p.Port, other.Name, other.ID)
+			}
+		}
+		if !portAvailable(p.Protocol, p.Port) {
+			return fmt.Errorf("%s port %d is already in use by another program", p.Purpose, p.Port)
+		}
+	}
+	return nil
+}
+
+func portAvailable(network string, port int) bool {
+	addr := fmt.Sprintf(":%d", port)
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
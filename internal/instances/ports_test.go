@@ -0,0 +1,186 @@
+package instances
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/properties"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func useTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(database.Models...); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+func TestAssignPortsAvoidsConflicts(t *testing.T) {
+	useTestDB(t)
+
+	// Something outside JJMC holds a port in the range.
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	im := &InstanceManager{instances: map[string]*Instance{}, portRange: PortRange{First: busyPort, Last: busyPort + 20}}
+
+	seen := map[string]string{}
+	for _, id := range []string{"a", "b"} {
+		dir := t.TempDir()
+		if err := im.assignPorts(id, dir, true); err != nil {
+			t.Fatalf("assignPorts(%s): %v", id, err)
+		}
+		props, err := properties.Read(filepath.Join(dir, PropertiesFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if props["enable-rcon"] != "true" || props["rcon.password"] == "" || props["enable-query"] != "true" {
+			t.Errorf("%s: RCON and query not enabled: %v", id, props)
+		}
+		for _, key := range []string{"server-port", "rcon.port", "query.port"} {
+			port := props[key]
+			// The query port is UDP, which the TCP listener doesn't block.
+			if port == strconv.Itoa(busyPort) && key != "query.port" {
+				t.Errorf("%s: %s got the port in use by another program", id, key)
+			}
+			if owner, dup := seen[port]; dup {
+				t.Errorf("%s: %s=%s is already %s", id, key, port, owner)
+			}
+			seen[port] = id + " " + key
+		}
+	}
+
+	ports, err := im.Ports("a")
+	if err != nil || len(ports) != 3 {
+		t.Fatalf("expected 3 allocations for a, got %v (%v)", ports, err)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	r, err := ParsePortRange("30000-30100")
+	if err != nil || r.First != 30000 || r.Last != 30100 {
+		t.Errorf("unexpected range %+v (%v)", r, err)
+	}
+	for _, bad := range []string{"30000", "30100-30000", "0-10", "1-70000", "a-b"} {
+		if _, err := ParsePortRange(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRecordPortsDefaults(t *testing.T) {
+	useTestDB(t)
+
+	dir := t.TempDir()
+	if err := properties.Update(filepath.Join(dir, PropertiesFile), map[string]string{
+		"server-port":  "30001",
+		"enable-rcon":  "true",
+		"enable-query": "true",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	recordPorts("smp", dir, "paper")
+
+	im := &InstanceManager{}
+	ports, err := im.Ports("smp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, p := range ports {
+		got[p.Purpose] = p.Port
+	}
+	// RCON has a default of its own, only query shares the server port.
+	want := map[string]int{PortServer: 30001, PortRCON: defaultRCONPort, PortQuery: 30001}
+	for purpose, port := range want {
+		if got[purpose] != port {
+			t.Errorf("%s port = %d, want %d", purpose, got[purpose], port)
+		}
+	}
+}
+
+func TestAssignProxyPorts(t *testing.T) {
+	useTestDB(t)
+
+	first := freeRange(t, 10)
+	im := &InstanceManager{instances: map[string]*Instance{}, portRange: PortRange{First: first, Last: first + 10}}
+
+	// Fresh proxies have no config yet. The imported one has the port the
+	// first proxy is about to get.
+	waterfall := t.TempDir()
+	stock := fmt.Sprintf("listeners:\n- query_port: 25577\n  motd: '&1Another Bungee server'\n  host: 0.0.0.0:%d\n  max_players: 1\n", first)
+	if err := os.WriteFile(filepath.Join(waterfall, "config.yml"), []byte(stock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	proxies := []struct{ serverType, dir string }{
+		{"velocity", t.TempDir()},
+		{"bungeecord", t.TempDir()},
+		{"waterfall", waterfall},
+	}
+
+	seen := map[int]string{}
+	for _, proxy := range proxies {
+		serverType, dir := proxy.serverType, proxy.dir
+		if err := im.assignInstancePorts(serverType, dir, serverType, true); err != nil {
+			t.Fatalf("%s: %v", serverType, err)
+		}
+		port, err := readProxyPort(dir, serverType)
+		if err != nil {
+			t.Fatalf("%s: %v", serverType, err)
+		}
+		if port < first || port > first+10 {
+			t.Errorf("%s: port %d is outside the range", serverType, port)
+		}
+		if owner, dup := seen[port]; dup {
+			t.Errorf("%s: port %d is already %s's", serverType, port, owner)
+		}
+		seen[port] = serverType
+		if _, err := os.Stat(filepath.Join(dir, PropertiesFile)); err == nil {
+			t.Errorf("%s: server.properties was written for a proxy", serverType)
+		}
+		ports, _ := im.Ports(serverType)
+		if len(ports) != 1 || ports[0].Port != port {
+			t.Errorf("%s: allocations %v, want just port %d", serverType, ports, port)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(waterfall, "config.yml"))
+	if !strings.Contains(string(data), "  motd: '&1Another Bungee server'\n") || !strings.Contains(string(data), "\n  host: 0.0.0.0:") {
+		t.Errorf("rest of config.yml was not kept:\n%s", data)
+	}
+}
+
+// freeRange returns the first of n consecutive ports nothing listens on.
+func freeRange(t *testing.T, n int) int {
+	t.Helper()
+	for first := 40000; first < 60000; first += n {
+		free := true
+		for port := first; port <= first+n && free; port++ {
+			free = portAvailable("tcp", port)
+		}
+		if free {
+			return first
+		}
+	}
+	t.Fatal("no free port range")
+	return 0
+}
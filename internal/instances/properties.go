@@ -0,0 +1,111 @@
+package instances
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"jjmc/internal/properties"
+)
+
+const PropertiesFile = "server.properties"
+
+// PropertyValue is a key of server.properties along with its schema, if it
+// is a vanilla key.
+type PropertyValue struct {
+	Key    string               `json:"key"`
+	Value  string               `json:"value"`
+	Set    bool                 `json:"set"` // False for vanilla keys missing from the file, shown with their default
+	Schema *properties.Property `json:"schema,omitempty"`
+}
+
+// PropertyErrors maps keys to why their new value was rejected.
+type PropertyErrors map[string]string
+
+func (e PropertyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = key + ": " + e[key]
+	}
+	return "invalid properties: " + strings.Join(msgs, "; ")
+}
+
+// GetProperties returns the keys in server.properties in file order,
+// followed by the vanilla keys of the instance's version that aren't set.
+func (inst *Instance) GetProperties() ([]PropertyValue, error) {
+	f, err := properties.Load(filepath.Join(inst.Directory, PropertiesFile))
+	if os.IsNotExist(err) {
+		f = &properties.File{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := []PropertyValue{}
+	seen := make(map[string]bool)
+	for _, key := range f.Keys() {
+		value, _ := f.Get(key)
+		values = append(values, PropertyValue{Key: key, Value: value, Set: true, Schema: properties.Lookup(key)})
+		seen[key] = true
+	}
+	for i := range properties.Schema {
+		p := &properties.Schema[i]
+		if !seen[p.Key] && p.AvailableIn(inst.Version) {
+			values = append(values, PropertyValue{Key: p.Key, Value: p.Default, Schema: p})
+		}
+	}
+	return values, nil
+}
+
+// UpdateProperties validates every change against the schema for the
+// instance's version and only then writes them, keeping the rest of the file
+// as it was. A nil value removes the key. A running server picks the changes
+// up on its next start.
+func (inst *Instance) UpdateProperties(changes map[string]*string) error {
+	invalid := PropertyErrors{}
+	for key, value := range changes {
+		if value == nil {
+			if key == "" {
+				invalid[key] = "invalid key"
+			}
+			continue
+		}
+		if err := properties.Validate(key, *value, inst.Version); err != nil {
+			invalid[key] = err.Error()
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+
+	path := filepath.Join(inst.Directory, PropertiesFile)
+	f, err := properties.Load(path)
+	if os.IsNotExist(err) {
+		f = &properties.File{}
+	} else if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := changes[key]; value == nil {
+			f.Delete(key)
+		} else {
+			f.Set(key, *value)
+		}
+	}
+	if err := f.Save(path); err != nil {
+		return fmt.Errorf("failed to save %s: %v", PropertiesFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package instances
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultProxyPort is where Velocity and BungeeCord listen out of the box.
+const defaultProxyPort = 25577
+
+var (
+	velocityBind = regexp.MustCompile(`^bind\s*=`)
+	bungeeHost   = regexp.MustCompile(`^(\s*-?\s*)host:`)
+)
+
+// proxyConfig is the file a proxy keeps its listen address in.
+func proxyConfig(dir, serverType string) string {
+	if serverType == "velocity" {
+		return filepath.Join(dir, "velocity.toml")
+	}
+	return filepath.Join(dir, "config.yml")
+}
+
+// readProxyPort returns the port the proxy in dir listens on. Velocity has it
+// in bind, BungeeCord and Waterfall in the host of their first listener.
+func readProxyPort(dir, serverType string) (int, error) {
+	path := proxyConfig(dir, serverType)
+	if serverType == "velocity" {
+		var config struct {
+			Bind string `toml:"bind"`
+		}
+		if err := parseToml(path, &config); err != nil {
+			return 0, err
+		}
+		return addressPort(config.Bind)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if loc := bungeeHost.FindStringIndex(line); loc != nil {
+			return addressPort(line[loc[1]:])
+		}
+	}
+	return 0, fmt.Errorf("no listener host in %s", filepath.Base(path))
+}
+
+func addressPort(address string) (int, error) {
+	address = strings.Trim(strings.TrimSpace(address), `"'`)
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(port)
+}
+
+// writeProxyPort points the listen address of the proxy in dir at port,
+// keeping the rest of its config. A missing config is created with just the
+// address and the proxy fills in the rest on its first start.
+func writeProxyPort(dir, serverType string, port int) error {
+	path := proxyConfig(dir, serverType)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	address := fmt.Sprintf("0.0.0.0:%d", port)
+	replaced := false
+	for i, line := range lines {
+		if serverType == "velocity" {
+			if strings.HasPrefix(strings.TrimSpace(line), "[") {
+				break
+			}
+			if velocityBind.MatchString(line) {
+				lines[i] = fmt.Sprintf("bind = %q", address)
+				replaced = true
+				break
+			}
+		} else if loc := bungeeHost.FindStringSubmatchIndex(line); loc != nil {
+			lines[i] = line[:loc[3]] + "host: " + address
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		if serverType == "velocity" {
+			// Top-level keys have to come before the first table.
+			lines = append([]string{fmt.Sprintf("bind = %q", address)}, lines...)
+		} else {
+			for _, line := range lines {
+				if strings.HasPrefix(line, "listeners:") {
+					return fmt.Errorf("config.yml has listeners without a host")
+				}
+			}
+			lines = append(lines, "listeners:", "- host: "+address)
+		}
+	}
+
+	content := strings.Join(lines, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// assignProxyPort is assignPorts for proxies, which have a single port and
+// keep it in their own config. Callers must hold im.mu.
+func (im *InstanceManager) assignProxyPort(id, dir, serverType string) error {
+	if port, err := readProxyPort(dir, serverType); err == nil && !portUsed(id, PortServer, port) && portAvailable("tcp", port) {
+		return recordPort(id, PortServer, "tcp", port)
+	}
+	port, err := im.allocatePort(id, PortServer, "tcp")
+	if err != nil {
+		return err
+	}
+	return writeProxyPort(dir, serverType, port)
+}
+
+// recordProxyPort is recordPorts for proxies.
+func recordProxyPort(id, dir, serverType string) {
+	port, err := readProxyPort(dir, serverType)
+	if err != nil {
+		port = defaultProxyPort
+	}
+	recordPort(id, PortServer, "tcp", port)
+}
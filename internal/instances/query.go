@@ -0,0 +1,47 @@
+package instances
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"jjmc/internal/manager"
+	"jjmc/internal/properties"
+	"jjmc/pkg/mcquery"
+)
+
+const queryTimeout = 3 * time.Second
+
+var ErrQueryDisabled = errors.New("query is not enabled in server.properties")
+
+// Query fetches the full stat from the server's query port, which lists
+// every player rather than the sample the status ping returns.
+func (inst *Instance) Query() (*mcquery.FullStat, error) {
+	if inst.Manager.GetState() != manager.StateOnline {
+		return nil, manager.ErrNotRunning
+	}
+
+	props, err := properties.Read(filepath.Join(inst.Directory, PropertiesFile))
+	if err != nil || props["enable-query"] != "true" {
+		return nil, ErrQueryDisabled
+	}
+	// Vanilla defaults the query port to the game port.
+	port := props["query.port"]
+	if port == "" {
+		port = strconv.Itoa(GetServerPort(inst.Directory))
+	}
+
+	return mcquery.FullStatQuery(net.JoinHostPort("127.0.0.1", port), queryTimeout)
+}
+
+// reconcilePlayers asks a server reattached to after a panel restart who is
+// online, as players may have come and gone while the panel was down.
+func (im *InstanceManager) reconcilePlayers(inst *Instance) {
+	stat, err := inst.Query()
+	if err != nil {
+		return
+	}
+	im.Players.Reconcile(inst.ID, stat.Players, time.Now())
+}
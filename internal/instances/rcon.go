@@ -0,0 +1,47 @@
+package instances
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+
+	"jjmc/internal/properties"
+)
+
+func isProxyType(serverType string) bool {
+	switch serverType {
+	case "velocity", "bungeecord", "waterfall":
+		return true
+	}
+	return false
+}
+
+// enableRCON adds the settings that turn on RCON to values, so that commands
+// from the panel get real responses. The password is random; one already in
+// current is kept. The port is left to assignPorts.
+func enableRCON(current, values map[string]string) error {
+	values["enable-rcon"] = "true"
+	if current["rcon.password"] == "" {
+		secret := make([]byte, 16)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		values["rcon.password"] = hex.EncodeToString(secret)
+	}
+	return nil
+}
+
+// renewRCONPassword gives the server in dir a new RCON password when RCON is
+// on but the password was left out, as in an exported bundle.
+func renewRCONPassword(dir string) error {
+	path := filepath.Join(dir, PropertiesFile)
+	current, err := properties.Read(path)
+	if err != nil || current["enable-rcon"] != "true" || current["rcon.password"] != "" {
+		return nil
+	}
+	values := map[string]string{}
+	if err := enableRCON(current, values); err != nil {
+		return err
+	}
+	return properties.Update(path, values)
+}
@@ -0,0 +1,124 @@
+package instances
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// instanceIDTables are the tables that refer to instances by ID.
+var instanceIDTables = []interface{}{
+	&models.Schedule{},
+	&models.PortAllocation{},
+	&models.PlayerSession{},
+	&models.PlayerEvent{},
+	&models.BackupRun{},
+	&models.SharedListRemoval{},
+}
+
+// RenameInstance changes the display name of an instance.
+func (im *InstanceManager) RenameInstance(id, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+	if err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Update("name", name).Error; err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+	inst.Name = name
+	inst.Manager.SetInstanceInfo(inst.ID, inst.Name, inst.Type, inst.Version)
+	return nil
+}
+
+// ChangeID gives an instance a new ID, moving its directory and backups and
+// pointing its schedules, ports and player history at the new ID. Either all
+// of it happens or none of it. The instance must be stopped.
+func (im *InstanceManager) ChangeID(id, newID string) (*Instance, error) {
+	if !instanceIDRe.MatchString(newID) {
+		return nil, fmt.Errorf("invalid instance id: %s", newID)
+	}
+	if newID == id {
+		return nil, fmt.Errorf("instance already has id %s", id)
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return nil, fmt.Errorf("instance not found")
+	}
+	if _, exists := im.instances[newID]; exists {
+		return nil, fmt.Errorf("instance with id %s already exists", newID)
+	}
+	if inst.Manager.GetState().IsActive() {
+		return nil, fmt.Errorf("cannot change the id of a running instance")
+	}
+	if inst.Tunnel != nil && inst.Tunnel.GetStatus().Running {
+		return nil, fmt.Errorf("stop the tunnel before changing the id")
+	}
+
+	dir := filepath.Join(im.baseDir, newID)
+	backups, newBackups := im.GetBackupDir(id), im.GetBackupDir(newID)
+	for _, path := range []string{dir, newBackups} {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+	}
+
+	if err := os.Rename(inst.Directory, dir); err != nil {
+		return nil, fmt.Errorf("failed to move directory: %v", err)
+	}
+	movedBackups := false
+	if _, err := os.Stat(backups); err == nil {
+		if err := os.Rename(backups, newBackups); err != nil {
+			os.Rename(dir, inst.Directory)
+			return nil, fmt.Errorf("failed to move backups: %v", err)
+		}
+		movedBackups = true
+	}
+	undo := func() {
+		if movedBackups {
+			os.Rename(newBackups, backups)
+		}
+		os.Rename(dir, inst.Directory)
+	}
+
+	var model models.InstanceModel
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.InstanceModel{}).Where("id = ?", id).Update("id", newID).Error; err != nil {
+			return err
+		}
+		for _, table := range instanceIDTables {
+			if err := tx.Model(table).Where("instance_id = ?", id).Update("instance_id", newID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&model, "id = ?", newID).Error
+	})
+	if err != nil {
+		undo()
+		return nil, fmt.Errorf("failed to update db: %v", err)
+	}
+
+	// Trackers and hooks were set up with the old ID, so start over.
+	moved := im.loadInstance(model)
+	delete(im.instances, id)
+	im.instances[newID] = moved
+	inst.Manager.Close()
+	return moved, nil
+}
@@ -0,0 +1,81 @@
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+func TestChangeID(t *testing.T) {
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances: map[string]*Instance{},
+		baseDir:   base,
+		Players:   players.NewTracker(database.DB),
+		portRange: PortRange{First: 41400, Last: 41500},
+	}
+
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", Version: "1.20.4", MaxMemory: 2048}
+	database.DB.Create(&model)
+	database.DB.Create(&models.Schedule{ID: "s1", InstanceID: "smp", Name: "Backup", CronExpression: "0 * * * *", Type: "backup"})
+	os.MkdirAll(filepath.Join(base, "smp", "world"), 0755)
+	if err := im.assignPorts("smp", filepath.Join(base, "smp"), false); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(im.GetBackupDir("smp"), 0755)
+	os.WriteFile(filepath.Join(im.GetBackupDir("smp"), "backup.zip"), []byte("x"), 0644)
+	im.instances["smp"] = im.loadInstance(model)
+	os.WriteFile(filepath.Join(base, "smp", "world", "level.dat"), []byte("level"), 0644)
+	run, err := im.RunBackup("smp", BackupOptions{}, BackupManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := im.ChangeID("smp", "../escape"); err == nil {
+		t.Error("expected an invalid id to be refused")
+	}
+
+	inst, err := im.ChangeID("smp", "survival")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.ID != "survival" || inst.Name != "SMP" || inst.Directory != filepath.Join(base, "survival") {
+		t.Errorf("unexpected instance %+v", inst.Instance)
+	}
+	if _, err := im.GetInstance("smp"); err == nil {
+		t.Error("old id still resolves")
+	}
+	if _, err := os.Stat(filepath.Join(base, "survival", "world")); err != nil {
+		t.Error("directory not moved")
+	}
+	if _, err := os.Stat(filepath.Join(im.GetBackupDir("survival"), "backup.zip")); err != nil {
+		t.Error("backups not moved")
+	}
+
+	var schedule models.Schedule
+	database.DB.First(&schedule, "id = ?", "s1")
+	if schedule.InstanceID != "survival" {
+		t.Errorf("schedule still points at %s", schedule.InstanceID)
+	}
+	if ports, _ := im.Ports("survival"); len(ports) == 0 {
+		t.Error("ports not moved")
+	}
+
+	// Backups taken under the old ID restore into the renamed directory.
+	os.Remove(filepath.Join(base, "survival", "world", "level.dat"))
+	if err := im.RestoreBackup("survival", run.Backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "survival", "world", "level.dat")); err != nil {
+		t.Errorf("backup not restored into the renamed directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "smp")); !os.IsNotExist(err) {
+		t.Error("restore brought back the old directory")
+	}
+}
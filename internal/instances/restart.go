@@ -0,0 +1,90 @@
+package instances
+
+import (
+	"fmt"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+)
+
+// Defaults for new instances, mirrored by the gorm tags on InstanceModel.
+const (
+	defaultRestartPolicy     = manager.RestartNever
+	defaultRestartMaxRetries = 3
+	defaultRestartWindow     = 600
+	defaultRestartBackoff    = 10
+)
+
+func applyRestartDefaults(inst *models.Instance) {
+	inst.RestartPolicy = defaultRestartPolicy
+	inst.RestartMaxRetries = defaultRestartMaxRetries
+	inst.RestartWindow = defaultRestartWindow
+	inst.RestartBackoff = defaultRestartBackoff
+}
+
+func (inst *Instance) applyRestartPolicy() {
+	inst.Manager.SetRestartPolicy(manager.RestartPolicy{
+		Mode:       inst.RestartPolicy,
+		MaxRetries: inst.RestartMaxRetries,
+		Window:     time.Duration(inst.RestartWindow) * time.Second,
+		Backoff:    time.Duration(inst.RestartBackoff) * time.Second,
+	})
+}
+
+// UpdateRestartPolicy changes the settings that are given and leaves nil ones
+// as they are.
+func (im *InstanceManager) UpdateRestartPolicy(id string, mode *string, maxRetries, window, backoff *int) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+
+	if mode == nil && maxRetries == nil && window == nil && backoff == nil {
+		return nil
+	}
+	newMode, newRetries, newWindow, newBackoff := inst.RestartPolicy, inst.RestartMaxRetries, inst.RestartWindow, inst.RestartBackoff
+	if mode != nil {
+		newMode = *mode
+	}
+	if maxRetries != nil {
+		newRetries = *maxRetries
+	}
+	if window != nil {
+		newWindow = *window
+	}
+	if backoff != nil {
+		newBackoff = *backoff
+	}
+
+	switch newMode {
+	case manager.RestartNever, manager.RestartOnFailure, manager.RestartAlways:
+	default:
+		return fmt.Errorf("invalid restart policy: %s", newMode)
+	}
+	if newRetries < 0 || newWindow < 0 || newBackoff < 0 {
+		return fmt.Errorf("restart limits must not be negative")
+	}
+
+	err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"restart_policy":      newMode,
+		"restart_max_retries": newRetries,
+		"restart_window":      newWindow,
+		"restart_backoff":     newBackoff,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+
+	inst.RestartPolicy = newMode
+	inst.RestartMaxRetries = newRetries
+	inst.RestartWindow = newWindow
+	inst.RestartBackoff = newBackoff
+	inst.applyRestartPolicy()
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package instances
+
+import (
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+func TestUpdateRestartPolicyKeepsUnsentFields(t *testing.T) {
+	useTestDB(t)
+	im := &InstanceManager{instances: map[string]*Instance{}, baseDir: t.TempDir(), Players: players.NewTracker(database.DB)}
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", RestartPolicy: manager.RestartNever, RestartMaxRetries: 3, RestartWindow: 600, RestartBackoff: 10}
+	database.DB.Create(&model)
+	im.instances["smp"] = im.loadInstance(model)
+
+	// Only the mode is sent; the limits must not drop to 0, which would mean
+	// restarting forever without a backoff.
+	mode := manager.RestartOnFailure
+	if err := im.UpdateRestartPolicy("smp", &mode, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var stored models.InstanceModel
+	database.DB.First(&stored, "id = ?", "smp")
+	inst := im.instances["smp"]
+	for _, m := range []struct {
+		mode                     string
+		retries, window, backoff int
+	}{
+		{stored.RestartPolicy, stored.RestartMaxRetries, stored.RestartWindow, stored.RestartBackoff},
+		{inst.RestartPolicy, inst.RestartMaxRetries, inst.RestartWindow, inst.RestartBackoff},
+	} {
+		if m.mode != manager.RestartOnFailure || m.retries != 3 || m.window != 600 || m.backoff != 10 {
+			t.Errorf("unexpected policy %+v", m)
+		}
+	}
+
+	bad := "sometimes"
+	if err := im.UpdateRestartPolicy("smp", &bad, nil, nil, nil); err == nil {
+		t.Error("expected an unknown mode to be refused")
+	}
+	negative := -1
+	if err := im.UpdateRestartPolicy("smp", nil, &negative, nil, nil); err == nil {
+		t.Error("expected a negative limit to be refused")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 func (inst *Instance) Save() error {
 	return database.DB.Model(&models.InstanceModel{}).Where("id = ?", inst.ID).Updates(models.InstanceModel{
+		Name:      inst.Name,
 		Type:      inst.Type,
 		Version:   inst.Version,
 		MaxMemory: inst.MaxMemory,
@@ -0,0 +1,752 @@
+package instances
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+// Player lists that can be shared across a group.
+const (
+	ListWhitelist = "whitelist"
+	ListOps       = "ops"
+	ListBans      = "bans"
+	ListIPBans    = "ip-bans"
+)
+
+const sharedQueueSize = 256
+
+// SourceAPI marks shared list entries added through the API. Entries picked
+// up from a member carry "console:<instance>" or "import:<instance>".
+const SourceAPI = "api"
+
+var (
+	ErrUnknownList   = errors.New("unknown list, expected whitelist, ops, bans or ip-bans")
+	ErrListNotShared = errors.New("this list is not shared in the group")
+)
+
+// MemberResult tells how a shared list change reached one member of the
+// group. Deferred changes are written when the member next starts.
+type MemberResult struct {
+	InstanceID string `json:"instanceId"`
+	AccessResult
+	Deferred bool   `json:"deferred,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func validList(list string) bool {
+	switch list {
+	case ListWhitelist, ListOps, ListBans, ListIPBans:
+		return true
+	}
+	return false
+}
+
+func listKey(list, value string) string {
+	if list == ListIPBans {
+		return value
+	}
+	return strings.ToLower(value)
+}
+
+func (im *InstanceManager) groupMembers(group string) []*Instance {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	members := []*Instance{}
+	for _, inst := range im.instances {
+		if group != "" && inst.Group == group {
+			members = append(members, inst)
+		}
+	}
+	return members
+}
+
+func (im *InstanceManager) isShared(group, list string) bool {
+	var count int64
+	database.DB.Model(&models.SharedList{}).Where("group_name = ? AND list = ?", group, list).Count(&count)
+	return count > 0
+}
+
+func (im *InstanceManager) sharedEntry(group, list, key string) (models.SharedListEntry, bool) {
+	var entry models.SharedListEntry
+	err := database.DB.Where("group_name = ? AND list = ? AND key = ?", group, list, key).First(&entry).Error
+	return entry, err == nil
+}
+
+func (im *InstanceManager) recordChange(group, list, key, action, source string) {
+	database.DB.Create(&models.SharedListChange{
+		Group:  group,
+		List:   list,
+		Key:    key,
+		Action: action,
+		Source: source,
+		Time:   time.Now().Unix(),
+	})
+}
+
+// SharedLists returns the lists kept in sync across group.
+func (im *InstanceManager) SharedLists(group string) ([]models.SharedList, error) {
+	lists := []models.SharedList{}
+	err := database.DB.Where("group_name = ?", group).Order("list").Find(&lists).Error
+	return lists, err
+}
+
+// ShareList starts keeping list in sync across group. What the members
+// already have is merged into the shared list first, so nothing is lost,
+// and the result is then pushed to every member: written to the files of
+// stopped ones, and sent as commands to running ones.
+func (im *InstanceManager) ShareList(group, list string) (models.SharedList, error) {
+	if !validList(list) {
+		return models.SharedList{}, ErrUnknownList
+	}
+	if group == "" {
+		return models.SharedList{}, fmt.Errorf("group is required")
+	}
+
+	shared, had, created, err := im.mergeSharedList(group, list)
+	if err != nil || !created {
+		return shared, err
+	}
+
+	entries, err := im.sharedEntries(group, list)
+	if err != nil {
+		return shared, err
+	}
+	for _, inst := range im.groupMembers(group) {
+		if !inst.acceptsCommands() {
+			if err := im.syncMember(inst, list); err != nil {
+				fmt.Printf("Failed to sync %s of %s: %v\n", list, inst.ID, err)
+			}
+			continue
+		}
+		// A running server only learns of entries through its console.
+		for i := range entries {
+			if had[inst.ID][entries[i].Key] {
+				continue
+			}
+			if result := inst.applySharedEntry(&entries[i]); result.Error != "" {
+				fmt.Printf("Failed to add %s to %s of %s: %s\n", entries[i].Key, list, inst.ID, result.Error)
+			}
+		}
+	}
+	return shared, nil
+}
+
+// mergeSharedList records list as shared in group and merges the members'
+// entries into it. had tells which keys each member already has. created is
+// false if the list was shared already, in which case nothing is merged.
+func (im *InstanceManager) mergeSharedList(group, list string) (shared models.SharedList, had map[string]map[string]bool, created bool, err error) {
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+
+	shared = models.SharedList{Group: group, List: list}
+	if err := database.DB.Where(&shared).First(&shared).Error; err == nil {
+		return shared, nil, false, nil
+	}
+	shared.CreatedAt = time.Now().Unix()
+	if err := database.DB.Create(&shared).Error; err != nil {
+		return models.SharedList{}, nil, false, fmt.Errorf("failed to save shared list: %v", err)
+	}
+
+	had = map[string]map[string]bool{}
+	for _, inst := range im.groupMembers(group) {
+		entries, err := inst.listEntries(list)
+		if err != nil {
+			fmt.Printf("Failed to read %s of %s: %v\n", list, inst.ID, err)
+			continue
+		}
+		had[inst.ID] = map[string]bool{}
+		for _, entry := range entries {
+			had[inst.ID][entry.Key] = true
+			if _, exists := im.sharedEntry(group, list, entry.Key); exists {
+				continue
+			}
+			entry.Group = group
+			entry.Source = "import:" + inst.ID
+			entry.CreatedAt = shared.CreatedAt
+			database.DB.Create(&entry)
+			im.recordChange(group, list, entry.Key, "add", entry.Source)
+		}
+	}
+	return shared, had, true, nil
+}
+
+// syncMember writes a shared list into the files of a stopped member.
+func (im *InstanceManager) syncMember(inst *Instance, list string) error {
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+	return im.syncSharedList(inst, list)
+}
+
+func (im *InstanceManager) sharedEntries(group, list string) ([]models.SharedListEntry, error) {
+	entries := []models.SharedListEntry{}
+	err := database.DB.Where("group_name = ? AND list = ?", group, list).Order("key").Find(&entries).Error
+	return entries, err
+}
+
+// UnshareList stops syncing list. The members keep their current entries.
+func (im *InstanceManager) UnshareList(group, list string) error {
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+
+	if !im.isShared(group, list) {
+		return ErrListNotShared
+	}
+	tx := database.DB.Begin()
+	if err := tx.Where("group_name = ? AND list = ?", group, list).Delete(&models.SharedListEntry{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("group_name = ? AND list = ?", group, list).Delete(&models.SharedList{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("group_name = ? AND list = ?", group, list).Delete(&models.SharedListRemoval{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (im *InstanceManager) SharedListEntries(group, list string) ([]models.SharedListEntry, error) {
+	if !im.isShared(group, list) {
+		return nil, ErrListNotShared
+	}
+	return im.sharedEntries(group, list)
+}
+
+// SharedListChanges returns the audit trail of a list, newest first.
+func (im *InstanceManager) SharedListChanges(group, list string, limit int) ([]models.SharedListChange, error) {
+	changes := []models.SharedListChange{}
+	db := database.DB.Where("group_name = ? AND list = ?", group, list).Order("time DESC, id DESC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	err := db.Find(&changes).Error
+	return changes, err
+}
+
+// AddSharedEntry adds or replaces an entry on a shared list and applies it to
+// every member of the group except origin, the instance it came from if any.
+func (im *InstanceManager) AddSharedEntry(group string, entry models.SharedListEntry, origin string) ([]MemberResult, error) {
+	if !validList(entry.List) {
+		return nil, ErrUnknownList
+	}
+	if entry.List == ListIPBans {
+		if net.ParseIP(entry.IP) == nil {
+			return nil, ErrInvalidIP
+		}
+		entry.Key = entry.IP
+	} else {
+		if !players.ValidName(entry.Name) {
+			return nil, players.ErrInvalidName
+		}
+		entry.Key = listKey(entry.List, entry.Name)
+	}
+	if entry.List == ListOps && (entry.Level < 0 || entry.Level > 4) {
+		return nil, ErrInvalidOpLevel
+	}
+	entry.Reason = sanitizeReason(entry.Reason)
+	if err := im.saveSharedEntry(group, &entry); err != nil {
+		return nil, err
+	}
+
+	// Members are updated without holding sharedMu, as a slow console would
+	// otherwise hold up every start in the group.
+	results := []MemberResult{}
+	for _, inst := range im.groupMembers(group) {
+		if inst.ID != origin {
+			results = append(results, inst.applySharedEntry(&entry))
+		}
+	}
+	return results, nil
+}
+
+// RemoveSharedEntry removes key (a player name, or an address for ip-bans)
+// from a shared list and from every member except origin.
+func (im *InstanceManager) RemoveSharedEntry(group, list, key, source, origin string) ([]MemberResult, error) {
+	if !validList(list) {
+		return nil, ErrUnknownList
+	}
+
+	entry, err := im.deleteSharedEntry(group, list, key, source)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []MemberResult{}
+	for _, inst := range im.groupMembers(group) {
+		if inst.ID == origin {
+			continue
+		}
+		result := inst.removeSharedEntry(entry)
+		if result.Error != "" {
+			im.recordRemovalFailure(inst, entry, result.Error)
+			result.Deferred = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (im *InstanceManager) saveSharedEntry(group string, entry *models.SharedListEntry) error {
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+
+	if !im.isShared(group, entry.List) {
+		return ErrListNotShared
+	}
+	entry.Group = group
+	if entry.Source == "" {
+		entry.Source = SourceAPI
+	}
+	entry.CreatedAt = time.Now().Unix()
+	entry.ID = 0
+	if existing, ok := im.sharedEntry(group, entry.List, entry.Key); ok {
+		entry.ID = existing.ID
+	}
+	if err := database.DB.Save(entry).Error; err != nil {
+		return fmt.Errorf("failed to save entry: %v", err)
+	}
+	// A removal still owed to a member is moot once the entry is back.
+	database.DB.Where("group_name = ? AND list = ? AND key = ?", group, entry.List, entry.Key).Delete(&models.SharedListRemoval{})
+	im.recordChange(group, entry.List, entry.Key, "add", entry.Source)
+	return nil
+}
+
+func (im *InstanceManager) deleteSharedEntry(group, list, key, source string) (models.SharedListEntry, error) {
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+
+	if !im.isShared(group, list) {
+		return models.SharedListEntry{}, ErrListNotShared
+	}
+	entry, ok := im.sharedEntry(group, list, listKey(list, key))
+	if !ok {
+		return models.SharedListEntry{}, fmt.Errorf("%s is not on the shared %s", key, list)
+	}
+	if err := database.DB.Delete(&entry).Error; err != nil {
+		return models.SharedListEntry{}, fmt.Errorf("failed to delete entry: %v", err)
+	}
+	im.recordChange(group, list, entry.Key, "remove", source)
+	return entry, nil
+}
+
+// recordRemovalFailure remembers that entry is still on inst's list, so that
+// the next sync takes it off.
+func (im *InstanceManager) recordRemovalFailure(inst *Instance, entry models.SharedListEntry, reason string) {
+	database.DB.Create(&models.SharedListRemoval{
+		InstanceID: inst.ID,
+		Group:      entry.Group,
+		List:       entry.List,
+		Key:        entry.Key,
+		Name:       entry.Name,
+		IP:         entry.IP,
+		Error:      reason,
+		Time:       time.Now().Unix(),
+	})
+}
+
+// PendingRemovals returns the removals that haven't reached inst yet.
+func (im *InstanceManager) PendingRemovals(instanceID string) ([]models.SharedListRemoval, error) {
+	removals := []models.SharedListRemoval{}
+	err := database.DB.Where("instance_id = ?", instanceID).Order("time, id").Find(&removals).Error
+	return removals, err
+}
+
+// applyPendingRemovals takes the entries that failed to be removed off
+// inst's list files. The server must not be running.
+func (im *InstanceManager) applyPendingRemovals(inst *Instance) error {
+	removals, err := im.PendingRemovals(inst.ID)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, removal := range removals {
+		var err error
+		switch removal.List {
+		case ListWhitelist:
+			err = inst.removeWhitelistEntry(removal.Name)
+		case ListOps:
+			err = inst.removeOpEntry(removal.Name)
+		case ListBans:
+			err = inst.removeBanEntry(removal.Name)
+		case ListIPBans:
+			err = inst.removeIPBanEntry(removal.IP)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", removal.Key, err))
+			continue
+		}
+		database.DB.Delete(&removal)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// entryProfile returns the profile to write entry with on inst. Offline-mode
+// servers derive the UUID from the name. Others use the UUID stored with the
+// entry and only ask Mojang for entries without a real one, which is then
+// saved so that it is asked only once.
+func entryProfile(inst *Instance, entry *models.SharedListEntry) (players.Profile, error) {
+	if !inst.onlineMode() {
+		return players.ResolveProfile(entry.Name, false)
+	}
+	if entry.UUID != "" && entry.UUID != players.OfflineUUID(entry.Name) {
+		return players.Profile{UUID: entry.UUID, Name: entry.Name}, nil
+	}
+
+	profile, err := players.ResolveProfile(entry.Name, true)
+	if err != nil {
+		return players.Profile{}, err
+	}
+	entry.UUID, entry.Name = profile.UUID, profile.Name
+	if entry.ID != 0 {
+		database.DB.Model(&models.SharedListEntry{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+			"uuid": profile.UUID,
+			"name": profile.Name,
+		})
+	}
+	return profile, nil
+}
+
+func (inst *Instance) applySharedEntry(entry *models.SharedListEntry) MemberResult {
+	result := MemberResult{InstanceID: inst.ID}
+
+	if inst.acceptsCommands() {
+		var cmd string
+		switch entry.List {
+		case ListWhitelist:
+			cmd = "whitelist add " + entry.Name
+		case ListOps:
+			// The op command can't set the level, so the exact entry is
+			// written on the next start.
+			cmd = "op " + entry.Name
+			result.Deferred = entry.Level != 0 || entry.BypassesPlayerLimit
+		case ListBans:
+			if entry.Expires != 0 {
+				result.Deferred = true
+			} else {
+				cmd = strings.TrimSpace("ban " + entry.Name + " " + entry.Reason)
+			}
+		case ListIPBans:
+			if entry.Expires != 0 {
+				result.Deferred = true
+			} else {
+				cmd = strings.TrimSpace("ban-ip " + entry.IP + " " + entry.Reason)
+			}
+		}
+		if cmd != "" {
+			access, _, err := inst.liveCommand(cmd)
+			result.AccessResult = access
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}
+		return result
+	}
+
+	if err := inst.writeSharedEntry(entry); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// writeSharedEntry puts entry into the member's list file. The server must
+// not be running.
+func (inst *Instance) writeSharedEntry(entry *models.SharedListEntry) error {
+	if entry.List == ListIPBans {
+		return inst.putIPBanEntry(IPBanEntry{
+			IP:      entry.IP,
+			Created: time.Unix(entry.CreatedAt, 0).Format(banTimeLayout),
+			Source:  banSource,
+			Expires: banExpiry(expiresAt(entry.Expires)),
+			Reason:  banReason(entry.Reason),
+		})
+	}
+
+	profile, err := entryProfile(inst, entry)
+	if err != nil {
+		return err
+	}
+	switch entry.List {
+	case ListWhitelist:
+		return inst.putWhitelistEntry(WhitelistEntry{UUID: profile.UUID, Name: profile.Name})
+	case ListOps:
+		return inst.putOpEntry(OpEntry{
+			UUID:                profile.UUID,
+			Name:                profile.Name,
+			Level:               entry.Level,
+			BypassesPlayerLimit: entry.BypassesPlayerLimit,
+		})
+	case ListBans:
+		return inst.putBanEntry(BanEntry{
+			UUID:    profile.UUID,
+			Name:    profile.Name,
+			Created: time.Unix(entry.CreatedAt, 0).Format(banTimeLayout),
+			Source:  banSource,
+			Expires: banExpiry(expiresAt(entry.Expires)),
+			Reason:  banReason(entry.Reason),
+		})
+	}
+	return ErrUnknownList
+}
+
+func (inst *Instance) removeSharedEntry(entry models.SharedListEntry) MemberResult {
+	var result AccessResult
+	var err error
+	switch entry.List {
+	case ListWhitelist:
+		result, err = inst.RemoveFromWhitelist(entry.Name)
+	case ListOps:
+		result, err = inst.RemoveOp(entry.Name)
+	case ListBans:
+		result, err = inst.PardonPlayer(entry.Name)
+	case ListIPBans:
+		result, err = inst.PardonIP(entry.IP)
+	}
+
+	member := MemberResult{InstanceID: inst.ID, AccessResult: result}
+	if err != nil {
+		member.Error = err.Error()
+	}
+	return member
+}
+
+// listEntries reads one of the member's list files as shared list entries.
+func (inst *Instance) listEntries(list string) ([]models.SharedListEntry, error) {
+	entries := []models.SharedListEntry{}
+	switch list {
+	case ListWhitelist:
+		whitelist, err := inst.GetWhitelist()
+		for _, e := range whitelist {
+			entries = append(entries, models.SharedListEntry{List: list, Key: listKey(list, e.Name), UUID: e.UUID, Name: e.Name})
+		}
+		return entries, err
+	case ListOps:
+		ops, err := inst.GetOps()
+		for _, e := range ops {
+			entries = append(entries, models.SharedListEntry{
+				List:                list,
+				Key:                 listKey(list, e.Name),
+				UUID:                e.UUID,
+				Name:                e.Name,
+				Level:               e.Level,
+				BypassesPlayerLimit: e.BypassesPlayerLimit,
+			})
+		}
+		return entries, err
+	case ListBans:
+		bans, err := inst.GetBans()
+		for _, e := range bans {
+			entries = append(entries, models.SharedListEntry{
+				List:    list,
+				Key:     listKey(list, e.Name),
+				UUID:    e.UUID,
+				Name:    e.Name,
+				Reason:  e.Reason,
+				Expires: parseBanExpiry(e.Expires),
+			})
+		}
+		return entries, err
+	case ListIPBans:
+		bans, err := inst.GetIPBans()
+		for _, e := range bans {
+			entries = append(entries, models.SharedListEntry{
+				List:    list,
+				Key:     e.IP,
+				IP:      e.IP,
+				Reason:  e.Reason,
+				Expires: parseBanExpiry(e.Expires),
+			})
+		}
+		return entries, err
+	}
+	return nil, ErrUnknownList
+}
+
+func parseBanExpiry(expires string) int64 {
+	t, err := time.Parse(banTimeLayout, expires)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func expiresAt(unix int64) time.Time {
+	if unix <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+// syncSharedList writes every entry of a shared list into the member's file.
+// It runs before the member starts, which is how offline members and temporary
+// bans that a running server couldn't take catch up. It doesn't go to Mojang
+// for entries that already have a UUID, so members can start while it is
+// down.
+func (im *InstanceManager) syncSharedList(inst *Instance, list string) error {
+	var entries []models.SharedListEntry
+	if err := database.DB.Where("group_name = ? AND list = ?", inst.Group, list).Find(&entries).Error; err != nil {
+		return err
+	}
+	var failed []string
+	for i := range entries {
+		entry := &entries[i]
+		if err := inst.writeSharedEntry(entry); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", entry.Key, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to sync %s: %s", list, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (im *InstanceManager) syncSharedLists(inst *Instance) error {
+	if inst.Group == "" {
+		return nil
+	}
+
+	im.sharedMu.Lock()
+	defer im.sharedMu.Unlock()
+
+	var errs []string
+	// Removals first, so that an entry added back since is written again.
+	if err := im.applyPendingRemovals(inst); err != nil {
+		errs = append(errs, err.Error())
+	}
+	lists, err := im.SharedLists(inst.Group)
+	if err != nil {
+		return err
+	}
+	for _, shared := range lists {
+		if err := im.syncSharedList(inst, shared.List); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// listChange is a list command seen on a member's console.
+type listChange struct {
+	List   string
+	Add    bool
+	Target string // Player name, or address for ip-bans
+	Reason string
+}
+
+var (
+	// Commands run by a player are echoed to the console as "[Name: ...]".
+	playerCommandRe = regexp.MustCompile(`^\[[^:\]]+: (.*)\]$`)
+
+	listChangePatterns = []struct {
+		re   *regexp.Regexp
+		list string
+		add  bool
+	}{
+		{regexp.MustCompile(`^Banned IP (\S+): (.*)$`), ListIPBans, true},
+		{regexp.MustCompile(`^Unbanned IP (\S+)$`), ListIPBans, false},
+		{regexp.MustCompile(`^Banned (\w{1,16}): (.*)$`), ListBans, true},
+		{regexp.MustCompile(`^Unbanned (\w{1,16})$`), ListBans, false},
+		{regexp.MustCompile(`^Made (\w{1,16}) a server operator$`), ListOps, true},
+		{regexp.MustCompile(`^Made (\w{1,16}) no longer a server operator$`), ListOps, false},
+		{regexp.MustCompile(`^Added (\w{1,16}) to the whitelist$`), ListWhitelist, true},
+		{regexp.MustCompile(`^Removed (\w{1,16}) from the whitelist$`), ListWhitelist, false},
+	}
+)
+
+func parseListChange(message string) (listChange, bool) {
+	if m := playerCommandRe.FindStringSubmatch(message); m != nil {
+		message = m[1]
+	}
+	for _, p := range listChangePatterns {
+		m := p.re.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		change := listChange{List: p.list, Add: p.add, Target: m[1]}
+		if len(m) > 2 {
+			change.Reason = m[2]
+		}
+		return change, true
+	}
+	return listChange{}, false
+}
+
+// watchSharedLists spreads list commands run on inst's console to the rest
+// of its group, and brings inst's lists up to date before every start.
+func (im *InstanceManager) watchSharedLists(inst *Instance) {
+	inst.Manager.AddLogListener(func(line manager.LogLine) {
+		if line.Record == nil {
+			return
+		}
+		if change, ok := parseListChange(line.Record.Message); ok {
+			// Spreading the change runs commands on other servers, so it
+			// is handed off rather than holding up this console.
+			select {
+			case im.sharedQueue <- func() { im.applyConsoleChange(inst, change) }:
+			default:
+				fmt.Printf("Dropped %s change from %s: too many pending\n", change.List, inst.ID)
+			}
+		}
+	})
+	inst.Manager.AddPreStartHook(func() error {
+		return im.syncSharedLists(inst)
+	})
+}
+
+func (im *InstanceManager) runSharedQueue() {
+	for fn := range im.sharedQueue {
+		fn()
+	}
+}
+
+func (im *InstanceManager) applyConsoleChange(inst *Instance, change listChange) {
+	group := inst.Group
+	if group == "" || !im.isShared(group, change.List) {
+		return
+	}
+
+	// Changes JJMC spreads are echoed back by each member; those are
+	// already on the shared list, or already off it.
+	_, exists := im.sharedEntry(group, change.List, listKey(change.List, change.Target))
+	if exists == change.Add {
+		return
+	}
+
+	source := "console:" + inst.ID
+	var err error
+	if change.Add {
+		entry := models.SharedListEntry{List: change.List, Reason: change.Reason, Source: source}
+		if change.List == ListIPBans {
+			entry.IP = change.Target
+		} else {
+			entry.Name = change.Target
+			if profile, err := inst.resolvePlayer(change.Target); err == nil {
+				entry.UUID = profile.UUID
+				entry.Name = profile.Name
+			}
+		}
+		_, err = im.AddSharedEntry(group, entry, inst.ID)
+	} else {
+		_, err = im.RemoveSharedEntry(group, change.List, change.Target, source, inst.ID)
+	}
+	if err != nil {
+		fmt.Printf("Failed to share %s change from %s: %v\n", change.List, inst.ID, err)
+	}
+}
@@ -0,0 +1,223 @@
+package instances
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+	"jjmc/internal/properties"
+)
+
+func TestParseListChange(t *testing.T) {
+	tests := []struct {
+		message string
+		want    listChange
+		ok      bool
+	}{
+		{"Banned Griefer: Banned by an operator.", listChange{List: ListBans, Add: true, Target: "Griefer", Reason: "Banned by an operator."}, true},
+		{"[Mod: Banned Griefer: spam]", listChange{List: ListBans, Add: true, Target: "Griefer", Reason: "spam"}, true},
+		{"Unbanned Griefer", listChange{List: ListBans, Target: "Griefer"}, true},
+		{"Banned IP 10.0.0.7: proxy", listChange{List: ListIPBans, Add: true, Target: "10.0.0.7", Reason: "proxy"}, true},
+		{"Unbanned IP 10.0.0.7", listChange{List: ListIPBans, Target: "10.0.0.7"}, true},
+		{"Made Steve a server operator", listChange{List: ListOps, Add: true, Target: "Steve"}, true},
+		{"Made Steve no longer a server operator", listChange{List: ListOps, Target: "Steve"}, true},
+		{"Added Alex to the whitelist", listChange{List: ListWhitelist, Add: true, Target: "Alex"}, true},
+		{"Removed Alex from the whitelist", listChange{List: ListWhitelist, Target: "Alex"}, true},
+		{"<Steve> Banned Alex: lol", listChange{}, false},
+		{"Player is already whitelisted", listChange{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseListChange(tt.message)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseListChange(%q) = %+v, %v; want %+v, %v", tt.message, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// newSharedGroup loads offline-mode instances into the group "net", so that
+// names resolve without going to Mojang.
+func newSharedGroup(t *testing.T, startCommand string, ids ...string) *InstanceManager {
+	t.Helper()
+	useTestDB(t)
+	t.Chdir(t.TempDir())
+	base := t.TempDir()
+	im := &InstanceManager{
+		instances:   map[string]*Instance{},
+		baseDir:     base,
+		Players:     players.NewTracker(database.DB),
+		portRange:   PortRange{First: 42000, Last: 42100},
+		sharedQueue: make(chan func(), sharedQueueSize),
+	}
+	for _, id := range ids {
+		dir := filepath.Join(base, id)
+		os.MkdirAll(dir, 0755)
+		if err := im.assignPorts(id, dir, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := properties.Update(filepath.Join(dir, PropertiesFile), map[string]string{"online-mode": "false"}); err != nil {
+			t.Fatal(err)
+		}
+		model := models.InstanceModel{ID: id, Name: id, Type: "paper", Group: "net", StartCommand: startCommand}
+		database.DB.Create(&model)
+		inst := im.loadInstance(model)
+		inst.Manager.SetSilent(true)
+		im.instances[id] = inst
+	}
+	return im
+}
+
+func whitelistNames(t *testing.T, inst *Instance) []string {
+	t.Helper()
+	list, err := inst.GetWhitelist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range list {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func changeLog(group, list string) []string {
+	var changes []models.SharedListChange
+	database.DB.Where("group_name = ? AND list = ?", group, list).Order("id").Find(&changes)
+	log := []string{}
+	for _, c := range changes {
+		log = append(log, c.Action+" "+c.Key+" "+c.Source)
+	}
+	return log
+}
+
+func TestSharedListStoppedMembers(t *testing.T) {
+	im := newSharedGroup(t, "", "lobby", "survival")
+	lobby, survival := im.instances["lobby"], im.instances["survival"]
+	if err := lobby.putWhitelistEntry(WhitelistEntry{UUID: players.OfflineUUID("Steve"), Name: "Steve"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sharing merges what the members have and pushes it to all of them.
+	if _, err := im.ShareList("net", ListWhitelist); err != nil {
+		t.Fatal(err)
+	}
+	if names := whitelistNames(t, survival); !reflect.DeepEqual(names, []string{"Steve"}) {
+		t.Errorf("expected Steve to be pushed to survival, got %v", names)
+	}
+
+	results, err := im.AddSharedEntry("net", models.SharedListEntry{List: ListWhitelist, Name: "Alex"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected a result per member, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Error != "" || r.Deferred || r.Live {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	for _, inst := range []*Instance{lobby, survival} {
+		if names := whitelistNames(t, inst); !reflect.DeepEqual(names, []string{"Alex", "Steve"}) {
+			t.Errorf("%s: unexpected whitelist %v", inst.ID, names)
+		}
+	}
+
+	// The member a change came from is left alone.
+	if _, err := im.RemoveSharedEntry("net", ListWhitelist, "Steve", "console:lobby", "lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if names := whitelistNames(t, survival); !reflect.DeepEqual(names, []string{"Alex"}) {
+		t.Errorf("expected Steve off survival, got %v", names)
+	}
+	if names := whitelistNames(t, lobby); !reflect.DeepEqual(names, []string{"Alex", "Steve"}) {
+		t.Errorf("expected lobby to be left alone, got %v", names)
+	}
+
+	want := []string{"add steve import:lobby", "add alex api", "remove steve console:lobby"}
+	if log := changeLog("net", ListWhitelist); !reflect.DeepEqual(log, want) {
+		t.Errorf("unexpected changes %v, want %v", log, want)
+	}
+}
+
+func TestSharedListRetriesFailedRemovals(t *testing.T) {
+	im := newSharedGroup(t, "", "lobby", "survival")
+	survival := im.instances["survival"]
+	if _, err := im.ShareList("net", ListWhitelist); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := im.AddSharedEntry("net", models.SharedListEntry{List: ListWhitelist, Name: "Steve"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// survival's whitelist can't be read, so Steve can't be taken off it.
+	path := filepath.Join(survival.Directory, WhitelistFile)
+	valid, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte("[{"), 0644)
+	results, err := im.RemoveSharedEntry("net", ListWhitelist, "Steve", SourceAPI, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if failed := r.InstanceID == "survival"; failed != (r.Error != "" && r.Deferred) {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	pending, _ := im.PendingRemovals("survival")
+	if len(pending) != 1 || pending[0].Name != "Steve" {
+		t.Fatalf("expected the removal to be kept for survival, got %+v", pending)
+	}
+
+	// Once the file is fixed, the next start takes Steve off.
+	os.WriteFile(path, valid, 0644)
+	if err := im.syncSharedLists(survival); err != nil {
+		t.Fatal(err)
+	}
+	if names := whitelistNames(t, survival); len(names) != 0 {
+		t.Errorf("expected Steve off survival, got %v", names)
+	}
+	if pending, _ := im.PendingRemovals("survival"); len(pending) != 0 {
+		t.Errorf("expected no pending removals, got %+v", pending)
+	}
+}
+
+func TestUnshareList(t *testing.T) {
+	im := newSharedGroup(t, "", "lobby", "survival")
+	if _, err := im.ShareList("net", ListOps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := im.AddSharedEntry("net", models.SharedListEntry{List: ListOps, Name: "Steve", Level: 3}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := im.UnshareList("net", ListOps); err != nil {
+		t.Fatal(err)
+	}
+	if lists, _ := im.SharedLists("net"); len(lists) != 0 {
+		t.Errorf("expected no shared lists, got %+v", lists)
+	}
+	if _, err := im.AddSharedEntry("net", models.SharedListEntry{List: ListOps, Name: "Alex"}, ""); err != ErrListNotShared {
+		t.Errorf("expected ErrListNotShared, got %v", err)
+	}
+
+	// Members keep what they had, and stop being synced.
+	for _, inst := range im.instances {
+		ops, _ := inst.GetOps()
+		if len(ops) != 1 || ops[0].Name != "Steve" || ops[0].Level != 3 {
+			t.Errorf("%s: unexpected ops %+v", inst.ID, ops)
+		}
+		os.Remove(filepath.Join(inst.Directory, OpsFile))
+		if err := im.syncSharedLists(inst); err != nil {
+			t.Fatal(err)
+		}
+		if ops, _ := inst.GetOps(); len(ops) != 0 {
+			t.Errorf("%s: unshared list was synced: %+v", inst.ID, ops)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !windows
+
+package instances
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+// A stand-in server that answers the whitelist and op commands.
+const listServer = `echo 'Done (1.0s)! For help, type "help"'; while read line; do case "$line" in ` +
+	`stop) exit 0;; ` +
+	`"whitelist add "*) echo "Added ${line#whitelist add } to the whitelist";; ` +
+	`"op "*) echo "Made ${line#op } a server operator";; ` +
+	`esac; done`
+
+func TestSharedListRunningMembers(t *testing.T) {
+	im := newSharedGroup(t, listServer, "lobby", "survival")
+	lobby, survival := im.instances["lobby"], im.instances["survival"]
+	for _, list := range []string{ListWhitelist, ListOps} {
+		if _, err := im.ShareList("net", list); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lobby.Manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lobby.Manager.Stop() })
+	waitOnline(t, lobby)
+
+	results, err := im.AddSharedEntry("net", models.SharedListEntry{List: ListWhitelist, Name: "Alex"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		switch r.InstanceID {
+		case "lobby":
+			if !r.Live || !strings.Contains(r.Output, "Added Alex to the whitelist") || r.Deferred {
+				t.Errorf("expected the running member to get the command, got %+v", r)
+			}
+		case "survival":
+			if r.Live || r.Error != "" {
+				t.Errorf("expected the stopped member's file to be written, got %+v", r)
+			}
+		}
+	}
+	if names := whitelistNames(t, survival); !reflect.DeepEqual(names, []string{"Alex"}) {
+		t.Errorf("unexpected whitelist on survival %v", names)
+	}
+	// The running server keeps its own file.
+	if names := whitelistNames(t, lobby); len(names) != 0 {
+		t.Errorf("whitelist.json written under a running server: %v", names)
+	}
+
+	// The op command can't set the level, so the entry waits for a restart.
+	results, err = im.AddSharedEntry("net", models.SharedListEntry{List: ListOps, Name: "Steve", Level: 4}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.InstanceID == "lobby" && (!r.Live || !r.Deferred) {
+			t.Errorf("expected the op level to be deferred, got %+v", r)
+		}
+	}
+
+	if _, err := lobby.Manager.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lobby.Manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitOnline(t, lobby)
+	ops, _ := lobby.GetOps()
+	if len(ops) != 1 || ops[0].Name != "Steve" || ops[0].Level != 4 {
+		t.Errorf("expected Steve at level 4 after the restart, got %+v", ops)
+	}
+	if names := whitelistNames(t, lobby); !reflect.DeepEqual(names, []string{"Alex"}) {
+		t.Errorf("unexpected whitelist on lobby after the restart %v", names)
+	}
+}
+
+func TestShareListWithRunningMember(t *testing.T) {
+	im := newSharedGroup(t, listServer, "lobby", "survival")
+	lobby, survival := im.instances["lobby"], im.instances["survival"]
+	if err := lobby.putWhitelistEntry(WhitelistEntry{UUID: players.OfflineUUID("Alex"), Name: "Alex"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := survival.putWhitelistEntry(WhitelistEntry{UUID: players.OfflineUUID("Steve"), Name: "Steve"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lobby.Manager.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lobby.Manager.Stop() })
+	waitOnline(t, lobby)
+
+	if _, err := im.ShareList("net", ListWhitelist); err != nil {
+		t.Fatal(err)
+	}
+
+	// The running member hears of Steve through its console, and isn't told
+	// about Alex, whom it already has.
+	if !consoleOrder(lobby.Manager, "Added Steve to the whitelist") {
+		t.Error("expected Steve to be sent to the running member")
+	}
+	if consoleOrder(lobby.Manager, "Added Alex to the whitelist") {
+		t.Error("Alex was sent to the member he came from")
+	}
+	if names := whitelistNames(t, lobby); !reflect.DeepEqual(names, []string{"Alex"}) {
+		t.Errorf("whitelist.json written under a running server: %v", names)
+	}
+	if names := whitelistNames(t, survival); !reflect.DeepEqual(names, []string{"Alex", "Steve"}) {
+		t.Errorf("unexpected whitelist on survival %v", names)
+	}
+}
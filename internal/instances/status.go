@@ -0,0 +1,117 @@
+package instances
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"jjmc/internal/manager"
+	"jjmc/pkg/mcping"
+)
+
+const (
+	healthProbeInterval = 30 * time.Second
+	pingTimeout         = 3 * time.Second
+)
+
+// ServerStatus is what the server reports about itself over the Server List
+// Ping, together with how the probe went.
+type ServerStatus struct {
+	State     string          `json:"state"`
+	Port      int             `json:"port"`
+	Reachable bool            `json:"reachable"`
+	MOTD      string          `json:"motd,omitempty"`
+	Version   *mcping.Version `json:"version,omitempty"`
+	Players   *mcping.Players `json:"players,omitempty"`
+	LatencyMs int64           `json:"latencyMs,omitempty"`
+	Legacy    bool            `json:"legacy,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CheckedAt time.Time       `json:"checkedAt"`
+}
+
+type healthState struct {
+	mu     sync.Mutex
+	last   *ServerStatus
+	failed bool
+}
+
+// Probe pings the server on the port from server.properties. Servers that
+// are not online are reported as unreachable without being pinged.
+func (inst *Instance) Probe() ServerStatus {
+	state := inst.Manager.GetState()
+	status := ServerStatus{
+		State:     string(state),
+		Port:      GetServerPort(inst.Directory),
+		CheckedAt: time.Now(),
+	}
+	if state != manager.StateOnline {
+		inst.health.mu.Lock()
+		inst.health.failed = false
+		inst.health.mu.Unlock()
+		return status
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(status.Port))
+	result, err := mcping.Ping(addr, pingTimeout)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Reachable = true
+		status.MOTD = result.MOTD
+		status.Version = &result.Version
+		status.Players = &result.Players
+		status.LatencyMs = result.Latency.Milliseconds()
+		status.Legacy = result.Legacy
+	}
+
+	inst.recordHealth(status)
+	return status
+}
+
+// LastStatus returns the result of the most recent probe, if any.
+func (inst *Instance) LastStatus() (ServerStatus, bool) {
+	inst.health.mu.Lock()
+	defer inst.health.mu.Unlock()
+	if inst.health.last == nil {
+		return ServerStatus{}, false
+	}
+	return *inst.health.last, true
+}
+
+func (inst *Instance) recordHealth(status ServerStatus) {
+	inst.health.mu.Lock()
+	inst.health.last = &status
+	wasFailing := inst.health.failed
+	inst.health.failed = !status.Reachable
+	inst.health.mu.Unlock()
+
+	if !status.Reachable && !wasFailing {
+		inst.Manager.Broadcast(fmt.Sprintf("Server is not answering status pings on port %d: %s", status.Port, status.Error))
+	} else if status.Reachable && wasFailing {
+		inst.Manager.Broadcast(fmt.Sprintf("Server is answering status pings on port %d again", status.Port))
+	}
+}
+
+// runHealthProbes pings every online instance periodically so that hung
+// servers show up in the console and in LastStatus.
+func (im *InstanceManager) runHealthProbes() {
+	ticker := time.NewTicker(healthProbeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		im.mu.RLock()
+		online := make([]*Instance, 0, len(im.instances))
+		for _, inst := range im.instances {
+			if inst.Manager.GetState() == manager.StateOnline {
+				online = append(online, inst)
+			}
+		}
+		im.mu.RUnlock()
+
+		for _, inst := range online {
+			go inst.Probe()
+		}
+	}
+}
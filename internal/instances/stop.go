@@ -0,0 +1,75 @@
+package instances
+
+import (
+	"fmt"
+	"time"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+)
+
+const defaultStopTimeout = 60
+
+// applyStopSettings hands the stop settings to the manager. An empty command
+// or a zero timeout leaves the manager to use its defaults.
+func (inst *Instance) applyStopSettings() {
+	inst.Manager.SetStopCommand(inst.StopCommand)
+	inst.Manager.SetStopTimeout(time.Duration(inst.StopTimeout) * time.Second)
+}
+
+// stopCommandFor falls back to the template's stop command for instances
+// created before it was stored on the model.
+func (im *InstanceManager) stopCommandFor(model models.InstanceModel) string {
+	if model.StopCommand != "" || im.TemplateMgr == nil {
+		return model.StopCommand
+	}
+	if tmpl, ok := im.TemplateMgr.GetTemplate(model.Type); ok {
+		return tmpl.Run.Stop
+	}
+	return ""
+}
+
+// UpdateStopSettings changes the settings that are given and leaves nil ones
+// as they are. An empty command goes back to the template's, and a timeout
+// of 0 to the default.
+func (im *InstanceManager) UpdateStopSettings(id string, stopCommand *string, stopTimeout *int) error {
+	if stopTimeout != nil && *stopTimeout < 0 {
+		return fmt.Errorf("stop timeout must not be negative")
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	inst, ok := im.instances[id]
+	if !ok {
+		return fmt.Errorf("instance not found")
+	}
+
+	updates := map[string]interface{}{}
+	command, timeout := inst.StopCommand, inst.StopTimeout
+	if stopCommand != nil {
+		updates["stop_command"] = *stopCommand
+		command = im.stopCommandFor(models.InstanceModel{Type: inst.Type, StopCommand: *stopCommand})
+	}
+	if stopTimeout != nil {
+		timeout = *stopTimeout
+		if timeout == 0 {
+			timeout = defaultStopTimeout
+		}
+		updates["stop_timeout"] = timeout
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := database.DB.Model(&models.InstanceModel{}).Where("id = ?", id).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to update db: %v", err)
+	}
+
+	inst.StopCommand = command
+	inst.StopTimeout = timeout
+	inst.applyStopSettings()
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package instances
+
+import (
+	"testing"
+
+	"jjmc/internal/database"
+	"jjmc/internal/models"
+	"jjmc/internal/players"
+)
+
+func TestUpdateStopSettings(t *testing.T) {
+	useTestDB(t)
+	im := &InstanceManager{instances: map[string]*Instance{}, baseDir: t.TempDir(), Players: players.NewTracker(database.DB)}
+	model := models.InstanceModel{ID: "smp", Name: "SMP", Type: "paper", StopCommand: "end", StopTimeout: 30}
+	database.DB.Create(&model)
+	im.instances["smp"] = im.loadInstance(model)
+
+	stored := func() models.InstanceModel {
+		var m models.InstanceModel
+		database.DB.First(&m, "id = ?", "smp")
+		return m
+	}
+
+	// Only the timeout is sent, so the command stays.
+	timeout := 90
+	if err := im.UpdateStopSettings("smp", nil, &timeout); err != nil {
+		t.Fatal(err)
+	}
+	inst := im.instances["smp"]
+	if m := stored(); m.StopCommand != "end" || m.StopTimeout != 90 || inst.StopCommand != "end" || inst.StopTimeout != 90 {
+		t.Errorf("unexpected settings: stored %q/%d, in memory %q/%d", m.StopCommand, m.StopTimeout, inst.StopCommand, inst.StopTimeout)
+	}
+
+	// Clearing both goes back to the defaults, in the database and in memory.
+	command, timeout := "", 0
+	if err := im.UpdateStopSettings("smp", &command, &timeout); err != nil {
+		t.Fatal(err)
+	}
+	if m := stored(); m.StopCommand != "" || m.StopTimeout != defaultStopTimeout || inst.StopCommand != "" || inst.StopTimeout != defaultStopTimeout {
+		t.Errorf("unexpected settings: stored %q/%d, in memory %q/%d", m.StopCommand, m.StopTimeout, inst.StopCommand, inst.StopTimeout)
+	}
+
+	timeout = -1
+	if err := im.UpdateStopSettings("smp", nil, &timeout); err == nil {
+		t.Error("expected a negative timeout to be refused")
+	}
+}
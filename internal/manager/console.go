@@ -1,56 +1,188 @@
 package manager
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
-func (m *Manager) handleBroadcast() {
-	for msg := range m.broadcast {
-		m.mu.Lock()
+type QueuePolicy int
 
-		m.logBuffer = append(m.logBuffer, msg)
-		if len(m.logBuffer) > 100 {
-			m.logBuffer = m.logBuffer[1:]
-		}
+const (
+	// Disconnect closes a client whose queue is full. Meant for interactive
+	// clients that can reconnect and catch up from the scrollback.
+	Disconnect QueuePolicy = iota
+	// DropOldest discards the oldest queued line to make room for a new one.
+	DropOldest
+)
+
+const defaultQueueSize = 256
+
+type FrameFormat int
+
+const (
+	// FormatText sends each line as its raw text.
+	FormatText FrameFormat = iota
+	// FormatJSON sends each line as a JSON-encoded LogLine.
+	FormatJSON
+)
 
-		for client := range m.clients {
+type SubscriberOptions struct {
+	QueueSize int
+	Policy    QueuePolicy
+	Format    FrameFormat
+	Replay    bool   // Send the scrollback before live output
+	Since     uint64 // With Replay, only lines after this sequence number
+}
 
-			if err := client.WriteMessage(1, []byte(msg)); err != nil {
+// subscriber decouples a ConsoleClient from the output stream: publishing
+// only ever does a non-blocking send into the queue, and a dedicated
+// goroutine drains it into the (possibly slow) client.
+type subscriber struct {
+	client ConsoleClient
+	queue  chan LogLine
+	policy QueuePolicy
+	format FrameFormat
+	done   chan struct{}
+	once   sync.Once
+}
 
-				delete(m.clients, client)
+// offer queues data without blocking. It reports false if the subscriber
+// should be disconnected.
+func (s *subscriber) offer(line LogLine) bool {
+	select {
+	case s.queue <- line:
+		return true
+	default:
+	}
+
+	if s.policy == Disconnect {
+		return false
+	}
+
+	select {
+	case <-s.queue:
+	default:
+	}
+	select {
+	case s.queue <- line:
+	default:
+	}
+	return true
+}
 
-				if closer, ok := client.(io.Closer); ok {
-					closer.Close()
-				}
+func (s *subscriber) run(m *Manager) {
+	for {
+		select {
+		case line := <-s.queue:
+			if err := s.client.WriteMessage(1, s.encode(line)); err != nil {
+				m.UnregisterClient(s.client)
+				return
 			}
+		case <-s.done:
+			return
 		}
-		m.mu.Unlock()
+	}
+}
+
+func (s *subscriber) encode(line LogLine) []byte {
+	if s.format == FormatJSON {
+		data, _ := json.Marshal(line)
+		return data
+	}
+	return []byte(line.Text)
+}
+
+func (s *subscriber) close() {
+	s.once.Do(func() {
+		close(s.done)
+		if closer, ok := s.client.(io.Closer); ok {
+			closer.Close()
+		}
+	})
+}
+
+// publish appends text to the scrollback and hands it to every subscriber.
+// It never blocks on a client, so it is safe to call from the stdout reader.
+func (m *Manager) publish(stream, text string) {
+	m.consoleMu.Lock()
+	defer m.consoleMu.Unlock()
+
+	line := m.scrollback.add(stream, text, time.Now())
+	for client, sub := range m.clients {
+		if !sub.offer(line) {
+			delete(m.clients, client)
+			sub.close()
+		}
+	}
+
+	select {
+	case m.logEvents <- line:
+	default:
+		// Listeners are hopelessly behind; losing a line beats stalling stdout.
 	}
 }
 
 func (m *Manager) RegisterClient(client ConsoleClient) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.clients[client] = true
+	m.RegisterClientWithOptions(client, SubscriberOptions{
+		QueueSize: defaultQueueSize,
+		Policy:    Disconnect,
+		Replay:    true,
+	})
+}
+
+func (m *Manager) RegisterClientWithOptions(client ConsoleClient, opts SubscriberOptions) {
+	m.consoleMu.Lock()
+	defer m.consoleMu.Unlock()
+
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	var backlog []LogLine
+	if opts.Replay {
+		backlog = m.scrollback.since(opts.Since)
+	}
+
+	sub := &subscriber{
+		client: client,
+		queue:  make(chan LogLine, opts.QueueSize+len(backlog)),
+		policy: opts.Policy,
+		format: opts.Format,
+		done:   make(chan struct{}),
+	}
+	// Queued under consoleMu, so nothing published concurrently can slip in
+	// between the backlog and the live lines, nor appear in both.
+	for _, line := range backlog {
+		sub.queue <- line
+	}
 
-	for _, line := range m.logBuffer {
-		client.WriteMessage(1, []byte(line))
+	if old, ok := m.clients[client]; ok {
+		old.close()
 	}
+	m.clients[client] = sub
+	go sub.run(m)
 }
 
 func (m *Manager) UnregisterClient(client ConsoleClient) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.consoleMu.Lock()
+	sub, ok := m.clients[client]
 	delete(m.clients, client)
-	if closer, ok := client.(io.Closer); ok {
+	m.consoleMu.Unlock()
+
+	if ok {
+		sub.close()
+	} else if closer, ok := client.(io.Closer); ok {
 		closer.Close()
 	}
 }
 
 func (m *Manager) Broadcast(msg string) {
-	m.broadcast <- msg
+	m.publish(StreamSystem, msg)
 }
 
 type executeCapture struct {
@@ -66,9 +198,16 @@ func (e *executeCapture) WriteMessage(messageType int, data []byte) error {
 	return nil
 }
 
+// ExecuteCommand runs cmd and returns its output. Once the server is online
+// this goes over RCON, which returns exactly the command's response. Before
+// that, or without RCON, the command is written to stdin and whatever the
+// console prints within timeout is returned instead.
 func (m *Manager) ExecuteCommand(cmd string, timeout time.Duration) (string, error) {
-	if err := m.WriteCommand(cmd); err != nil {
-		return "", err
+	if m.GetState() == StateOnline {
+		output, sent, err := m.rconCommand(cmd)
+		if sent {
+			return output, err
+		}
 	}
 
 	capture := &executeCapture{
@@ -76,9 +215,14 @@ func (m *Manager) ExecuteCommand(cmd string, timeout time.Duration) (string, err
 		done:   make(chan struct{}),
 	}
 
-	m.RegisterClient(capture)
+	// Subscribe before sending so fast responses are not missed.
+	m.RegisterClientWithOptions(capture, SubscriberOptions{QueueSize: 100, Policy: DropOldest})
 	defer m.UnregisterClient(capture)
 
+	if err := m.WriteCommand(cmd); err != nil {
+		return "", err
+	}
+
 	var output []string
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
@@ -98,3 +242,56 @@ Loop:
 
 	return strings.Join(output, "\n"), nil
 }
+
+// lineWaiter is a console client that picks out the first line matching a
+// pattern.
+type lineWaiter struct {
+	match *regexp.Regexp
+	found chan string
+}
+
+func (w *lineWaiter) WriteMessage(messageType int, data []byte) error {
+	if w.match.Match(data) {
+		select {
+		case w.found <- string(data):
+		default:
+		}
+	}
+	return nil
+}
+
+// ExecuteAndWait runs cmd and waits up to timeout for a response matching
+// match, either from RCON or printed to the console, and returns it.
+func (m *Manager) ExecuteAndWait(cmd string, match *regexp.Regexp, timeout time.Duration) (string, error) {
+	waiter := &lineWaiter{match: match, found: make(chan string, 1)}
+
+	// Subscribe before sending so fast responses are not missed.
+	m.RegisterClientWithOptions(waiter, SubscriberOptions{QueueSize: 100, Policy: DropOldest})
+	defer m.UnregisterClient(waiter)
+
+	sent := false
+	if m.GetState() == StateOnline {
+		output, ok, err := m.rconCommand(cmd)
+		if ok && err != nil {
+			return "", err
+		}
+		if ok && match.MatchString(output) {
+			return output, nil
+		}
+		sent = ok
+	}
+	if !sent {
+		if err := m.WriteCommand(cmd); err != nil {
+			return "", err
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case line := <-waiter.found:
+		return line, nil
+	case <-timer.C:
+		return "", fmt.Errorf("no response to %q within %s", cmd, timeout)
+	}
+}
@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowClient blocks every write until release is closed, standing in for a
+// telnet or websocket peer that has stopped reading.
+type slowClient struct {
+	release chan struct{}
+	mu      sync.Mutex
+	lines   []string
+	closed  bool
+}
+
+func newSlowClient() *slowClient {
+	return &slowClient{release: make(chan struct{})}
+}
+
+func (c *slowClient) WriteMessage(messageType int, data []byte) error {
+	<-c.release
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, string(data))
+	return nil
+}
+
+func (c *slowClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *slowClient) snapshot() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...), c.closed
+}
+
+type fastClient struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *fastClient) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, string(data))
+	return nil
+}
+
+func (c *fastClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.lines)
+}
+
+func publishWithDeadline(t *testing.T, m *Manager, n int) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < n; i++ {
+			m.publish(StreamStdout, fmt.Sprintf("line %d", i))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publish blocked on a slow client")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPublishDisconnectsSlowClient(t *testing.T) {
+	m := NewManager()
+	slow := newSlowClient()
+	defer close(slow.release)
+	m.RegisterClientWithOptions(slow, SubscriberOptions{QueueSize: 8, Policy: Disconnect})
+
+	publishWithDeadline(t, m, 1000)
+
+	m.consoleMu.Lock()
+	_, stillRegistered := m.clients[slow]
+	m.consoleMu.Unlock()
+	if stillRegistered {
+		t.Error("expected slow client to be disconnected")
+	}
+	if _, closed := slow.snapshot(); !closed {
+		t.Error("expected slow client to be closed")
+	}
+}
+
+func TestPublishDropsOldestForSlowClient(t *testing.T) {
+	m := NewManager()
+	slow := newSlowClient()
+	m.RegisterClientWithOptions(slow, SubscriberOptions{QueueSize: 4, Policy: DropOldest})
+
+	publishWithDeadline(t, m, 100)
+	close(slow.release)
+
+	waitFor(t, func() bool {
+		lines, _ := slow.snapshot()
+		return len(lines) > 0 && lines[len(lines)-1] == "line 99"
+	})
+
+	lines, closed := slow.snapshot()
+	if closed {
+		t.Error("DropOldest client should stay connected")
+	}
+	// The writer may have picked up one line before blocking, plus a full queue.
+	if len(lines) > 5 {
+		t.Errorf("expected at most 5 lines after dropping, got %d", len(lines))
+	}
+}
+
+func TestSlowClientDoesNotDelayOthers(t *testing.T) {
+	m := NewManager()
+	slow := newSlowClient()
+	defer close(slow.release)
+	fast := &fastClient{}
+
+	m.RegisterClientWithOptions(slow, SubscriberOptions{QueueSize: 4, Policy: DropOldest})
+	m.RegisterClientWithOptions(fast, SubscriberOptions{QueueSize: 1000, Policy: Disconnect})
+
+	publishWithDeadline(t, m, 500)
+
+	waitFor(t, func() bool { return fast.count() == 500 })
+}
+
+func TestRegisterClientReplaysScrollback(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < 3; i++ {
+		m.publish(StreamStdout, fmt.Sprintf("line %d", i))
+	}
+
+	fast := &fastClient{}
+	m.RegisterClient(fast)
+	m.publish(StreamStdout, "line 3")
+
+	waitFor(t, func() bool { return fast.count() == 4 })
+	fast.mu.Lock()
+	defer fast.mu.Unlock()
+	for i, line := range fast.lines {
+		if want := fmt.Sprintf("line %d", i); line != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, line)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package manager
+
+import "fmt"
+
+// LogListener receives every console line, parsed, in order. Listeners run
+// on a single dispatcher goroutine per manager, so a slow listener delays
+// the others but never the server's output.
+type LogListener func(line LogLine)
+
+// PreStartHook runs before the server process is launched, without the
+// manager's lock held. Its error is logged but does not stop the start.
+type PreStartHook func() error
+
+// StartCheck runs before the server process is launched, after the
+// pre-start hooks. An error aborts the start and is returned by Start.
+type StartCheck func() error
+
+const logEventBuffer = 4096
+
+func (m *Manager) AddLogListener(fn LogListener) {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	m.logListeners = append(m.logListeners, fn)
+}
+
+func (m *Manager) dispatchLogEvents() {
+	for {
+		select {
+		case line := <-m.logEvents:
+			m.listenersMu.Lock()
+			listeners := m.logListeners
+			m.listenersMu.Unlock()
+
+			for _, fn := range listeners {
+				fn(line)
+			}
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+func (m *Manager) AddPreStartHook(fn PreStartHook) {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	m.preStartHooks = append(m.preStartHooks, fn)
+}
+
+func (m *Manager) runPreStartHooks() {
+	m.listenersMu.Lock()
+	hooks := m.preStartHooks
+	m.listenersMu.Unlock()
+
+	for _, fn := range hooks {
+		if err := fn(); err != nil {
+			fmt.Printf("Pre-start hook failed: %v\n", err)
+		}
+	}
+}
+
+func (m *Manager) AddStartCheck(fn StartCheck) {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	m.startChecks = append(m.startChecks, fn)
+}
+
+func (m *Manager) runStartChecks() error {
+	m.listenersMu.Lock()
+	checks := m.startChecks
+	m.listenersMu.Unlock()
+
+	for _, fn := range checks {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
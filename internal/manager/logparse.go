@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LogRecord is a console line split into the fields of the common log4j
+// layouts used by Vanilla, Paper, Forge and Velocity.
+type LogRecord struct {
+	Time    string `json:"time"`
+	Thread  string `json:"thread,omitempty"`
+	Level   string `json:"level"`
+	Logger  string `json:"logger,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// Vanilla / Forge / NeoForge:
+	//   [12:00:00] [Server thread/INFO]: Message
+	//   [12:00:00] [Server thread/INFO] [minecraft/DedicatedServer]: Message
+	//   [14Oct2024 12:00:00.123] [Server thread/INFO] [net.minecraft.server.MinecraftServer/]: Message
+	threadLayoutRe = regexp.MustCompile(`^\[([^\]]+)\] \[(.+)/([A-Z]+)\](?: \[([^\]]*)\])?: ?(.*)$`)
+
+	// Paper / Spigot / Velocity:
+	//   [12:00:00 INFO]: Message
+	//   [12:00:00 INFO]: [PluginName] Message
+	//   [12:00:00 INFO] [LoggerName]: Message
+	levelLayoutRe = regexp.MustCompile(`^\[([0-9:.]+) ([A-Z]+)\](?: \[([^\]]*)\])?: ?(.*)$`)
+
+	pluginPrefixRe = regexp.MustCompile(`^\[([A-Za-z0-9_.-]+)\] (.*)$`)
+)
+
+// ParseLogLine parses line into a LogRecord. It reports false for lines that
+// don't follow a known layout, such as stack traces or JJMC's own messages.
+func ParseLogLine(line string) (LogRecord, bool) {
+	line = ansiRe.ReplaceAllString(line, "")
+
+	if match := threadLayoutRe.FindStringSubmatch(line); match != nil {
+		return LogRecord{
+			Time:    match[1],
+			Thread:  match[2],
+			Level:   match[3],
+			Logger:  strings.TrimSuffix(match[4], "/"),
+			Message: match[5],
+		}, true
+	}
+
+	if match := levelLayoutRe.FindStringSubmatch(line); match != nil {
+		record := LogRecord{
+			Time:    match[1],
+			Level:   match[2],
+			Logger:  match[3],
+			Message: match[4],
+		}
+		// Paper prints plugin messages as "[12:00:00 INFO]: [Plugin] ...".
+		if record.Logger == "" {
+			if prefix := pluginPrefixRe.FindStringSubmatch(record.Message); prefix != nil {
+				record.Logger = prefix[1]
+				record.Message = prefix[2]
+			}
+		}
+		return record, true
+	}
+
+	return LogRecord{}, false
+}
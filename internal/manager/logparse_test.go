@@ -0,0 +1,62 @@
+package manager
+
+import "testing"
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want LogRecord
+	}{
+		{
+			line: "[12:00:00] [Server thread/INFO]: Steve joined the game",
+			want: LogRecord{Time: "12:00:00", Thread: "Server thread", Level: "INFO", Message: "Steve joined the game"},
+		},
+		{
+			line: "[12:00:00] [Server thread/INFO] [minecraft/DedicatedServer]: Done (5.123s)! For help, type \"help\"",
+			want: LogRecord{Time: "12:00:00", Thread: "Server thread", Level: "INFO", Logger: "minecraft/DedicatedServer", Message: "Done (5.123s)! For help, type \"help\""},
+		},
+		{
+			line: "[14Oct2024 12:00:00.123] [Server thread/WARN] [net.minecraft.server.MinecraftServer/]: Can't keep up!",
+			want: LogRecord{Time: "14Oct2024 12:00:00.123", Thread: "Server thread", Level: "WARN", Logger: "net.minecraft.server.MinecraftServer", Message: "Can't keep up!"},
+		},
+		{
+			line: "[12:00:00 ERROR]: Could not pass event",
+			want: LogRecord{Time: "12:00:00", Level: "ERROR", Message: "Could not pass event"},
+		},
+		{
+			line: "[12:00:00 INFO]: [LuckPerms] Loading configuration...",
+			want: LogRecord{Time: "12:00:00", Level: "INFO", Logger: "LuckPerms", Message: "Loading configuration..."},
+		},
+		{
+			line: "[12:00:00 INFO] [velocity]: Listening on /0.0.0.0:25577",
+			want: LogRecord{Time: "12:00:00", Level: "INFO", Logger: "velocity", Message: "Listening on /0.0.0.0:25577"},
+		},
+		{
+			line: "\x1b[32m[12:00:00 INFO]: <Steve> hello\x1b[0m",
+			want: LogRecord{Time: "12:00:00", Level: "INFO", Message: "<Steve> hello"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseLogLine(tt.line)
+		if !ok {
+			t.Errorf("ParseLogLine(%q) failed to parse", tt.line)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLogLine(%q)\n got  %+v\n want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLogLineRejectsUnstructured(t *testing.T) {
+	for _, line := range []string{
+		"\tat net.minecraft.server.Main.main(Main.java:123)",
+		"Installing template: Paper (Version 1.20.4)",
+		"",
+	} {
+		if _, ok := ParseLogLine(line); ok {
+			t.Errorf("expected %q not to parse", line)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package manager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogArchiveDir is where rotated server.log files end up, relative to the
+// instance directory. It is kept apart from the server's own logs/ folder.
+const LogArchiveDir = "jjmc-logs"
+
+// StderrLogFile is where the server's stderr goes, next to server.log, so
+// that its lines can still be told apart on the console.
+const StderrLogFile = "server.stderr.log"
+
+type LogRotation struct {
+	MaxSize   int64         // Rotate once server.log grows past this many bytes, 0 disables
+	MaxAge    time.Duration // Rotate once server.log is older than this, 0 disables
+	Retention int           // Compressed archives to keep, 0 keeps all
+}
+
+var defaultLogRotation = LogRotation{
+	MaxSize:   100 * 1024 * 1024,
+	MaxAge:    24 * time.Hour,
+	Retention: 14,
+}
+
+func (m *Manager) SetLogRotation(policy LogRotation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logRotation = policy
+}
+
+// logRotator archives server.log into LogArchiveDir as gzip files once it
+// gets too large or too old. The server process writes to server.log
+// directly so that it keeps logging while the panel is down, which means the
+// file cannot be renamed from under it: it is copied and truncated instead.
+type logRotator struct {
+	path   string
+	policy LogRotation
+	since  time.Time // When the current log was started
+}
+
+// rotateHook runs between the bulk copy of a rotation and catching up with
+// what was written meanwhile. Tests use it to write during a rotation.
+var rotateHook func()
+
+func newLogRotator(path string, policy LogRotation) *logRotator {
+	return &logRotator{path: path, policy: policy, since: time.Now()}
+}
+
+// rotateStale archives a log left over from a previous run that is already
+// past its age limit, which would otherwise only be rotated after another
+// full period. It must only be called while the server is not running.
+func (r *logRotator) rotateStale() error {
+	info, err := os.Stat(r.path)
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	if r.policy.MaxAge <= 0 || time.Since(info.ModTime()) <= r.policy.MaxAge {
+		return nil
+	}
+	_, err = r.rotate(info.Size())
+	return err
+}
+
+func (r *logRotator) due(size int64) bool {
+	if size == 0 {
+		return false
+	}
+	if r.policy.MaxSize > 0 && size > r.policy.MaxSize {
+		return true
+	}
+	return r.policy.MaxAge > 0 && time.Since(r.since) > r.policy.MaxAge
+}
+
+// rotate writes a gzip copy of the log into LogArchiveDir and truncates it.
+// The server keeps appending during the copy, so what it wrote meanwhile is
+// copied too, right before truncating through the same open file. Only a
+// write landing between that last read and the truncate can still be lost,
+// as a file the server appends to can't be cut any more atomically. The log
+// from offset from on, which the caller hasn't read yet, is returned so that
+// it still reaches the console. On error the log is left as it was.
+func (r *logRotator) rotate(from int64) ([]byte, error) {
+	dir := filepath.Join(filepath.Dir(r.path), LogArchiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	in, err := os.OpenFile(r.path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	base := strings.TrimSuffix(filepath.Base(r.path), ".log")
+	stamp := time.Now().Format("2006-01-02_15-04-05")
+	target := filepath.Join(dir, fmt.Sprintf("%s-%s.log.gz", base, stamp))
+	for i := 1; fileExists(target); i++ {
+		target = filepath.Join(dir, fmt.Sprintf("%s-%s-%d.log.gz", base, stamp, i))
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) ([]byte, error) {
+		out.Close()
+		os.Remove(target)
+		return nil, err
+	}
+
+	zw := gzip.NewWriter(out)
+	n, err := io.Copy(zw, in)
+	if err != nil {
+		return fail(err)
+	}
+	var unread bytes.Buffer
+	if from < n {
+		if _, err := io.Copy(&unread, io.NewSectionReader(in, from, n-from)); err != nil {
+			return fail(err)
+		}
+	}
+
+	if rotateHook != nil {
+		rotateHook()
+	}
+	if _, err := io.Copy(io.MultiWriter(zw, &unread), in); err != nil {
+		return fail(err)
+	}
+	if err := in.Truncate(0); err != nil {
+		return fail(err)
+	}
+	r.since = time.Now()
+
+	// The log is already emptied, so a broken archive is only reported.
+	if err := zw.Close(); err != nil {
+		fmt.Printf("Failed to write %s: %v\n", target, err)
+	}
+	if err := out.Close(); err != nil {
+		fmt.Printf("Failed to write %s: %v\n", target, err)
+	}
+	pruneLogArchives(dir, base, r.policy.Retention)
+	return unread.Bytes(), nil
+}
+
+func pruneLogArchives(dir, base string, retention int) {
+	if retention <= 0 {
+		return
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(dir, base+"-*.log.gz"))
+	if len(archives) <= retention {
+		return
+	}
+
+	modTimes := make(map[string]time.Time, len(archives))
+	for _, path := range archives {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		return modTimes[archives[i]].Before(modTimes[archives[j]])
+	})
+	for _, path := range archives[:len(archives)-retention] {
+		os.Remove(path)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFollowLogRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+
+	m := NewManager()
+	fast := &fastClient{}
+	m.RegisterClient(fast)
+
+	done := make(chan struct{})
+	followed := make(chan struct{})
+	go func() {
+		m.followLog(path, StreamStdout, 0, newLogRotator(path, LogRotation{MaxSize: 100, Retention: 2}), done)
+		close(followed)
+	}()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(f, "line %02d of the log\n", i)
+		if i%5 == 4 {
+			// Give the follower a chance to rotate in between.
+			waitFor(t, func() bool { return fast.count() == i+1 })
+		}
+	}
+	close(done)
+	<-followed
+
+	fast.mu.Lock()
+	lines := append([]string(nil), fast.lines...)
+	fast.mu.Unlock()
+	if len(lines) != 40 {
+		t.Fatalf("expected 40 lines on the console, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if want := fmt.Sprintf("line %02d of the log", i); line != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, line)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("server.log missing after rotation: %v", err)
+	}
+	if info.Size() > 100 {
+		t.Errorf("expected server.log below the size limit, got %d bytes", info.Size())
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(dir, LogArchiveDir, "server-*.log.gz"))
+	if len(archives) != 2 {
+		t.Fatalf("expected 2 archives after pruning, got %d", len(archives))
+	}
+
+	a, err := os.Open(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	zr, err := gzip.NewReader(a)
+	if err != nil {
+		t.Fatalf("archive is not gzip: %v", err)
+	}
+	data, _ := io.ReadAll(zr)
+	if !strings.HasPrefix(string(data), "line ") {
+		t.Errorf("unexpected archive content: %q", data)
+	}
+}
+
+func TestRotateKeepsLinesWrittenDuringRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "line 0\nline 1\n")
+
+	// The server writes while the first part is being archived.
+	rotateHook = func() { fmt.Fprintf(f, "line 2\nline 3\n") }
+	defer func() { rotateHook = nil }()
+
+	// line 0 was already on the console.
+	unread, err := newLogRotator(path, LogRotation{}).rotate(int64(len("line 0\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unread) != "line 1\nline 2\nline 3\n" {
+		t.Errorf("unexpected unread output %q", unread)
+	}
+
+	fmt.Fprintf(f, "line 4\n")
+	rest, _ := os.ReadFile(path)
+	if string(rest) != "line 4\n" {
+		t.Errorf("expected server.log to start over, got %q", rest)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(dir, LogArchiveDir, "server-*.log.gz"))
+	if len(archives) != 1 {
+		t.Fatalf("expected 1 archive, got %d", len(archives))
+	}
+	a, err := os.Open(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	zr, err := gzip.NewReader(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "line 0\nline 1\nline 2\nline 3\n" {
+		t.Errorf("unexpected archive content %q", data)
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"jjmc/pkg/mcrcon"
+
 	"github.com/gofiber/contrib/websocket"
 )
 
@@ -20,10 +25,23 @@ type ConsoleClient interface {
 }
 
 type Manager struct {
-	cmd     *exec.Cmd
-	tailCmd *exec.Cmd
-	stdin   io.WriteCloser
-	clients map[ConsoleClient]bool
+	cmd   *exec.Cmd
+	stdin io.WriteCloser // console.in, or a plain pipe on Windows
+
+	rcon   *mcrcon.Client
+	rconMu sync.Mutex
+
+	// Console fan-out, guarded by consoleMu rather than mu so that
+	// publishing output never waits on lifecycle operations.
+	clients    map[ConsoleClient]*subscriber
+	scrollback *scrollback
+	consoleMu  sync.Mutex
+
+	logListeners  []LogListener
+	logEvents     chan LogLine
+	preStartHooks []PreStartHook
+	startChecks   []StartCheck
+	listenersMu   sync.Mutex
 
 	// Stats
 	StatsClients   map[*websocket.Conn]bool
@@ -37,14 +55,36 @@ type Manager struct {
 	maxMemory    int
 	javaArgs     string
 	javaPath     string
+	stopCommand  string
+	stopTimeout  time.Duration
+
+	pid    int
+	silent bool
 
-	broadcast chan string
-	logBuffer []string
-	pid       int
-	silent    bool
+	// Lifecycle
+	state          State
+	stopRequested  bool
+	stateEvents    []StateEvent  // Queued for handleStateBroadcast, guarded by mu
+	stateSignal    chan struct{} // Wakes handleStateBroadcast
+	stateClients   map[*websocket.Conn]*stateSubscriber
+	stateListeners []*stateSubscriber
+	stateMu        sync.Mutex
 
 	webhookURL string
 
+	exited      chan struct{} // Closed by reap once the process has gone away
+	exitHandled chan struct{} // Closed by reap once handleExit has run
+	webhooks    chan func()   // Webhook posts for deliverWebhooks
+
+	logRotation LogRotation
+
+	// Auto-restart
+	restartPolicy   RestartPolicy
+	restartHistory  []time.Time
+	restartTimer    *time.Timer
+	startedAt       time.Time
+	lastCrashReport string
+
 	// Metadata
 	id         string
 	name       string
@@ -52,28 +92,79 @@ type Manager struct {
 	version    string
 
 	// Control
-	ctx    context.Context
-	cancel context.CancelFunc
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closed    chan struct{} // Closed by Close to end the background goroutines
+	closeOnce sync.Once
 }
 
 func NewManager() *Manager {
 	m := &Manager{
-		clients:   make(map[ConsoleClient]bool),
-		broadcast: make(chan string),
+		clients:   make(map[ConsoleClient]*subscriber),
 		jarName:   "server.jar",
 		workDir:   ".",
 		maxMemory: 2048,
-		logBuffer: make([]string, 0, 100),
+
+		stopCommand: defaultStopCommand,
+		stopTimeout: defaultStopTimeout,
+		logRotation: defaultLogRotation,
+		scrollback:  newScrollback(DefaultScrollbackSize),
+		logEvents:   make(chan LogLine, logEventBuffer),
 
 		StatsClients:   make(map[*websocket.Conn]bool),
 		StatsBroadcast: make(chan interface{}),
+
+		state:        StateOffline,
+		stateClients: make(map[*websocket.Conn]*stateSubscriber),
+		stateSignal:  make(chan struct{}, 1),
+
+		webhooks: make(chan func(), webhookQueueSize),
+		closed:   make(chan struct{}),
 	}
-	go m.handleBroadcast()
 	go m.handleStatsBroadcast()
+	go m.handleStateBroadcast()
+	go m.deliverWebhooks()
+	go m.dispatchLogEvents()
 	// Stats collection is now started when the server starts
 	return m
 }
 
+// Close ends the goroutines a manager runs for its whole life and disconnects
+// its console and state clients. Pending webhooks are still sent. It is for
+// managers that are being dropped, such as that of a deleted instance, whose
+// server must not be running.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.cancelRestartUnsafe()
+		for c := range m.StatsClients {
+			delete(m.StatsClients, c)
+			c.Close()
+		}
+		m.mu.Unlock()
+		close(m.closed)
+
+		m.consoleMu.Lock()
+		for client, sub := range m.clients {
+			delete(m.clients, client)
+			sub.close()
+		}
+		m.consoleMu.Unlock()
+
+		m.stateMu.Lock()
+		for c, sub := range m.stateClients {
+			delete(m.stateClients, c)
+			sub.close()
+			c.Close()
+		}
+		for _, sub := range m.stateListeners {
+			sub.close()
+		}
+		m.stateListeners = nil
+		m.stateMu.Unlock()
+	})
+}
+
 func (m *Manager) SetWorkDir(dir string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -138,7 +229,7 @@ func (m *Manager) SetInstanceInfo(id, name, serverType, version string) {
 	m.version = version
 }
 
-func (m *Manager) sendWebhook(event string) {
+func (m *Manager) sendWebhook(event string, attachment string) {
 	m.mu.Lock()
 	url := m.webhookURL
 	id := m.id
@@ -146,7 +237,7 @@ func (m *Manager) sendWebhook(event string) {
 	st := m.serverType
 	v := m.version
 	m.mu.Unlock()
-	sendWebhookPayload(url, event, id, name, st, v)
+	m.sendWebhookPayload(url, event, id, name, st, v, attachment)
 }
 
 type discordEmbed struct {
@@ -166,40 +257,93 @@ type discordPayload struct {
 	Embeds []discordEmbed `json:"embeds"`
 }
 
-func sendWebhookPayload(url, event, id, name, serverType, version string) {
+// sendWebhookPayload posts a Discord-style embed. If attachment names a file,
+// it is uploaded alongside the embed as a multipart request.
+func (m *Manager) sendWebhookPayload(url, event, id, name, serverType, version, attachment string) {
+	color := 3066993 // Green
+	if event == "Stopped" || event == "Crashed" {
+		color = 15158332 // Red
+	}
+
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("Server %s", event),
+		Description: fmt.Sprintf("Server **%s** has %s.", name, strings.ToLower(event)),
+		Color:       color,
+		Fields: []discordField{
+			{Name: "Server Name", Value: name, Inline: true},
+			{Name: "ID", Value: id, Inline: true},
+			{Name: "Type", Value: serverType, Inline: true},
+			{Name: "Version", Value: version, Inline: true},
+		},
+	}
+	if attachment != "" {
+		embed.Fields = append(embed.Fields,
+			discordField{Name: "Crash Report", Value: filepath.Base(attachment), Inline: false})
+	}
+	m.postWebhook(url, embed, attachment)
+}
+
+// NotifyFailure posts a red embed about something other than the server
+// process failing, such as a scheduled backup.
+func (m *Manager) NotifyFailure(title, description, detail string) {
+	m.mu.Lock()
+	url := m.webhookURL
+	id := m.id
+	name := m.name
+	m.mu.Unlock()
+
+	embed := discordEmbed{
+		Title:       title,
+		Description: description,
+		Color:       15158332, // Red
+		Fields: []discordField{
+			{Name: "Server Name", Value: name, Inline: true},
+			{Name: "ID", Value: id, Inline: true},
+		},
+	}
+	if detail != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "Error", Value: detail, Inline: false})
+	}
+	m.postWebhook(url, embed, "")
+}
+
+// webhookQueueSize bounds the webhooks waiting for a slow endpoint.
+const webhookQueueSize = 32
+
+// postWebhook queues the embed for deliverWebhooks without blocking, so that
+// it can be called with m.mu held. Webhooks go out one at a time, in order.
+func (m *Manager) postWebhook(url string, embed discordEmbed, attachment string) {
 	if url == "" {
 		return
 	}
 
-	go func() {
-		color := 3066993 // Green
-		if event == "Stopped" || event == "Crashed" {
-			color = 15158332 // Red
-		}
+	post := func() {
+		payloadObj := discordPayload{Embeds: []discordEmbed{embed}}
 
-		payloadObj := discordPayload{
-			Embeds: []discordEmbed{
-				{
-					Title:       fmt.Sprintf("Server %s", event),
-					Description: fmt.Sprintf("Server **%s** has %s.", name, strings.ToLower(event)),
-					Color:       color,
-					Fields: []discordField{
-						{Name: "Server Name", Value: name, Inline: true},
-						{Name: "ID", Value: id, Inline: true},
-						{Name: "Type", Value: serverType, Inline: true},
-						{Name: "Version", Value: version, Inline: true},
-					},
-				},
-			},
+		data, _ := json.Marshal(payloadObj)
+		body := bytes.NewBuffer(data)
+		contentType := "application/json"
+
+		if attachment != "" {
+			if report, err := os.ReadFile(attachment); err == nil {
+				body = &bytes.Buffer{}
+				form := multipart.NewWriter(body)
+				form.WriteField("payload_json", string(data))
+				part, _ := form.CreateFormFile("files[0]", filepath.Base(attachment))
+				part.Write(report)
+				form.Close()
+				contentType = form.FormDataContentType()
+			} else {
+				fmt.Printf("Failed to read webhook attachment: %v\n", err)
+			}
 		}
 
-		data, _ := json.Marshal(payloadObj)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+		req, err := http.NewRequest("POST", url, body)
 		if err != nil {
 			fmt.Printf("Failed to create webhook request: %v\n", err)
 			return
 		}
-		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Type", contentType)
 
 		client := &http.Client{Timeout: 5 * time.Second}
 		resp, err := client.Do(req)
@@ -208,5 +352,29 @@ func sendWebhookPayload(url, event, id, name, serverType, version string) {
 			return
 		}
 		defer resp.Body.Close()
-	}()
+	}
+
+	select {
+	case m.webhooks <- post:
+	default:
+		fmt.Printf("Dropped %q webhook: too many pending\n", embed.Title)
+	}
+}
+
+func (m *Manager) deliverWebhooks() {
+	for {
+		select {
+		case post := <-m.webhooks:
+			post()
+		case <-m.closed:
+			for {
+				select {
+				case post := <-m.webhooks:
+					post()
+				default:
+					return
+				}
+			}
+		}
+	}
 }
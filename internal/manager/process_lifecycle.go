@@ -24,7 +24,9 @@ func (m *Manager) isPidRunning(pid int) bool {
 }
 
 func (m *Manager) IsRunningUnsafe() bool {
-	if m.cmd != nil && m.cmd.Process != nil && m.cmd.ProcessState == nil {
+	// reap clears cmd under m.mu once Wait has returned. cmd.ProcessState
+	// is written by Wait without the lock, so it can't be read here.
+	if m.cmd != nil && m.cmd.Process != nil {
 		return true
 	}
 	if m.pid > 0 && m.isPidRunning(m.pid) {
@@ -40,6 +42,15 @@ func (m *Manager) IsRunning() bool {
 }
 
 func (m *Manager) Start() error {
+	if m.IsRunning() {
+		return fmt.Errorf("server is already running")
+	}
+	m.runPreStartHooks()
+	if err := m.runStartChecks(); err != nil {
+		m.Broadcast(fmt.Sprintf("Cannot start: %v", err))
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -48,19 +59,31 @@ func (m *Manager) Start() error {
 	}
 
 	// Use payload helper directly since we already hold the lock
-	sendWebhookPayload(m.webhookURL, "Starting", m.id, m.name, m.serverType, m.version)
-
-	if m.tailCmd != nil {
-		if m.tailCmd.Process != nil {
-			m.tailCmd.Process.Kill()
+	m.sendWebhookPayload(m.webhookURL, "Starting", m.id, m.name, m.serverType, m.version, "")
+	m.cancelRestartUnsafe()
+
+	// The server writes its output straight to server.log and stderr to its
+	// own log rather than to pipes, so it carries on undisturbed if the panel
+	// goes away.
+	var logFiles []*os.File
+	var offsets []int64
+	for _, name := range []string{"server.log", StderrLogFile} {
+		logPath := filepath.Join(m.workDir, name)
+		if err := newLogRotator(logPath, m.logRotation).rotateStale(); err != nil {
+			fmt.Printf("Failed to rotate %s: %v\n", logPath, err)
 		}
-		m.tailCmd = nil
-	}
+		logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", name, err)
+		}
+		defer logFile.Close()
 
-	logPath := filepath.Join(m.workDir, "server.log")
-	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("Failed to open log file: %v\n", err)
+		logInfo, err := logFile.Stat()
+		if err != nil {
+			return err
+		}
+		logFiles = append(logFiles, logFile)
+		offsets = append(offsets, logInfo.Size())
 	}
 
 	mem := m.maxMemory
@@ -105,140 +128,159 @@ func (m *Manager) Start() error {
 
 	m.cmd.Dir = m.workDir
 
-	stdin, err := m.cmd.StdinPipe()
-	if err != nil {
-		return err
-	}
-	m.stdin = stdin
-
-	stdout, err := m.cmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
+	m.cmd.Stdout = logFiles[0]
+	m.cmd.Stderr = logFiles[1]
 
-	stderr, err := m.cmd.StderrPipe()
+	stdin, closeServerEnd, err := openConsoleInput(m.cmd, m.workDir)
 	if err != nil {
 		return err
 	}
+	defer closeServerEnd()
 
 	if err := m.cmd.Start(); err != nil {
+		stdin.Close()
+		removeConsoleInput(m.workDir)
 		return err
 	}
 
+	m.stdin = stdin
 	m.pid = m.cmd.Process.Pid
+	m.stopRequested = false
+	m.exited = make(chan struct{})
+	m.exitHandled = make(chan struct{})
+	m.startedAt = time.Now()
+	m.lastCrashReport = ""
+	m.setStateUnsafe(StateStarting, nil)
 	os.WriteFile(filepath.Join(m.workDir, "server.pid"), []byte(fmt.Sprintf("%d", m.pid)), 0644)
 
-	go m.streamOutput(stdout, logFile)
-	go m.streamOutput(stderr, logFile)
+	cmd := m.cmd
+	exited, handled := m.exited, m.exitHandled
+	followed := m.followLogs(offsets[0], offsets[1], m.logRotation, exited)
 
 	// Start stats collection
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	go m.CollectStats(m.ctx)
 
 	go func() {
-		m.cmd.Wait()
-		m.mu.Lock()
-		m.cmd = nil
-		m.pid = 0
-		os.Remove(filepath.Join(m.workDir, "server.pid"))
-		m.mu.Unlock()
-		if logFile != nil {
-			logFile.Close()
-		}
-		// Stop stats collection
-		if m.cancel != nil {
-			m.cancel()
-		}
-		m.broadcast <- "Server stopped"
-		m.sendWebhook("Stopped")
+		cmd.Wait()
+		exitCode := cmd.ProcessState.ExitCode()
+		m.reap(&exitCode, exited, handled, followed)
 	}()
 
 	return nil
 }
 
-func (m *Manager) Stop() error {
+// reap records that the server process has gone away and runs the exit
+// handling once followLog has published its last lines, closing handled
+// after it. exitCode is nil if the exit status is unknown, as for a process
+// reattached after a panel restart.
+func (m *Manager) reap(exitCode *int, exited, handled chan struct{}, followed <-chan struct{}) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if m.tailCmd != nil {
-		if m.tailCmd.Process != nil {
-			m.tailCmd.Process.Kill()
-		}
-		m.tailCmd = nil
+	close(exited)
+	m.cmd = nil
+	m.pid = 0
+	if m.stdin != nil {
+		m.stdin.Close()
+		m.stdin = nil
 	}
-
-	if m.cmd != nil && m.cmd.Process != nil {
-		fmt.Fprintln(m.stdin, "stop")
-		return nil
+	os.Remove(filepath.Join(m.workDir, "server.pid"))
+	removeConsoleInput(m.workDir)
+
+	// A non-zero exit (or death by signal, reported as -1) that nobody
+	// asked for is a crash; anything else is a clean stop. Without an exit
+	// code, a fresh crash report is the only hint.
+	requested := m.stopRequested
+	report := findCrashReport(m.workDir, m.startedAt)
+	code := -1
+	var crashed bool
+	if exitCode != nil {
+		code = *exitCode
+		crashed = code != 0 && !requested
+	} else {
+		crashed = !requested && report != ""
 	}
-
-	if m.pid > 0 && m.isPidRunning(m.pid) {
-		process, err := os.FindProcess(m.pid)
-		if err == nil {
-
-			process.Signal(os.Interrupt)
-			m.pid = 0
-			os.Remove(filepath.Join(m.workDir, "server.pid"))
-			return nil
-		}
+	if crashed {
+		m.lastCrashReport = report
+		m.setStateUnsafe(StateCrashed, exitCode)
+	} else {
+		m.setStateUnsafe(StateOffline, exitCode)
 	}
+	m.mu.Unlock()
+	m.closeRCON()
 
-	return fmt.Errorf("server is not running")
-}
-
-func (m *Manager) Restart() error {
-	if err := m.Stop(); err != nil {
-
-		if err.Error() == "server is not running" {
-			return m.Start()
-		}
-		return err
+	<-followed
+	// Stop stats collection
+	if m.cancel != nil {
+		m.cancel()
 	}
-
-	go func() {
-
-		for i := 0; i < 60; i++ {
-			if !m.IsRunning() {
-				break
-			}
-			time.Sleep(1 * time.Second)
-		}
-
-		if !m.IsRunning() {
-			m.Start()
-		} else {
-			m.broadcast <- "Restart failed: server didn't stop in time"
-		}
-	}()
-
-	return nil
+	m.handleExit(crashed, requested, code)
+	close(handled)
 }
 
 func (m *Manager) WriteCommand(cmd string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if m.cmd == nil || m.stdin == nil {
-
-		return fmt.Errorf("server is running in detached mode (backend restarted), cannot send command")
+	if m.stdin == nil {
+		if m.IsRunningUnsafe() {
+			return fmt.Errorf("console input of the reattached server is unavailable, cannot send command")
+		}
+		return ErrNotRunning
 	}
 	_, err := fmt.Fprintln(m.stdin, cmd)
 	return err
 }
 
 func (m *Manager) loadPid() {
+	pidPath := filepath.Join(m.workDir, "server.pid")
+	data, err := os.ReadFile(pidPath)
+	if err != nil {
+		return
+	}
+	pid, err := strconv.Atoi(string(data))
+	if err != nil {
+		return
+	}
+	if !m.isPidRunning(pid) {
+		os.Remove(pidPath)
+		removeConsoleInput(m.workDir)
+		return
+	}
 
-	data, err := os.ReadFile(filepath.Join(m.workDir, "server.pid"))
-	if err == nil {
-		pid, err := strconv.Atoi(string(data))
-		if err == nil {
-			if m.isPidRunning(pid) {
-				m.pid = pid
-				m.recoverLogs()
-				m.startTailing()
-			} else {
-
-				os.Remove(filepath.Join(m.workDir, "server.pid"))
-			}
+	m.pid = pid
+	// We cannot tell how far the reattached server got, assume it is up.
+	m.state = StateOnline
+	m.stopRequested = false
+	m.exited = make(chan struct{})
+	m.exitHandled = make(chan struct{})
+	m.startedAt = time.Now()
+	if info, err := os.Stat(pidPath); err == nil {
+		m.startedAt = info.ModTime()
+	}
+
+	if stdin, err := dialConsoleInput(m.workDir); err == nil {
+		m.stdin = stdin
+	} else {
+		fmt.Printf("Reattached to pid %d without console input: %v\n", pid, err)
+	}
+
+	offset, errOffset := m.recoverLogs()
+	exited, handled := m.exited, m.exitHandled
+	followed := m.followLogs(offset, errOffset, m.logRotation, exited)
+
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	go m.CollectStats(m.ctx)
+
+	go m.watchPid(pid, exited, handled, followed)
+}
+
+// watchPid stands in for cmd.Wait for a process we did not start.
+func (m *Manager) watchPid(pid int, exited, handled chan struct{}, followed <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !m.isPidRunning(pid) {
+			m.reap(nil, exited, handled, followed)
+			return
 		}
 	}
 }
@@ -0,0 +1,349 @@
+//go:build !windows
+
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stateRecorder collects the state changes of a manager.
+type stateRecorder struct {
+	mu     sync.Mutex
+	events []StateEvent
+}
+
+func recordStates(m *Manager) *stateRecorder {
+	r := &stateRecorder{}
+	m.AddStateListener(func(event StateEvent) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+	})
+	return r
+}
+
+func (r *stateRecorder) states() []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := []State{}
+	for _, event := range r.events {
+		states = append(states, event.State)
+	}
+	return states
+}
+
+func (r *stateRecorder) last() StateEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return StateEvent{}
+	}
+	return r.events[len(r.events)-1]
+}
+
+func (r *stateRecorder) reached(state State) func() bool {
+	return func() bool { return r.last().State == state }
+}
+
+func (r *stateRecorder) count(state State) int {
+	n := 0
+	for _, s := range r.states() {
+		if s == state {
+			n++
+		}
+	}
+	return n
+}
+
+func consoleCount(m *Manager, text string) int {
+	n := 0
+	for _, line := range m.QueryScrollback(ScrollbackQuery{}).Lines {
+		if line.Text == text {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestServer(t *testing.T, script string) *Manager {
+	t.Helper()
+	m := NewManager()
+	m.SetSilent(true)
+	m.SetWorkDir(t.TempDir())
+	m.SetStartCommand(script)
+	t.Cleanup(func() {
+		m.SetRestartPolicy(RestartPolicy{Mode: RestartNever})
+		m.Stop()
+	})
+	return m
+}
+
+func TestStartGoesOnlineOnDoneLine(t *testing.T) {
+	m := newTestServer(t, `echo "Loading libraries"; read line; `+
+		`echo '[12:00:00] [Server thread/INFO]: Done (1.234s)! For help, type "help"'; `+
+		`while read line; do [ "$line" = stop ] && exit 0; done`)
+	states := recordStates(m)
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, consoleHas(m, "Loading libraries"))
+	if state := m.GetState(); state != StateStarting {
+		t.Fatalf("expected Starting before the Done line, got %s", state)
+	}
+
+	m.WriteCommand("go")
+	waitFor(t, states.reached(StateOnline))
+
+	phase, err := m.Stop()
+	if err != nil || phase != StopPhaseCommand {
+		t.Fatalf("expected the stop command to end the server, got %s (%v)", phase, err)
+	}
+	waitFor(t, states.reached(StateOffline))
+	want := []State{StateStarting, StateOnline, StateStopping, StateOffline}
+	if got := states.states(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected states %v, got %v", want, got)
+	}
+}
+
+func TestCrashAndCleanExit(t *testing.T) {
+	crash := newTestServer(t, "exit 3")
+	crashStates := recordStates(crash)
+	if err := crash.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, crashStates.reached(StateCrashed))
+	if code := crashStates.last().ExitCode; code == nil || *code != 3 {
+		t.Errorf("expected exit code 3, got %v", code)
+	}
+	waitFor(t, consoleHas(crash, "Server crashed (exit code 3)"))
+
+	clean := newTestServer(t, "exit 0")
+	cleanStates := recordStates(clean)
+	if err := clean.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, cleanStates.reached(StateOffline))
+	waitFor(t, consoleHas(clean, "Server stopped"))
+	if cleanStates.count(StateCrashed) != 0 {
+		t.Errorf("clean exit reported as a crash: %v", cleanStates.states())
+	}
+}
+
+func TestRestartBackoffAndRetryLimit(t *testing.T) {
+	m := newTestServer(t, "exit 1")
+	states := recordStates(m)
+	m.SetRestartPolicy(RestartPolicy{Mode: RestartOnFailure, MaxRetries: 2, Window: time.Minute, Backoff: 50 * time.Millisecond})
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, consoleHas(m, "Auto-restart disabled: 2 restarts within 1m0s"))
+	// Each retry waits twice as long as the one before.
+	for _, text := range []string{"Restarting in 50ms (attempt 1)", "Restarting in 100ms (attempt 2)"} {
+		if !consoleHas(m, text)() {
+			t.Errorf("expected %q on the console", text)
+		}
+	}
+	if n := states.count(StateStarting); n != 3 {
+		t.Errorf("expected 3 starts, got %d", n)
+	}
+}
+
+func TestRestartRetriesOutsideWindowAreForgotten(t *testing.T) {
+	m := newTestServer(t, "exit 1")
+	m.SetRestartPolicy(RestartPolicy{Mode: RestartOnFailure, MaxRetries: 1, Window: 100 * time.Millisecond, Backoff: 200 * time.Millisecond})
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// The first retry is past the window by the time the server crashes
+	// again, so the next one doesn't count it.
+	waitFor(t, func() bool { return consoleCount(m, "Restarting in 200ms (attempt 1)") >= 2 })
+	if consoleHas(m, "Auto-restart disabled: 1 restarts within 100ms")() {
+		t.Error("restart disabled by a retry outside the window")
+	}
+}
+
+func TestCrashReportWebhook(t *testing.T) {
+	type upload struct {
+		payload discordPayload
+		report  string
+	}
+	uploads := make(chan upload, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u upload
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			json.Unmarshal([]byte(r.FormValue("payload_json")), &u.payload)
+			if file, _, err := r.FormFile("files[0]"); err == nil {
+				data, _ := io.ReadAll(file)
+				u.report = string(data)
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&u.payload)
+		}
+		uploads <- u
+	}))
+	defer hook.Close()
+
+	// The report has to be newer than the start to count.
+	m := newTestServer(t, "sleep 0.1; mkdir -p crash-reports; echo boom > crash-reports/crash-1.txt; exit 1")
+	m.SetWebhookURL(hook.URL)
+	states := recordStates(m)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, states.reached(StateCrashed))
+	if report := states.last().CrashReport; report != "crash-1.txt" {
+		t.Errorf("expected the crash report on the state event, got %q", report)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case u := <-uploads:
+			if len(u.payload.Embeds) == 0 || u.payload.Embeds[0].Title != "Server Crashed" {
+				continue
+			}
+			if strings.TrimSpace(u.report) != "boom" {
+				t.Errorf("expected the crash report to be attached, got %q", u.report)
+			}
+			return
+		case <-timeout:
+			t.Fatal("no crash webhook received")
+		}
+	}
+}
+
+func TestStopEscalates(t *testing.T) {
+	prev := signalGracePeriod
+	signalGracePeriod = 300 * time.Millisecond
+	t.Cleanup(func() { signalGracePeriod = prev })
+
+	tests := []struct {
+		name   string
+		script string
+		phase  StopPhase
+	}{
+		{"command", `while read line; do [ "$line" = stop ] && exit 0; done`, StopPhaseCommand},
+		{"sigterm", `trap 'exit 0' TERM; while :; do sleep 0.05; done`, StopPhaseTerm},
+		{"sigkill", `trap '' TERM; while :; do sleep 0.05; done`, StopPhaseKill},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestServer(t, tt.script)
+			m.SetStopTimeout(200 * time.Millisecond)
+			if err := m.Start(); err != nil {
+				t.Fatal(err)
+			}
+			// Let the shell set its traps up.
+			time.Sleep(100 * time.Millisecond)
+
+			phase, err := m.Stop()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if phase != tt.phase {
+				t.Errorf("expected phase %s, got %s", tt.phase, phase)
+			}
+			if m.IsRunning() {
+				t.Error("server still running after Stop")
+			}
+			if state := m.GetState(); state != StateOffline {
+				t.Errorf("expected a requested stop to end Offline, got %s", state)
+			}
+		})
+	}
+}
+
+func TestStopSignalsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs /proc to tell whether the child is gone")
+	}
+	prev := signalGracePeriod
+	signalGracePeriod = 300 * time.Millisecond
+	t.Cleanup(func() { signalGracePeriod = prev })
+
+	// The server leaves a child behind that would outlive it.
+	m := newTestServer(t, `sleep 30 & echo $! > child.pid; trap 'exit 0' TERM; while :; do sleep 0.05; done`)
+	m.SetStopTimeout(200 * time.Millisecond)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var child int
+	waitFor(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(m.workDir, "child.pid"))
+		child, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil && child > 0
+	})
+
+	if phase, err := m.Stop(); err != nil || phase != StopPhaseTerm {
+		t.Fatalf("expected SIGTERM to end the server, got %s (%v)", phase, err)
+	}
+	waitFor(t, func() bool {
+		// A zombie waiting for a reaper is as good as gone.
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", child))
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	})
+}
+
+func TestRestartReportsStopFirst(t *testing.T) {
+	var mu sync.Mutex
+	var titles []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload discordPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, embed := range payload.Embeds {
+			titles = append(titles, embed.Title)
+		}
+	}))
+	defer hook.Close()
+
+	m := newTestServer(t, `echo up; while read line; do [ "$line" = stop ] && exit 0; done`)
+	m.SetWebhookURL(hook.URL)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, consoleHas(m, "up"))
+	if err := m.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return consoleCount(m, "up") == 2 })
+
+	var console []string
+	for _, line := range m.QueryScrollback(ScrollbackQuery{}).Lines {
+		if line.Text == "up" || line.Text == "Server stopped" {
+			console = append(console, line.Text)
+		}
+	}
+	if want := []string{"up", "Server stopped", "up"}; !reflect.DeepEqual(console, want) {
+		t.Errorf("expected console %v, got %v", want, console)
+	}
+
+	want := []string{"Server Starting", "Server Stopped", "Server Starting"}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(titles) == len(want)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("expected webhooks %v, got %v", want, titles)
+	}
+}
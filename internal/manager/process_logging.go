@@ -1,83 +1,217 @@
 package manager
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
-func (m *Manager) recoverLogs() {
+// recoverLogs refills the scrollback from the end of server.log and the
+// stderr log after a panel restart. Only the tails of the files are read,
+// however large they are. The files don't say how their lines interleave,
+// so stderr goes first and the latest stdout lines last. It returns the
+// offsets to follow the logs from.
+func (m *Manager) recoverLogs() (int64, int64) {
+	m.consoleMu.Lock()
+	defer m.consoleMu.Unlock()
+	errOffset := m.recoverLogUnsafe(StderrLogFile, StreamStderr)
+	return m.recoverLogUnsafe("server.log", StreamStdout), errOffset
+}
 
-	logPath := fmt.Sprintf("%s/server.log", m.workDir)
-	file, err := os.Open(logPath)
+func (m *Manager) recoverLogUnsafe(name, stream string) int64 {
+	file, err := os.Open(filepath.Join(m.workDir, name))
 	if err != nil {
-		return
+		return 0
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	var lines []string
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	info, err := file.Stat()
+	if err != nil {
+		return 0
 	}
 
-	start := 0
-	if len(lines) > 100 {
-		start = len(lines) - 100
+	lines := readLastLines(file, info.Size(), len(m.scrollback.lines))
+	for _, line := range lines {
+		m.scrollback.add(stream, line, info.ModTime())
 	}
-	m.logBuffer = lines[start:]
+	return info.Size()
 }
 
-func (m *Manager) startTailing() {
-	if m.tailCmd != nil {
-		return
-	}
+// readLastLines returns up to n complete lines from the end of r, reading
+// backwards in blocks.
+func readLastLines(r io.ReaderAt, size int64, n int) []string {
+	const blockSize = 64 * 1024
 
-	logPath := fmt.Sprintf("%s/server.log", m.workDir)
-	m.tailCmd = exec.Command("tail", "-f", "-n", "0", logPath)
+	var buf []byte
+	offset := size
+	for offset > 0 && bytes.Count(buf, []byte{'\n'}) <= n {
+		readSize := int64(blockSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
 
-	stdout, err := m.tailCmd.StdoutPipe()
-	if err != nil {
-		fmt.Println("Failed to pipe tail:", err)
-		return
+		block := make([]byte, readSize)
+		if _, err := r.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil
+		}
+		buf = append(block, buf...)
 	}
 
-	if err := m.tailCmd.Start(); err != nil {
-		fmt.Println("Failed to start tail:", err)
-		return
+	lines := strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+	// The first line is likely cut off unless we reached the start of the file.
+	if offset > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
 	}
+	return lines
+}
+
+// followInterval is how often followLog polls server.log for new output.
+const followInterval = 200 * time.Millisecond
 
-	go m.streamOutput(stdout, nil)
+// followLogs follows server.log and the stderr log from the given offsets
+// until done is closed. The returned channel is closed once both have
+// published their last lines.
+func (m *Manager) followLogs(offset, errOffset int64, policy LogRotation, done <-chan struct{}) <-chan struct{} {
+	logs := []struct {
+		name, stream string
+		offset       int64
+	}{
+		{"server.log", StreamStdout, offset},
+		{StderrLogFile, StreamStderr, errOffset},
+	}
 
+	var wg sync.WaitGroup
+	for _, l := range logs {
+		path := filepath.Join(m.workDir, l.name)
+		wg.Add(1)
+		go func(stream string, offset int64) {
+			defer wg.Done()
+			m.followLog(path, stream, offset, newLogRotator(path, policy), done)
+		}(l.stream, l.offset)
+	}
+
+	followed := make(chan struct{})
 	go func() {
-		m.tailCmd.Wait()
-		m.mu.Lock()
-		m.tailCmd = nil
-		m.mu.Unlock()
+		wg.Wait()
+		close(followed)
 	}()
+	return followed
 }
 
-func (m *Manager) streamOutput(r io.Reader, logFile io.Writer) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		text := scanner.Text()
-		if !m.silent {
-			fmt.Println("Server:", text)
+// followLog streams lines appended to a log into the console as stream,
+// starting at offset, until done is closed. The server writes to the file
+// itself, so this works the same for a process we started and one we
+// reattached to after a panel restart. Rotation happens here too, between
+// reads, so that no line is lost from the console when the file is truncated.
+func (m *Manager) followLog(path, stream string, offset int64, rotator *logRotator, done <-chan struct{}) {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	var pending []byte
+	buf := make([]byte, 32*1024)
+
+	// consume publishes the complete lines in data and keeps the rest.
+	consume := func(data []byte) {
+		pending = append(pending, data...)
+		for {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				break
+			}
+			m.handleOutput(stream, strings.TrimRight(string(pending[:i]), "\r"))
+			pending = pending[i+1:]
 		}
+	}
 
-		if logFile != nil {
-			fmt.Fprintln(logFile, text)
+	// read publishes the lines from offset to the end of the file.
+	read := func() {
+		for {
+			n, err := file.ReadAt(buf, offset)
+			offset += int64(n)
+			consume(buf[:n])
+			if err != nil || n == 0 {
+				return
+			}
 		}
+	}
 
-		m.mu.Lock()
-		m.logBuffer = append(m.logBuffer, text)
-		if len(m.logBuffer) > 100 {
-			m.logBuffer = m.logBuffer[1:]
+	poll := func() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return
 		}
-		m.mu.Unlock()
+		if file != nil {
+			// The file was replaced, e.g. by an older panel rotating it.
+			if current, err := file.Stat(); err != nil || !os.SameFile(current, info) {
+				file.Close()
+				file = nil
+			}
+		}
+		if file == nil {
+			if file, err = os.Open(path); err != nil {
+				file = nil
+				return
+			}
+			if offset > info.Size() {
+				offset = 0
+			}
+		}
+		if info.Size() < offset {
+			// Truncated by someone else.
+			offset = 0
+			pending = nil
+		}
+
+		read()
+
+		if rotator != nil && rotator.due(offset) {
+			unread, err := rotator.rotate(offset)
+			if err != nil {
+				fmt.Printf("Failed to rotate %s: %v\n", path, err)
+				return
+			}
+			consume(unread)
+			offset = 0
+		}
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			// Pick up whatever the process wrote before it went away.
+			poll()
+			if len(pending) > 0 {
+				m.handleOutput(stream, strings.TrimRight(string(pending), "\r"))
+			}
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
 
-		m.broadcast <- text
+func (m *Manager) handleOutput(stream, text string) {
+	if !m.silent {
+		fmt.Println("Server:", text)
 	}
+	m.observeStateLine(text)
+	m.publish(stream, text)
 }
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFollowLogsKeepsStreams(t *testing.T) {
+	m := NewManager()
+	m.SetWorkDir(t.TempDir())
+	os.WriteFile(filepath.Join(m.workDir, "server.log"), []byte("[12:00:00] [Server thread/INFO]: Starting\n"), 0644)
+	os.WriteFile(filepath.Join(m.workDir, StderrLogFile), []byte("Exception in thread \"main\"\n"), 0644)
+
+	done := make(chan struct{})
+	followed := m.followLogs(0, 0, LogRotation{}, done)
+	waitFor(t, func() bool { return len(m.QueryScrollback(ScrollbackQuery{}).Lines) == 2 })
+	close(done)
+	<-followed
+
+	streams := map[string]string{}
+	for _, line := range m.QueryScrollback(ScrollbackQuery{}).Lines {
+		streams[line.Text] = line.Stream
+	}
+	if streams["[12:00:00] [Server thread/INFO]: Starting"] != StreamStdout {
+		t.Errorf("expected server.log on stdout, got %v", streams)
+	}
+	if streams["Exception in thread \"main\""] != StreamStderr {
+		t.Errorf("expected the stderr log on stderr, got %v", streams)
+	}
+
+	// After a panel restart the scrollback comes back with the same tags.
+	restarted := NewManager()
+	restarted.SetWorkDir(m.workDir)
+	offset, errOffset := restarted.recoverLogs()
+	if offset == 0 || errOffset == 0 {
+		t.Errorf("expected to follow on from the end of both logs, got %d and %d", offset, errOffset)
+	}
+	lines := restarted.QueryScrollback(ScrollbackQuery{}).Lines
+	if len(lines) != 2 || lines[0].Stream != StreamStderr || lines[1].Stream != StreamStdout {
+		t.Errorf("unexpected recovered lines %+v", lines)
+	}
+}
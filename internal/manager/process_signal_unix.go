@@ -0,0 +1,21 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalServer sends sig to the server's process group, which
+// openConsoleInput gave it, so that anything the start command spawned
+// goes down with it. A process that leads no group of its own only gets
+// the signal itself.
+func signalServer(pid int, sig syscall.Signal) {
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return
+	}
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Signal(sig)
+	}
+}
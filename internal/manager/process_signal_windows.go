@@ -0,0 +1,16 @@
+//go:build windows
+
+package manager
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalServer delivers sig to the server process. Windows has no process
+// groups, and of the signals only SIGKILL does anything.
+func signalServer(pid int, sig syscall.Signal) {
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Signal(sig)
+	}
+}
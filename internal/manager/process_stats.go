@@ -29,7 +29,14 @@ func (m *Manager) UnregisterStatsClient(c *websocket.Conn) {
 
 func (m *Manager) handleStatsBroadcast() {
 	fmt.Println("Starting handleStatsBroadcast")
-	for stats := range m.StatsBroadcast {
+	for {
+		var stats interface{}
+		select {
+		case stats = <-m.StatsBroadcast:
+		case <-m.closed:
+			return
+		}
+
 		m.mu.Lock()
 		if len(m.StatsClients) > 0 {
 			fmt.Printf("Broadcasting stats to %d clients\n", len(m.StatsClients))
@@ -55,11 +62,11 @@ func (m *Manager) CollectStats(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			// Context cancelled, stop collecting
-			m.StatsBroadcast <- ProcessStats{
+			m.sendStats(ProcessStats{
 				CPU:    0,
 				Memory: 0,
 				Time:   time.Now().Unix(),
-			}
+			})
 			return
 		case <-ticker.C:
 			// Continue with collection
@@ -92,10 +99,19 @@ func (m *Manager) CollectStats(ctx context.Context) {
 
 		fmt.Printf("Stats: CPU=%.2f, Mem=%d\n", cpu, mem)
 
-		m.StatsBroadcast <- ProcessStats{
+		m.sendStats(ProcessStats{
 			CPU:    cpu,
 			Memory: mem,
 			Time:   time.Now().Unix(),
-		}
+		})
+	}
+}
+
+// sendStats hands stats to handleStatsBroadcast, unless the manager has
+// been closed and nobody is listening any more.
+func (m *Manager) sendStats(stats ProcessStats) {
+	select {
+	case m.StatsBroadcast <- stats:
+	case <-m.closed:
 	}
 }
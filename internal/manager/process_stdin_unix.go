@@ -0,0 +1,76 @@
+//go:build !windows
+
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ConsoleInputName is the FIFO in the instance directory that the server
+// reads its stdin from.
+const ConsoleInputName = "console.in"
+
+// commandWriteTimeout bounds how long a command may wait for room in the
+// FIFO when the server is not reading its stdin.
+const commandWriteTimeout = 5 * time.Second
+
+// openConsoleInput binds the server's stdin to a fresh console.in FIFO and
+// returns JJMC's end of it. The server holds a read-write descriptor so it
+// never sees EOF while the panel is away, and a restarted panel can open
+// the FIFO again with dialConsoleInput. The returned func closes the
+// server's end in this process once it has been started.
+func openConsoleInput(cmd *exec.Cmd, workDir string) (io.WriteCloser, func(), error) {
+	path := filepath.Join(workDir, ConsoleInputName)
+	os.Remove(path)
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s: %v", ConsoleInputName, err)
+	}
+
+	serverEnd, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stdin, err := dialConsoleInput(workDir)
+	if err != nil {
+		serverEnd.Close()
+		return nil, nil, err
+	}
+
+	cmd.Stdin = serverEnd
+	// Keep the server out of the panel's process group so that a Ctrl+C
+	// meant for the panel doesn't take the server down with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	return stdin, func() { serverEnd.Close() }, nil
+}
+
+// dialConsoleInput opens the write end of a running server's console.in.
+// It fails if nothing is reading from it.
+func dialConsoleInput(workDir string) (io.WriteCloser, error) {
+	path := filepath.Join(workDir, ConsoleInputName)
+	file, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", ConsoleInputName, err)
+	}
+	return &consoleInput{file}, nil
+}
+
+func removeConsoleInput(workDir string) {
+	os.Remove(filepath.Join(workDir, ConsoleInputName))
+}
+
+type consoleInput struct {
+	*os.File
+}
+
+func (c *consoleInput) Write(p []byte) (int, error) {
+	c.File.SetWriteDeadline(time.Now().Add(commandWriteTimeout))
+	return c.File.Write(p)
+}
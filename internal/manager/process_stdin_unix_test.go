@@ -0,0 +1,73 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A stand-in server that echoes its commands and exits on "stop".
+const echoServer = `while read line; do [ "$line" = stop ] && exit 0; echo "got $line"; done`
+
+func consoleHas(m *Manager, text string) func() bool {
+	return func() bool {
+		for _, line := range m.QueryScrollback(ScrollbackQuery{}).Lines {
+			if line.Text == text {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestReattachAfterPanelRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	first := NewManager()
+	first.SetSilent(true)
+	first.SetWorkDir(dir)
+	first.SetStartCommand(echoServer)
+	if err := first.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer first.Stop()
+
+	if err := first.WriteCommand("one"); err != nil {
+		t.Fatalf("WriteCommand failed: %v", err)
+	}
+	waitFor(t, consoleHas(first, "got one"))
+
+	if info, err := os.Stat(filepath.Join(dir, ConsoleInputName)); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("expected %s to be a FIFO", ConsoleInputName)
+	}
+
+	// A second manager for the same directory plays the restarted panel.
+	second := NewManager()
+	second.SetSilent(true)
+	second.SetWorkDir(dir)
+	if second.GetState() != StateOnline {
+		t.Fatalf("expected reattached server to be online, got %s", second.GetState())
+	}
+	waitFor(t, consoleHas(second, "got one"))
+
+	if err := second.WriteCommand("two"); err != nil {
+		t.Fatalf("WriteCommand after reattach failed: %v", err)
+	}
+	waitFor(t, consoleHas(second, "got two"))
+
+	phase, err := second.Stop()
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if phase != StopPhaseCommand {
+		t.Errorf("expected the stop command to end the server, got %s", phase)
+	}
+	if second.GetState() != StateOffline {
+		t.Errorf("expected offline after stop, got %s", second.GetState())
+	}
+	if _, err := os.Stat(filepath.Join(dir, ConsoleInputName)); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed after exit", ConsoleInputName)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package manager
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+)
+
+// ConsoleInputName is unused on Windows, which has no FIFOs; the server's
+// stdin is an ordinary pipe and commands cannot be sent to it again after
+// a panel restart.
+const ConsoleInputName = "console.in"
+
+func openConsoleInput(cmd *exec.Cmd, workDir string) (io.WriteCloser, func(), error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	return stdin, func() {}, nil
+}
+
+func dialConsoleInput(workDir string) (io.WriteCloser, error) {
+	return nil, errors.New("reattaching to the console is not supported on Windows")
+}
+
+func removeConsoleInput(workDir string) {}
@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+type StopPhase string
+
+const (
+	// The server shut down by itself after receiving its stop command.
+	StopPhaseCommand StopPhase = "command"
+	// The server ignored the stop command and exited on SIGTERM.
+	StopPhaseTerm StopPhase = "sigterm"
+	// The server had to be killed.
+	StopPhaseKill StopPhase = "sigkill"
+)
+
+const (
+	defaultStopCommand = "stop"
+	defaultStopTimeout = 60 * time.Second
+)
+
+// How long each signal gets before escalating to the next one.
+var signalGracePeriod = 10 * time.Second
+
+var ErrNotRunning = errors.New("server is not running")
+
+func (m *Manager) SetStopCommand(cmd string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopCommand = cmd
+}
+
+func (m *Manager) SetStopTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopTimeout = timeout
+}
+
+// Stop asks the server to shut down with its stop command and blocks until
+// it has exited and its exit has been reported. A server that is still
+// running after the stop timeout gets SIGTERM and finally SIGKILL; the
+// returned phase tells which one ended it.
+func (m *Manager) Stop() (StopPhase, error) {
+	m.mu.Lock()
+
+	m.cancelRestartUnsafe()
+
+	if !m.IsRunningUnsafe() {
+		m.mu.Unlock()
+		return "", ErrNotRunning
+	}
+
+	m.stopRequested = true
+	m.setStateUnsafe(StateStopping, nil)
+
+	pid := m.pid
+	exited, handled := m.exited, m.exitHandled
+	timeout := m.stopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+
+	// Without console input (reattached to a server whose console.in is
+	// gone) there is no way to send the stop command, so go straight to
+	// the signals.
+	if m.stdin != nil {
+		stopCmd := m.stopCommand
+		if stopCmd == "" {
+			stopCmd = defaultStopCommand
+		}
+		if _, err := fmt.Fprintln(m.stdin, stopCmd); err != nil {
+			timeout = 0
+		}
+	} else {
+		timeout = 0
+	}
+	m.mu.Unlock()
+
+	phase, err := m.escalateStop(pid, exited, timeout)
+	if err == nil {
+		// So that whoever starts the server next, such as Restart, does so
+		// after "Server stopped" went out.
+		<-handled
+	}
+	return phase, err
+}
+
+func (m *Manager) escalateStop(pid int, exited chan struct{}, timeout time.Duration) (StopPhase, error) {
+	if m.waitForExit(exited, timeout) {
+		return StopPhaseCommand, nil
+	}
+
+	if timeout > 0 {
+		m.Broadcast(fmt.Sprintf("Server did not stop within %s, sending SIGTERM", timeout))
+	}
+	signalServer(pid, syscall.SIGTERM)
+	if m.waitForExit(exited, signalGracePeriod) {
+		return StopPhaseTerm, nil
+	}
+
+	m.Broadcast("Server ignored SIGTERM, killing it")
+	signalServer(pid, syscall.SIGKILL)
+	if m.waitForExit(exited, signalGracePeriod) {
+		return StopPhaseKill, nil
+	}
+
+	return StopPhaseKill, fmt.Errorf("server (pid %d) is still running after SIGKILL", pid)
+}
+
+// waitForExit waits up to timeout for the process to go away. The exited
+// channel is closed by reap, whether the process was started by us or
+// reattached after a panel restart.
+func (m *Manager) waitForExit(exited chan struct{}, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Restart stops the server the same way Stop does and starts it again once
+// it has exited. It returns as soon as the restart is under way.
+func (m *Manager) Restart() error {
+	if !m.IsRunning() {
+		return m.Start()
+	}
+
+	go func() {
+		if _, err := m.Stop(); err != nil && !errors.Is(err, ErrNotRunning) {
+			m.Broadcast(fmt.Sprintf("Restart failed: %v", err))
+			return
+		}
+		if err := m.Start(); err != nil {
+			m.Broadcast(fmt.Sprintf("Restart failed: %v", err))
+		}
+	}()
+
+	return nil
+}
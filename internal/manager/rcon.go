@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"time"
+
+	"jjmc/internal/properties"
+	"jjmc/pkg/mcrcon"
+)
+
+const (
+	defaultRCONPort = "25575"
+	// Bounds connecting to RCON and every command sent over it.
+	rconTimeout = 5 * time.Second
+)
+
+var errRCONDisabled = errors.New("rcon is not enabled in server.properties")
+
+// rconClientUnsafe returns the cached RCON connection, dialing one with the
+// settings from server.properties if needed. Callers must hold rconMu.
+func (m *Manager) rconClientUnsafe() (*mcrcon.Client, error) {
+	if m.rcon != nil {
+		return m.rcon, nil
+	}
+
+	props, err := properties.Read(filepath.Join(m.GetWorkDir(), "server.properties"))
+	if err != nil {
+		return nil, err
+	}
+	if props["enable-rcon"] != "true" || props["rcon.password"] == "" {
+		return nil, errRCONDisabled
+	}
+	port := props["rcon.port"]
+	if port == "" {
+		port = defaultRCONPort
+	}
+
+	client, err := mcrcon.Dial(net.JoinHostPort("127.0.0.1", port), props["rcon.password"], rconTimeout)
+	if err != nil {
+		return nil, err
+	}
+	m.rcon = client
+	return client, nil
+}
+
+// rconCommand runs cmd over RCON. A dial error means the command was never
+// sent; any later error drops the connection, as the command may have run.
+func (m *Manager) rconCommand(cmd string) (output string, sent bool, err error) {
+	m.rconMu.Lock()
+	defer m.rconMu.Unlock()
+
+	client, err := m.rconClientUnsafe()
+	if err != nil {
+		return "", false, err
+	}
+
+	output, err = client.Command(cmd)
+	if err != nil {
+		client.Close()
+		m.rcon = nil
+	}
+	return output, true, err
+}
+
+func (m *Manager) closeRCON() {
+	m.rconMu.Lock()
+	defer m.rconMu.Unlock()
+	if m.rcon != nil {
+		m.rcon.Close()
+		m.rcon = nil
+	}
+}
@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	defaultRestartBackoff = 5 * time.Second
+	maxRestartBackoff     = 5 * time.Minute
+)
+
+type RestartPolicy struct {
+	Mode       string
+	MaxRetries int           // 0 means unlimited
+	Window     time.Duration // retries older than this are forgotten
+	Backoff    time.Duration // delay before the first retry, doubled for each further one
+}
+
+func (m *Manager) SetRestartPolicy(policy RestartPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.restartPolicy = policy
+}
+
+// cancelRestartUnsafe drops a pending automatic restart, e.g. because the
+// user started or stopped the server by hand in the meantime.
+func (m *Manager) cancelRestartUnsafe() {
+	if m.restartTimer != nil {
+		m.restartTimer.Stop()
+		m.restartTimer = nil
+	}
+}
+
+// handleExit runs after the process has been reaped and the state updated.
+// requested tells whether the exit was asked for through Stop.
+func (m *Manager) handleExit(crashed, requested bool, exitCode int) {
+	if !crashed {
+		m.Broadcast("Server stopped")
+		m.sendWebhook("Stopped", "")
+	} else {
+		m.mu.Lock()
+		report := m.lastCrashReport
+		m.mu.Unlock()
+
+		m.Broadcast(fmt.Sprintf("Server crashed (exit code %d)", exitCode))
+		m.sendWebhook("Crashed", report)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy := m.restartPolicy
+	if requested {
+		return
+	}
+	if policy.Mode != RestartAlways && !(policy.Mode == RestartOnFailure && crashed) {
+		return
+	}
+
+	now := time.Now()
+	recent := m.restartHistory[:0]
+	for _, t := range m.restartHistory {
+		if policy.Window <= 0 || now.Sub(t) < policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	m.restartHistory = recent
+
+	if policy.MaxRetries > 0 && len(recent) >= policy.MaxRetries {
+		m.Broadcast(fmt.Sprintf("Auto-restart disabled: %d restarts within %s", len(recent), policy.Window))
+		return
+	}
+
+	delay := policy.Backoff
+	if delay <= 0 {
+		delay = defaultRestartBackoff
+	}
+	for i := 0; i < len(recent) && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRestartBackoff {
+		delay = maxRestartBackoff
+	}
+
+	m.restartHistory = append(m.restartHistory, now)
+	m.Broadcast(fmt.Sprintf("Restarting in %s (attempt %d)", delay, len(m.restartHistory)))
+
+	m.cancelRestartUnsafe()
+	m.restartTimer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		m.restartTimer = nil
+		m.mu.Unlock()
+
+		if err := m.Start(); err != nil {
+			m.Broadcast(fmt.Sprintf("Auto-restart failed: %v", err))
+		}
+	})
+}
+
+// findCrashReport returns the newest file in crash-reports/ written since
+// the server was started, or "" if the server left none behind.
+func findCrashReport(workDir string, since time.Time) string {
+	entries, err := os.ReadDir(filepath.Join(workDir, "crash-reports"))
+	if err != nil {
+		return ""
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		if info.ModTime().After(newestTime) {
+			newest = filepath.Join(workDir, "crash-reports", entry.Name())
+			newestTime = info.ModTime()
+		}
+	}
+	return newest
+}
@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	// Messages generated by JJMC itself, e.g. install progress or "Server stopped".
+	StreamSystem = "system"
+)
+
+const DefaultScrollbackSize = 1000
+
+type LogLine struct {
+	Seq    uint64     `json:"seq"`
+	Time   time.Time  `json:"time"`
+	Stream string     `json:"stream"`
+	Text   string     `json:"text"`
+	Record *LogRecord `json:"record,omitempty"` // Nil if the line isn't in a known log layout
+}
+
+// scrollback is a fixed-size ring of the most recent console lines. Every
+// line gets a sequence number that keeps increasing across evictions, so
+// clients can ask for "everything after N".
+type scrollback struct {
+	lines []LogLine
+	start int
+	count int
+	next  uint64
+}
+
+func newScrollback(size int) *scrollback {
+	if size <= 0 {
+		size = DefaultScrollbackSize
+	}
+	return &scrollback{lines: make([]LogLine, size), next: 1}
+}
+
+func (s *scrollback) add(stream, text string, t time.Time) LogLine {
+	line := LogLine{Seq: s.next, Time: t, Stream: stream, Text: text}
+	if record, ok := ParseLogLine(text); ok {
+		line.Record = &record
+	}
+	s.next++
+
+	if s.count < len(s.lines) {
+		s.lines[(s.start+s.count)%len(s.lines)] = line
+		s.count++
+	} else {
+		s.lines[s.start] = line
+		s.start = (s.start + 1) % len(s.lines)
+	}
+	return line
+}
+
+func (s *scrollback) at(i int) LogLine {
+	return s.lines[(s.start+i)%len(s.lines)]
+}
+
+// since returns the buffered lines with a sequence number above seq. A seq
+// from the future (e.g. handed out before a panel restart) yields everything.
+func (s *scrollback) since(seq uint64) []LogLine {
+	if seq >= s.next {
+		seq = 0
+	}
+	out := make([]LogLine, 0, s.count)
+	for i := 0; i < s.count; i++ {
+		if line := s.at(i); line.Seq > seq {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func (s *scrollback) resize(size int) {
+	if size <= 0 {
+		size = DefaultScrollbackSize
+	}
+	if size == len(s.lines) {
+		return
+	}
+	kept := s.since(0)
+	if len(kept) > size {
+		kept = kept[len(kept)-size:]
+	}
+	s.lines = make([]LogLine, size)
+	copy(s.lines, kept)
+	s.start = 0
+	s.count = len(kept)
+}
+
+func (s *scrollback) oldest() uint64 {
+	if s.count == 0 {
+		return s.next
+	}
+	return s.at(0).Seq
+}
+
+func (m *Manager) SetScrollbackSize(size int) {
+	m.consoleMu.Lock()
+	defer m.consoleMu.Unlock()
+	m.scrollback.resize(size)
+}
+
+type ScrollbackQuery struct {
+	Since  uint64 // Only lines after this sequence number
+	Before uint64 // Only lines before this sequence number, 0 for no limit
+	Grep   *regexp.Regexp
+	Level  string // Minimum log level, e.g. "WARN" also matches ERROR
+	Limit  int    // Newest matches to return
+}
+
+type ScrollbackPage struct {
+	Lines  []LogLine `json:"lines"`
+	Oldest uint64    `json:"oldest"` // Oldest sequence number still buffered
+	Next   uint64    `json:"next"`   // Sequence number the next line will get
+}
+
+func (m *Manager) QueryScrollback(q ScrollbackQuery) ScrollbackPage {
+	m.consoleMu.Lock()
+	lines := m.scrollback.since(q.Since)
+	page := ScrollbackPage{Oldest: m.scrollback.oldest(), Next: m.scrollback.next}
+	m.consoleMu.Unlock()
+
+	minLevel := levelRank(q.Level)
+	matched := make([]LogLine, 0, len(lines))
+	for _, line := range lines {
+		if q.Before > 0 && line.Seq >= q.Before {
+			break
+		}
+		if q.Grep != nil && !q.Grep.MatchString(line.Text) {
+			continue
+		}
+		if minLevel > 0 && (line.Record == nil || levelRank(line.Record.Level) < minLevel) {
+			continue
+		}
+		matched = append(matched, line)
+	}
+
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[len(matched)-q.Limit:]
+	}
+	page.Lines = matched
+	return page
+}
+
+func levelRank(level string) int {
+	switch strings.ToUpper(level) {
+	case "TRACE":
+		return 1
+	case "DEBUG":
+		return 2
+	case "INFO":
+		return 3
+	case "WARN", "WARNING":
+		return 4
+	case "ERROR":
+		return 5
+	case "FATAL":
+		return 6
+	}
+	return 0
+}
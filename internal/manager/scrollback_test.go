@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrollbackEvictsOldest(t *testing.T) {
+	s := newScrollback(3)
+	for i := 1; i <= 5; i++ {
+		s.add(StreamStdout, fmt.Sprintf("line %d", i), time.Now())
+	}
+
+	lines := s.since(0)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	if lines[0].Seq != 3 || lines[2].Seq != 5 {
+		t.Errorf("expected seqs 3..5, got %d..%d", lines[0].Seq, lines[2].Seq)
+	}
+	if s.oldest() != 3 {
+		t.Errorf("expected oldest 3, got %d", s.oldest())
+	}
+}
+
+func TestScrollbackSince(t *testing.T) {
+	s := newScrollback(10)
+	for i := 1; i <= 5; i++ {
+		s.add(StreamStdout, fmt.Sprintf("line %d", i), time.Now())
+	}
+
+	if lines := s.since(3); len(lines) != 2 || lines[0].Text != "line 4" {
+		t.Errorf("expected lines 4 and 5, got %+v", lines)
+	}
+	if lines := s.since(5); len(lines) != 0 {
+		t.Errorf("expected no lines after the newest, got %d", len(lines))
+	}
+	// A sequence number from a previous panel run replays everything.
+	if lines := s.since(100); len(lines) != 5 {
+		t.Errorf("expected full replay for unknown seq, got %d", len(lines))
+	}
+}
+
+func TestScrollbackResizeKeepsNewest(t *testing.T) {
+	s := newScrollback(5)
+	for i := 1; i <= 5; i++ {
+		s.add(StreamStdout, fmt.Sprintf("line %d", i), time.Now())
+	}
+
+	s.resize(2)
+	lines := s.since(0)
+	if len(lines) != 2 || lines[0].Text != "line 4" {
+		t.Fatalf("expected lines 4 and 5 after shrinking, got %+v", lines)
+	}
+
+	s.resize(4)
+	s.add(StreamStdout, "line 6", time.Now())
+	if lines := s.since(0); len(lines) != 3 || lines[2].Seq != 6 {
+		t.Errorf("expected 3 lines ending at seq 6 after growing, got %+v", lines)
+	}
+}
+
+func TestQueryScrollbackFilters(t *testing.T) {
+	m := NewManager()
+	m.publish(StreamStdout, "[12:00:00] [Server thread/INFO]: Starting minecraft server")
+	m.publish(StreamStdout, "[12:00:01] [Server thread/WARN]: Can't keep up!")
+	m.publish(StreamStderr, "[12:00:02 ERROR]: Could not load plugin")
+	m.publish(StreamStdout, "[12:00:03] [Server thread/INFO]: Done (1.234s)!")
+
+	page := m.QueryScrollback(ScrollbackQuery{Level: "WARN"})
+	if len(page.Lines) != 2 {
+		t.Errorf("expected 2 lines at WARN or above, got %d", len(page.Lines))
+	}
+
+	page = m.QueryScrollback(ScrollbackQuery{Grep: regexp.MustCompile(`(?i)done`)})
+	if len(page.Lines) != 1 || !strings.Contains(page.Lines[0].Text, "Done") {
+		t.Errorf("expected the Done line, got %+v", page.Lines)
+	}
+
+	page = m.QueryScrollback(ScrollbackQuery{Before: 3, Limit: 1})
+	if len(page.Lines) != 1 || page.Lines[0].Seq != 2 {
+		t.Errorf("expected only seq 2 when paging back, got %+v", page.Lines)
+	}
+	if page.Next != 5 {
+		t.Errorf("expected next seq 5, got %d", page.Next)
+	}
+}
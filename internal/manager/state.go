@@ -0,0 +1,208 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+type State string
+
+const (
+	StateOffline  State = "Offline"
+	StateStarting State = "Starting"
+	StateOnline   State = "Online"
+	StateStopping State = "Stopping"
+	StateCrashed  State = "Crashed"
+)
+
+type StateEvent struct {
+	State    State `json:"state"`
+	Previous State `json:"previous"`
+	ExitCode *int  `json:"exitCode,omitempty"`
+	Time     int64 `json:"time"`
+
+	CrashReport string `json:"crashReport,omitempty"`
+}
+
+// Matches the "Done (3.142s)! For help, type "help"" line printed by
+// Vanilla, Paper, Forge and Velocity once the server accepts players.
+var doneLineRe = regexp.MustCompile(`Done \(\d+(?:[.,]\d+)?s\)!`)
+
+func (s State) IsActive() bool {
+	return s == StateStarting || s == StateOnline || s == StateStopping
+}
+
+func (m *Manager) GetState() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// setStateUnsafe only queues the event, so that a slow state subscriber
+// can't hold up whoever holds m.mu. handleStateBroadcast delivers it.
+func (m *Manager) setStateUnsafe(state State, exitCode *int) {
+	if m.state == state {
+		return
+	}
+	event := StateEvent{
+		State:    state,
+		Previous: m.state,
+		ExitCode: exitCode,
+		Time:     time.Now().Unix(),
+	}
+	if state == StateCrashed && m.lastCrashReport != "" {
+		event.CrashReport = filepath.Base(m.lastCrashReport)
+	}
+	m.state = state
+	m.stateEvents = append(m.stateEvents, event)
+	select {
+	case m.stateSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) setState(state State, exitCode *int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setStateUnsafe(state, exitCode)
+}
+
+func (m *Manager) observeStateLine(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == StateStarting && doneLineRe.MatchString(line) {
+		m.setStateUnsafe(StateOnline, nil)
+	}
+}
+
+const stateQueueSize = 64
+
+// stateSubscriber hands state events to a websocket or listener from its own
+// queue and goroutine, so that one slow subscriber doesn't hold up the rest.
+type stateSubscriber struct {
+	queue chan StateEvent
+	send  func(StateEvent) error
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newStateSubscriber(send func(StateEvent) error) *stateSubscriber {
+	return &stateSubscriber{
+		queue: make(chan StateEvent, stateQueueSize),
+		send:  send,
+		done:  make(chan struct{}),
+	}
+}
+
+// offer queues event without blocking. It reports false if the queue is full.
+func (s *stateSubscriber) offer(event StateEvent) bool {
+	select {
+	case s.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *stateSubscriber) run(onError func()) {
+	for {
+		select {
+		case event := <-s.queue:
+			if err := s.send(event); err != nil {
+				onError()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *stateSubscriber) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (m *Manager) RegisterStateClient(c *websocket.Conn) {
+	m.mu.Lock()
+	current := StateEvent{State: m.state, Previous: m.state, Time: time.Now().Unix()}
+	m.mu.Unlock()
+
+	sub := newStateSubscriber(func(event StateEvent) error { return c.WriteJSON(event) })
+	sub.offer(current)
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if old, ok := m.stateClients[c]; ok {
+		old.close()
+	}
+	m.stateClients[c] = sub
+	go sub.run(func() { m.UnregisterStateClient(c) })
+}
+
+func (m *Manager) UnregisterStateClient(c *websocket.Conn) {
+	m.stateMu.Lock()
+	sub, ok := m.stateClients[c]
+	delete(m.stateClients, c)
+	m.stateMu.Unlock()
+
+	if ok {
+		sub.close()
+	}
+	c.Close()
+}
+
+// StateListener is called for every state change, in order, on a goroutine
+// of its own.
+type StateListener func(event StateEvent)
+
+func (m *Manager) AddStateListener(fn StateListener) {
+	sub := newStateSubscriber(func(event StateEvent) error {
+		fn(event)
+		return nil
+	})
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.stateListeners = append(m.stateListeners, sub)
+	go sub.run(func() {})
+}
+
+// handleStateBroadcast fans the events queued by setStateUnsafe out to the
+// subscribers. A websocket that falls behind is closed, as it can fetch the
+// state again; a listener that does only loses the event.
+func (m *Manager) handleStateBroadcast() {
+	for {
+		select {
+		case <-m.stateSignal:
+		case <-m.closed:
+			return
+		}
+
+		m.mu.Lock()
+		events := m.stateEvents
+		m.stateEvents = nil
+		m.mu.Unlock()
+
+		m.stateMu.Lock()
+		for _, event := range events {
+			for c, sub := range m.stateClients {
+				if !sub.offer(event) {
+					delete(m.stateClients, c)
+					sub.close()
+					c.Close()
+				}
+			}
+			for _, sub := range m.stateListeners {
+				if !sub.offer(event) {
+					fmt.Printf("Dropped %s state event: listener too slow\n", event.State)
+				}
+			}
+		}
+		m.stateMu.Unlock()
+	}
+}
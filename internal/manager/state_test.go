@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestSlowStateListenerDoesNotBlock(t *testing.T) {
+	m := NewManager()
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	m.AddStateListener(func(StateEvent) { <-blocked })
+
+	var mu sync.Mutex
+	var seen []State
+	m.AddStateListener(func(event StateEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, event.State)
+	})
+
+	// Enough changes to fill the stuck listener's queue.
+	states := []State{StateStarting, StateOnline, StateStopping, StateOffline}
+	rounds := stateQueueSize/len(states) + 2
+	for i := 1; i <= rounds; i++ {
+		for _, state := range states {
+			m.setState(state, nil)
+		}
+		waitFor(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(seen) == i*len(states)
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, state := range seen {
+		if state != states[i%len(states)] {
+			t.Fatalf("event %d: expected %s, got %s", i, states[i%len(states)], state)
+		}
+	}
+}
+
+func TestCloseEndsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	m := NewManager()
+	m.SetSilent(true)
+	m.AddStateListener(func(StateEvent) {})
+	m.RegisterClient(&fastClient{})
+	m.setState(StateStarting, nil)
+
+	m.Close()
+	m.Close()
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= before })
+}
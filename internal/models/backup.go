@@ -0,0 +1,19 @@
+package models
+
+// BackupRun records one attempt at backing up an instance, whether started by
+// hand or by a schedule.
+type BackupRun struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	InstanceID string `json:"instanceId" gorm:"index"`
+	Trigger    string `json:"trigger"` // "manual" or "schedule"
+	Label      string `json:"label,omitempty"`
+	WorldOnly  bool   `json:"worldOnly"`
+	Status     string `json:"status"`           // "running", "success" or "failed"
+	Backup     string `json:"backup,omitempty"` // File name of the backup
+	Size       int64  `json:"size"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	Pruned     int    `json:"pruned"` // Old backups removed by the retention policy afterwards
+	StartedAt  int64  `json:"startedAt"`
+	FinishedAt int64  `json:"finishedAt"`
+}
@@ -12,23 +12,65 @@ type Instance struct {
 	JarFile      string `json:"jarFile"`
 	JavaPath     string `json:"javaPath"`
 	StartCommand string `json:"startCommand"`
+	StopCommand  string `json:"stopCommand"`
+	StopTimeout  int    `json:"stopTimeout"` // Seconds before escalating to SIGTERM
 	WebhookURL   string `json:"webhookUrl"`
 	Group        string `json:"group"`
 	FolderID     string `json:"folderId"`
+
+	ScrollbackLines int `json:"scrollbackLines"`
+	LogMaxSizeMB    int `json:"logMaxSizeMb"`   // 0 disables size-based rotation
+	LogMaxAgeHours  int `json:"logMaxAgeHours"` // 0 disables age-based rotation
+	LogRetention    int `json:"logRetention"`   // Rotated logs to keep, 0 keeps all
+
+	RestartPolicy     string `json:"restartPolicy"` // "never", "on-failure", "always"
+	RestartMaxRetries int    `json:"restartMaxRetries"`
+	RestartWindow     int    `json:"restartWindow"`  // Seconds
+	RestartBackoff    int    `json:"restartBackoff"` // Seconds before the first retry
+
+	BackupKeepLast    int `json:"backupKeepLast"` // 0 in all of these keeps every backup
+	BackupKeepDaily   int `json:"backupKeepDaily"`
+	BackupKeepWeekly  int `json:"backupKeepWeekly"`
+	BackupKeepMonthly int `json:"backupKeepMonthly"`
+	BackupMaxSizeMB   int `json:"backupMaxSizeMb"` // 0 for no limit
+
+	BackupMode     string   `json:"backupMode"`     // "zip" or "snapshot", empty means zip
+	BackupExcludes []string `json:"backupExcludes"` // Gitignore-style, nil for the defaults
 }
 
 type InstanceModel struct {
-	ID           string `gorm:"primaryKey"`
-	Name         string
-	Type         string
-	Version      string
-	MaxMemory    int
-	JavaArgs     string
-	JarFile      string
-	JavaPath     string
-	StartCommand string
-	WebhookURL   string
-	Group        string
-	FolderID     string // Links to models.Folder.ID
-	CreatedAt    int64
+	ID           string `json:"id" gorm:"primaryKey"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	MaxMemory    int    `json:"maxMemory"`
+	JavaArgs     string `json:"javaArgs"`
+	JarFile      string `json:"jarFile"`
+	JavaPath     string `json:"javaPath"`
+	StartCommand string `json:"startCommand"`
+	StopCommand  string `json:"stopCommand"`
+	StopTimeout  int    `json:"stopTimeout" gorm:"default:60"`
+	WebhookURL   string `json:"webhookUrl"`
+	Group        string `json:"group"`
+	FolderID     string `json:"folderId"` // Links to models.Folder.ID
+	CreatedAt    int64  `json:"createdAt"`
+
+	ScrollbackLines int `json:"scrollbackLines" gorm:"default:1000"`
+	LogMaxSizeMB    int `json:"logMaxSizeMb" gorm:"default:100"`
+	LogMaxAgeHours  int `json:"logMaxAgeHours" gorm:"default:24"`
+	LogRetention    int `json:"logRetention" gorm:"default:14"`
+
+	RestartPolicy     string `json:"restartPolicy" gorm:"default:never"`
+	RestartMaxRetries int    `json:"restartMaxRetries" gorm:"default:3"`
+	RestartWindow     int    `json:"restartWindow" gorm:"default:600"`
+	RestartBackoff    int    `json:"restartBackoff" gorm:"default:10"`
+
+	BackupKeepLast    int `json:"backupKeepLast"`
+	BackupKeepDaily   int `json:"backupKeepDaily"`
+	BackupKeepWeekly  int `json:"backupKeepWeekly"`
+	BackupKeepMonthly int `json:"backupKeepMonthly"`
+	BackupMaxSizeMB   int `json:"backupMaxSizeMb"`
+
+	BackupMode     string `json:"backupMode"`
+	BackupExcludes string `json:"backupExcludes"` // JSON list, empty for the defaults
 }
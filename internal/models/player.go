@@ -0,0 +1,24 @@
+package models
+
+// PlayerSession is one stretch of a player being online on an instance.
+type PlayerSession struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	InstanceID string `json:"instanceId" gorm:"index"`
+	UUID       string `json:"uuid" gorm:"index"`
+	Name       string `json:"name" gorm:"index"`
+	IP         string `json:"ip"`
+	JoinedAt   int64  `json:"joinedAt" gorm:"index"`
+	LeftAt     int64  `json:"leftAt"` // 0 while the player is online
+	Reason     string `json:"reason"` // Disconnect reason, or why JJMC closed the session
+}
+
+// PlayerEvent is a single join, leave, death, chat or advancement line.
+type PlayerEvent struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	InstanceID string `json:"instanceId" gorm:"index"`
+	UUID       string `json:"uuid"`
+	Name       string `json:"name" gorm:"index"`
+	Type       string `json:"type"` // "join", "leave", "death", "chat", "advancement"
+	Message    string `json:"message"`
+	Time       int64  `json:"time" gorm:"index"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// PortAllocation is a port handed out to an instance. Purpose is "server",
+// "rcon" or "query" for the ports in server.properties, or a name of the
+// user's choosing for extra ports such as a map or voice chat plugin.
+type PortAllocation struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	InstanceID string `json:"instanceId" gorm:"index"`
+	Purpose    string `json:"purpose"`
+	Protocol   string `json:"protocol"` // "tcp" or "udp"
+	Port       int    `json:"port" gorm:"index"`
+	CreatedAt  int64  `json:"createdAt"`
+}
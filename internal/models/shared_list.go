@@ -0,0 +1,54 @@
+package models
+
+// SharedList declares that every instance in a group keeps one of its player
+// lists ("whitelist", "ops", "bans" or "ip-bans") identical.
+type SharedList struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Group     string `json:"group" gorm:"column:group_name;uniqueIndex:idx_shared_list"`
+	List      string `json:"list" gorm:"uniqueIndex:idx_shared_list"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// SharedListEntry is a player or address on a shared list, along with where
+// it came from.
+type SharedListEntry struct {
+	ID                  uint   `json:"id" gorm:"primaryKey"`
+	Group               string `json:"group" gorm:"column:group_name;index"`
+	List                string `json:"list"`
+	Key                 string `json:"key"` // Lower-case name, or the address for ip-bans
+	UUID                string `json:"uuid,omitempty"`
+	Name                string `json:"name,omitempty"`
+	IP                  string `json:"ip,omitempty"`
+	Level               int    `json:"level,omitempty"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+	Expires             int64  `json:"expires,omitempty"` // 0 for permanent bans
+	Source              string `json:"source"`            // "api", "console:<instance>" or "import:<instance>"
+	CreatedAt           int64  `json:"createdAt"`
+}
+
+// SharedListChange is the audit trail of a shared list.
+type SharedListChange struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	Group  string `json:"group" gorm:"column:group_name;index"`
+	List   string `json:"list"`
+	Key    string `json:"key"`
+	Action string `json:"action"` // "add" or "remove"
+	Source string `json:"source"`
+	Time   int64  `json:"time" gorm:"index"`
+}
+
+// SharedListRemoval is a removal from a shared list that didn't reach one
+// member, such as a running server that didn't answer. It is retried before
+// the member next starts.
+type SharedListRemoval struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	InstanceID string `json:"instanceId" gorm:"index"`
+	Group      string `json:"group" gorm:"column:group_name"`
+	List       string `json:"list"`
+	Key        string `json:"key"`
+	Name       string `json:"name,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	Error      string `json:"error"`
+	Time       int64  `json:"time"`
+}
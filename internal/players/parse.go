@@ -0,0 +1,83 @@
+package players
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	EventUUID        = "uuid"  // Not stored, links a name to its UUID before the join
+	EventLogin       = "login" // Not stored, carries the IP before the join
+	EventJoin        = "join"
+	EventLeave       = "leave"
+	EventDeath       = "death"
+	EventChat        = "chat"
+	EventAdvancement = "advancement"
+)
+
+// Event is a player related console message.
+type Event struct {
+	Type    string
+	Name    string
+	UUID    string // EventUUID only
+	IP      string // EventLogin only
+	Message string // Chat text, advancement, death message or disconnect reason
+}
+
+var (
+	uuidRe  = regexp.MustCompile(`^UUID of player (\S+) is ([0-9a-fA-F-]{32,36})$`)
+	loginRe = regexp.MustCompile(`^(\S+)\[/(.+):\d+\] logged in with entity id`)
+	joinRe  = regexp.MustCompile(`^(\S+)(?: \(formerly known as \S+\))? joined the game$`)
+	lostRe  = regexp.MustCompile(`^(\S+) lost connection: (.*)$`)
+	leftRe  = regexp.MustCompile(`^(\S+) left the game$`)
+	chatRe  = regexp.MustCompile(`^(?:\[Not Secure\] )?<([^>\s]+)> (.*)$`)
+	advRe   = regexp.MustCompile(`^(\S+) has (?:made the advancement|completed the challenge|reached the goal) \[(.+)\]$`)
+)
+
+// Beginnings of the vanilla death messages, right after the player name.
+var deathPhrases = []string{
+	"was ", "drowned", "died", "fell ", "blew up", "burned", "went up in flames",
+	"went off with a bang", "hit the ground", "starved", "suffocated",
+	"experienced kinetic energy", "froze to death", "withered away", "walked into",
+	"tried to swim in lava", "discovered the floor was lava", "didn't want to live",
+	"left the confines", "fell out of the world", "was killed",
+}
+
+// ParseEvent recognises the player messages of the vanilla server, which
+// Paper, Spigot, Forge and Fabric print unchanged. message is the text after
+// the log prefix (LogRecord.Message). Deaths are only recognised for names
+// in online, since they have no fixed shape.
+func ParseEvent(message string, online func(name string) bool) (Event, bool) {
+	if m := uuidRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventUUID, Name: m[1], UUID: m[2]}, true
+	}
+	if m := loginRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventLogin, Name: m[1], IP: strings.Trim(m[2], "[]")}, true
+	}
+	if m := joinRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventJoin, Name: m[1]}, true
+	}
+	if m := lostRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventLeave, Name: m[1], Message: m[2]}, true
+	}
+	if m := leftRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventLeave, Name: m[1]}, true
+	}
+	if m := chatRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventChat, Name: m[1], Message: m[2]}, true
+	}
+	if m := advRe.FindStringSubmatch(message); m != nil {
+		return Event{Type: EventAdvancement, Name: m[1], Message: m[2]}, true
+	}
+
+	name, rest, ok := strings.Cut(message, " ")
+	if ok && online != nil && online(name) {
+		for _, phrase := range deathPhrases {
+			if strings.HasPrefix(rest, phrase) {
+				return Event{Type: EventDeath, Name: name, Message: message}, true
+			}
+		}
+	}
+
+	return Event{}, false
+}
@@ -0,0 +1,51 @@
+package players
+
+import "testing"
+
+func TestParseEvent(t *testing.T) {
+	online := func(name string) bool { return name == "Steve" }
+
+	tests := []struct {
+		message string
+		want    Event
+	}{
+		{"UUID of player Steve is 8667ba71-b85a-4004-af54-457a9734eed7", Event{Type: EventUUID, Name: "Steve", UUID: "8667ba71-b85a-4004-af54-457a9734eed7"}},
+		{"Steve[/127.0.0.1:51234] logged in with entity id 187 at (0.5, 64.0, 0.5)", Event{Type: EventLogin, Name: "Steve", IP: "127.0.0.1"}},
+		{"Steve[/[0:0:0:0:0:0:0:1]:51234] logged in with entity id 187 at ([world]0.5, 64.0, 0.5)", Event{Type: EventLogin, Name: "Steve", IP: "0:0:0:0:0:0:0:1"}},
+		{"Steve joined the game", Event{Type: EventJoin, Name: "Steve"}},
+		{"Steve (formerly known as Alex) joined the game", Event{Type: EventJoin, Name: "Steve"}},
+		{"Steve lost connection: Disconnected", Event{Type: EventLeave, Name: "Steve", Message: "Disconnected"}},
+		{"Steve left the game", Event{Type: EventLeave, Name: "Steve"}},
+		{"<Steve> hello there", Event{Type: EventChat, Name: "Steve", Message: "hello there"}},
+		{"[Not Secure] <Steve> hi", Event{Type: EventChat, Name: "Steve", Message: "hi"}},
+		{"Steve has made the advancement [Stone Age]", Event{Type: EventAdvancement, Name: "Steve", Message: "Stone Age"}},
+		{"Steve was slain by Zombie", Event{Type: EventDeath, Name: "Steve", Message: "Steve was slain by Zombie"}},
+		{"Steve fell from a high place", Event{Type: EventDeath, Name: "Steve", Message: "Steve fell from a high place"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseEvent(tt.message, online)
+		if !ok {
+			t.Errorf("%q: not recognised", tt.message)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestParseEventIgnoresOtherLines(t *testing.T) {
+	online := func(name string) bool { return name == "Steve" }
+
+	for _, message := range []string{
+		"Done (3.142s)! For help, type \"help\"",
+		"Alex was slain by Zombie", // Not online
+		"Steve has the following entity data: {}",
+		"Preparing spawn area: 83%",
+	} {
+		if ev, ok := ParseEvent(message, online); ok {
+			t.Errorf("%q: unexpectedly parsed as %+v", message, ev)
+		}
+	}
+}
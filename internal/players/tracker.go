@@ -0,0 +1,303 @@
+// Package players follows each instance's console to record who was online
+// when, along with their deaths, chat and advancements.
+package players
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type Tracker struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	instances map[string]*instanceState
+}
+
+type instanceState struct {
+	uuids  map[string]string // Name to UUID, from the line before the join
+	ips    map[string]string
+	online map[string]*models.PlayerSession
+}
+
+// Playtime is the total time a player has spent on an instance.
+type Playtime struct {
+	UUID     string `json:"uuid"`
+	Name     string `json:"name"`
+	Seconds  int64  `json:"seconds"`
+	Sessions int64  `json:"sessions"`
+	LastSeen int64  `json:"lastSeen"`
+}
+
+func NewTracker(db *gorm.DB) *Tracker {
+	return &Tracker{
+		db:        db,
+		instances: make(map[string]*instanceState),
+	}
+}
+
+// Watch starts tracking the console and state changes of an instance.
+// Sessions left open by a previous run go on if the server was reattached
+// to, and end with the panel otherwise.
+func (t *Tracker) Watch(instanceID string, m *manager.Manager) {
+	t.resume(instanceID)
+	m.AddLogListener(func(line manager.LogLine) {
+		if line.Record != nil {
+			t.handleMessage(instanceID, line.Record.Message, line.Time)
+		}
+	})
+	m.AddStateListener(func(event manager.StateEvent) {
+		if !event.State.IsActive() {
+			t.closeAll(instanceID, fmt.Sprintf("server %s", strings.ToLower(string(event.State))), time.Unix(event.Time, 0))
+		}
+	})
+	if !m.GetState().IsActive() {
+		t.closeAll(instanceID, "panel restarted", time.Now())
+	}
+}
+
+// resume picks up the sessions of an instance that are still open in the
+// database.
+func (t *Tracker) resume(instanceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var open []models.PlayerSession
+	if err := t.db.Where("instance_id = ? AND left_at = 0", instanceID).Find(&open).Error; err != nil {
+		fmt.Printf("Failed to load open player sessions: %v\n", err)
+		return
+	}
+	s := t.stateFor(instanceID)
+	for i := range open {
+		if _, online := s.online[open[i].Name]; !online {
+			s.online[open[i].Name] = &open[i]
+		}
+	}
+}
+
+// Reconcile brings the open sessions of an instance in line with the
+// players the server says are online, for whoever joined or left while
+// nobody was watching its console.
+func (t *Tracker) Reconcile(instanceID string, names []string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stateFor(instanceID)
+	current := make(map[string]bool, len(names))
+	for _, name := range names {
+		current[name] = true
+		if _, online := s.online[name]; online {
+			continue
+		}
+		session := &models.PlayerSession{InstanceID: instanceID, Name: name, JoinedAt: at.Unix()}
+		if err := t.db.Create(session).Error; err != nil {
+			fmt.Printf("Failed to save player session: %v\n", err)
+			continue
+		}
+		s.online[name] = session
+	}
+	for name, session := range s.online {
+		if !current[name] {
+			t.closeSession(session, "left while the panel was down", at)
+			delete(s.online, name)
+		}
+	}
+}
+
+func (t *Tracker) stateFor(instanceID string) *instanceState {
+	s, ok := t.instances[instanceID]
+	if !ok {
+		s = &instanceState{
+			uuids:  make(map[string]string),
+			ips:    make(map[string]string),
+			online: make(map[string]*models.PlayerSession),
+		}
+		t.instances[instanceID] = s
+	}
+	return s
+}
+
+func (t *Tracker) handleMessage(instanceID, message string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stateFor(instanceID)
+	ev, ok := ParseEvent(message, func(name string) bool {
+		_, online := s.online[name]
+		return online
+	})
+	if !ok {
+		return
+	}
+
+	var uuid string
+	if session, online := s.online[ev.Name]; online {
+		uuid = session.UUID
+	}
+
+	switch ev.Type {
+	case EventUUID:
+		s.uuids[ev.Name] = ev.UUID
+		return
+	case EventLogin:
+		s.ips[ev.Name] = ev.IP
+		return
+	case EventJoin:
+		if _, online := s.online[ev.Name]; online {
+			return
+		}
+		session := &models.PlayerSession{
+			InstanceID: instanceID,
+			UUID:       s.uuids[ev.Name],
+			Name:       ev.Name,
+			IP:         s.ips[ev.Name],
+			JoinedAt:   at.Unix(),
+		}
+		delete(s.uuids, ev.Name)
+		delete(s.ips, ev.Name)
+		if err := t.db.Create(session).Error; err != nil {
+			fmt.Printf("Failed to save player session: %v\n", err)
+			return
+		}
+		s.online[ev.Name] = session
+		uuid = session.UUID
+	case EventLeave:
+		// "lost connection" and "left the game" both follow a disconnect;
+		// the first one closes the session.
+		session, online := s.online[ev.Name]
+		if !online {
+			return
+		}
+		t.closeSession(session, ev.Message, at)
+		delete(s.online, ev.Name)
+	}
+
+	t.db.Create(&models.PlayerEvent{
+		InstanceID: instanceID,
+		UUID:       uuid,
+		Name:       ev.Name,
+		Type:       ev.Type,
+		Message:    ev.Message,
+		Time:       at.Unix(),
+	})
+}
+
+func (t *Tracker) closeSession(session *models.PlayerSession, reason string, at time.Time) {
+	session.LeftAt = at.Unix()
+	session.Reason = reason
+	t.db.Model(session).Updates(map[string]interface{}{
+		"left_at": session.LeftAt,
+		"reason":  reason,
+	})
+}
+
+func (t *Tracker) closeAll(instanceID, reason string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stateFor(instanceID)
+	for name, session := range s.online {
+		t.closeSession(session, reason, at)
+		delete(s.online, name)
+	}
+	s.uuids = make(map[string]string)
+	s.ips = make(map[string]string)
+}
+
+// Online returns the open sessions of an instance.
+func (t *Tracker) Online(instanceID string) []models.PlayerSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sessions := []models.PlayerSession{}
+	for _, session := range t.stateFor(instanceID).online {
+		sessions = append(sessions, *session)
+	}
+	return sessions
+}
+
+type SessionQuery struct {
+	InstanceID string // Empty for all instances
+	Player     string // Name or UUID, partial names match
+	From, To   int64  // Sessions overlapping this window, 0 for open ends
+	Limit      int
+}
+
+// Sessions returns matching sessions, most recent first.
+func (t *Tracker) Sessions(q SessionQuery) ([]models.PlayerSession, error) {
+	db := t.db.Model(&models.PlayerSession{})
+	if q.InstanceID != "" {
+		db = db.Where("instance_id = ?", q.InstanceID)
+	}
+	if q.Player != "" {
+		db = db.Where("uuid = ? OR name LIKE ?", q.Player, "%"+q.Player+"%")
+	}
+	if q.To > 0 {
+		db = db.Where("joined_at <= ?", q.To)
+	}
+	if q.From > 0 {
+		db = db.Where("left_at = 0 OR left_at >= ?", q.From)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	sessions := []models.PlayerSession{}
+	err := db.Order("joined_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// Playtime sums up session lengths per player on an instance. Open
+// sessions count up to now.
+func (t *Tracker) Playtime(instanceID string) ([]Playtime, error) {
+	totals := []Playtime{}
+	err := t.db.Model(&models.PlayerSession{}).
+		Select("MAX(uuid) AS uuid, MAX(name) AS name, COUNT(*) AS sessions, MAX(joined_at) AS last_seen, "+
+			"SUM(CASE WHEN left_at = 0 THEN ? ELSE left_at END - joined_at) AS seconds", time.Now().Unix()).
+		Where("instance_id = ?", instanceID).
+		Group("COALESCE(NULLIF(uuid, ''), name)").
+		Order("seconds desc").
+		Scan(&totals).Error
+	return totals, err
+}
+
+type EventQuery struct {
+	InstanceID string
+	Player     string
+	Type       string
+	From, To   int64
+	Limit      int
+}
+
+// Events returns matching player events, most recent first.
+func (t *Tracker) Events(q EventQuery) ([]models.PlayerEvent, error) {
+	db := t.db.Model(&models.PlayerEvent{})
+	if q.InstanceID != "" {
+		db = db.Where("instance_id = ?", q.InstanceID)
+	}
+	if q.Player != "" {
+		db = db.Where("uuid = ? OR name LIKE ?", q.Player, "%"+q.Player+"%")
+	}
+	if q.Type != "" {
+		db = db.Where("type = ?", q.Type)
+	}
+	if q.From > 0 {
+		db = db.Where("time >= ?", q.From)
+	}
+	if q.To > 0 {
+		db = db.Where("time <= ?", q.To)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	events := []models.PlayerEvent{}
+	err := db.Order("time desc").Find(&events).Error
+	return events, err
+}
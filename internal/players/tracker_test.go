@@ -0,0 +1,129 @@
+package players
+
+import (
+	"testing"
+	"time"
+
+	"jjmc/internal/manager"
+	"jjmc/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestTracker(t *testing.T) *Tracker {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.PlayerSession{}, &models.PlayerEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewTracker(db)
+}
+
+func TestTrackerSessions(t *testing.T) {
+	tr := newTestTracker(t)
+	start := time.Unix(1700000000, 0)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	tr.handleMessage("survival", "UUID of player Steve is 8667ba71-b85a-4004-af54-457a9734eed7", at(0))
+	tr.handleMessage("survival", "Steve[/10.0.0.5:51234] logged in with entity id 1 at (0.5, 64.0, 0.5)", at(0))
+	tr.handleMessage("survival", "Steve joined the game", at(0))
+	tr.handleMessage("survival", "Alex joined the game", at(10))
+	tr.handleMessage("survival", "Steve was slain by Zombie", at(30))
+	tr.handleMessage("survival", "<Alex> rip", at(31))
+
+	online := tr.Online("survival")
+	if len(online) != 2 {
+		t.Fatalf("expected 2 players online, got %d", len(online))
+	}
+
+	tr.handleMessage("survival", "Steve lost connection: Disconnected", at(60))
+	tr.handleMessage("survival", "Steve left the game", at(60))
+	tr.closeAll("survival", "server offline", at(100))
+
+	if online := tr.Online("survival"); len(online) != 0 {
+		t.Fatalf("expected nobody online after stop, got %v", online)
+	}
+
+	sessions, err := tr.Sessions(SessionQuery{Player: "8667ba71-b85a-4004-af54-457a9734eed7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session for Steve, got %d", len(sessions))
+	}
+	steve := sessions[0]
+	if steve.Name != "Steve" || steve.IP != "10.0.0.5" || steve.LeftAt-steve.JoinedAt != 60 || steve.Reason != "Disconnected" {
+		t.Errorf("unexpected session %+v", steve)
+	}
+
+	// Who was on at 50s in?
+	during, err := tr.Sessions(SessionQuery{From: at(50).Unix(), To: at(50).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(during) != 2 {
+		t.Errorf("expected 2 sessions overlapping, got %d", len(during))
+	}
+
+	totals, err := tr.Playtime("survival")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 2 || totals[0].Name != "Alex" || totals[0].Seconds != 90 || totals[1].Seconds != 60 {
+		t.Errorf("unexpected playtime %+v", totals)
+	}
+
+	deaths, err := tr.Events(EventQuery{InstanceID: "survival", Type: EventDeath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deaths) != 1 || deaths[0].UUID != steve.UUID {
+		t.Errorf("unexpected deaths %+v", deaths)
+	}
+}
+
+func TestTrackerResumesOpenSessions(t *testing.T) {
+	tr := newTestTracker(t)
+	for _, s := range []models.PlayerSession{
+		{InstanceID: "survival", Name: "Steve", JoinedAt: 100},
+		{InstanceID: "survival", Name: "Alex", JoinedAt: 100},
+		{InstanceID: "creative", Name: "Bob", JoinedAt: 100},
+	} {
+		tr.db.Create(&s)
+	}
+
+	// Creative was not reattached to, so its sessions ended with the panel.
+	m := manager.NewManager()
+	defer m.Close()
+	tr.Watch("creative", m)
+	var bob models.PlayerSession
+	tr.db.First(&bob, "name = ?", "Bob")
+	if bob.LeftAt == 0 || bob.Reason != "panel restarted" {
+		t.Errorf("session of a stopped instance left open: %+v", bob)
+	}
+
+	// Survival still runs, so its sessions go on.
+	tr.resume("survival")
+	if online := tr.Online("survival"); len(online) != 2 {
+		t.Fatalf("expected 2 resumed sessions, got %v", online)
+	}
+	tr.handleMessage("survival", "Steve lost connection: Disconnected", time.Unix(200, 0))
+	tr.Reconcile("survival", []string{"Carl"}, time.Unix(300, 0))
+
+	online := tr.Online("survival")
+	if len(online) != 1 || online[0].Name != "Carl" {
+		t.Errorf("expected only Carl online, got %v", online)
+	}
+	sessions, _ := tr.Sessions(SessionQuery{InstanceID: "survival"})
+	reasons := map[string]string{}
+	for _, s := range sessions {
+		reasons[s.Name] = s.Reason
+	}
+	if reasons["Steve"] != "Disconnected" || reasons["Alex"] != "left while the panel was down" {
+		t.Errorf("unexpected reasons %v", reasons)
+	}
+}
@@ -0,0 +1,82 @@
+package players
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrInvalidName    = errors.New("invalid player name")
+
+	nameRe = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+)
+
+type Profile struct {
+	UUID string `json:"uuid"`
+	Name string `json:"username"`
+}
+
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+// OfflineUUID is the UUID an offline-mode server gives name, a version 3
+// UUID of "OfflinePlayer:<name>".
+func OfflineUUID(name string) string {
+	hash := md5.Sum([]byte("OfflinePlayer:" + name))
+	hash[6] = (hash[6] & 0x0f) | 0x30
+	hash[8] = (hash[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:])
+}
+
+// LookupProfile asks the Mojang API for the UUID and correctly cased name
+// of an account.
+func LookupProfile(name string) (Profile, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("https://api.mojang.com/users/profiles/minecraft/" + url.PathEscape(name))
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to contact Mojang API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent {
+		return Profile{}, ErrPlayerNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return Profile{}, fmt.Errorf("mojang API error: %s", resp.Status)
+	}
+
+	var mojangResp struct {
+		Name string `json:"name"`
+		ID   string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&mojangResp); err != nil {
+		return Profile{}, fmt.Errorf("failed to parse Mojang response: %v", err)
+	}
+
+	return Profile{UUID: dashUUID(mojangResp.ID), Name: mojangResp.Name}, nil
+}
+
+// ResolveProfile returns the profile name has on a server in the given mode.
+func ResolveProfile(name string, onlineMode bool) (Profile, error) {
+	if !ValidName(name) {
+		return Profile{}, ErrInvalidName
+	}
+	if !onlineMode {
+		return Profile{UUID: OfflineUUID(name), Name: name}, nil
+	}
+	return LookupProfile(name)
+}
+
+func dashUUID(id string) string {
+	if len(id) != 32 {
+		return id
+	}
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:])
+}
@@ -0,0 +1,17 @@
+package players
+
+import "testing"
+
+func TestOfflineUUID(t *testing.T) {
+	if got, want := OfflineUUID("Notch"), "b50ad385-829d-3141-a216-7e7d7539ba7f"; got != want {
+		t.Errorf("OfflineUUID(Notch) = %s, want %s", got, want)
+	}
+}
+
+func TestResolveProfileRejectsInvalidNames(t *testing.T) {
+	for _, name := range []string{"", "has space", "way_too_long_for_minecraft", "evil\nop me"} {
+		if _, err := ResolveProfile(name, false); err != ErrInvalidName {
+			t.Errorf("ResolveProfile(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package properties reads and edits Java .properties files such as
+// server.properties, keeping comments and the order of keys intact.
+package properties
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// line is one logical line: a comment, a blank line or an entry. An entry
+// continued with a trailing backslash spans several physical lines in raw.
+type line struct {
+	raw   string
+	key   string
+	value string
+	entry bool
+}
+
+type File struct {
+	lines []line
+}
+
+func Parse(data []byte) *File {
+	f := &File{}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return f
+	}
+	physical := strings.Split(text, "\n")
+	for i := 0; i < len(physical); i++ {
+		raw := physical[i]
+		logical := strings.TrimLeft(raw, " \t\f")
+		if !isComment(logical) {
+			for continues(logical) && i+1 < len(physical) {
+				i++
+				raw += "\n" + physical[i]
+				logical = logical[:len(logical)-1] + strings.TrimLeft(physical[i], " \t\f")
+			}
+			logical = strings.TrimSuffix(logical, "\\")
+		}
+		f.lines = append(f.lines, parseLine(raw, logical))
+	}
+	return f
+}
+
+func isComment(trimmed string) bool {
+	return trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!'
+}
+
+// continues reports whether a line ends in an odd number of backslashes,
+// which joins it with the next one.
+func continues(s string) bool {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// parseLine parses a logical line, already joined and with leading
+// whitespace removed. raw is what it looked like in the file.
+func parseLine(raw, trimmed string) line {
+	if isComment(trimmed) {
+		return line{raw: raw}
+	}
+
+	sep := -1
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' {
+			sep = i
+			break
+		}
+	}
+
+	if sep < 0 {
+		return line{raw: raw, key: unescape(strings.TrimSpace(trimmed)), entry: true}
+	}
+	return line{
+		raw:   raw,
+		key:   unescape(strings.TrimRight(trimmed[:sep], " \t\f")),
+		value: unescape(strings.TrimLeft(trimmed[sep+1:], " \t\f")),
+		entry: true,
+	}
+}
+
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data), nil
+}
+
+// Read returns the entries of the file at path as a map.
+func Read(path string) (map[string]string, error) {
+	f, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Map(), nil
+}
+
+func (f *File) Map() map[string]string {
+	values := make(map[string]string)
+	for _, l := range f.lines {
+		if l.entry {
+			values[l.key] = l.value
+		}
+	}
+	return values
+}
+
+// Keys returns the keys in the order they appear in the file.
+func (f *File) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, l := range f.lines {
+		if l.entry && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Get returns the value of key. Like Java, the last occurrence wins.
+func (f *File) Get(key string) (string, bool) {
+	for i := len(f.lines) - 1; i >= 0; i-- {
+		if l := f.lines[i]; l.entry && l.key == key {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of key in place, or appends it if it isn't there.
+func (f *File) Set(key, value string) {
+	set := line{raw: escape(key, true) + "=" + escape(value, false), key: key, value: value, entry: true}
+	for i, l := range f.lines {
+		if l.entry && l.key == key {
+			// Drop any later duplicates so the new value is the one read.
+			f.Delete(key)
+			f.lines = append(f.lines[:i], append([]line{set}, f.lines[i:]...)...)
+			return
+		}
+	}
+	f.lines = append(f.lines, set)
+}
+
+// Delete removes key and reports whether it was there.
+func (f *File) Delete(key string) bool {
+	kept := f.lines[:0]
+	for _, l := range f.lines {
+		if !(l.entry && l.key == key) {
+			kept = append(kept, l)
+		}
+	}
+	found := len(kept) != len(f.lines)
+	f.lines = kept
+	return found
+}
+
+func (f *File) Bytes() []byte {
+	var sb strings.Builder
+	for _, l := range f.lines {
+		sb.WriteString(l.raw)
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
+
+func (f *File) Save(path string) error {
+	return os.WriteFile(path, f.Bytes(), 0644)
+}
+
+// Update sets values in the file at path, creating it if needed.
+func Update(path string, values map[string]string) error {
+	f, err := Load(path)
+	if os.IsNotExist(err) {
+		f = &File{}
+	} else if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f.Set(key, values[key])
+	}
+	return f.Save(path)
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+5 <= len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					sb.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			sb.WriteString(`\u`)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+func escape(s string, key bool) string {
+	var sb strings.Builder
+	for i, c := range s {
+		switch c {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if key {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(c)
+		case ' ':
+			if key || i == 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
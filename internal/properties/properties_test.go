@@ -0,0 +1,83 @@
+package properties
+
+import "testing"
+
+const sample = `#Minecraft server properties
+#Mon Jan 01 00:00:00 UTC 2024
+motd=A \
+    Minecraft Server
+max-players=20
+  level-name = my\ world
+key\:with\=seps=value
+pvp=true
+pvp=false
+`
+
+func TestParseKeepsFileOnRoundTrip(t *testing.T) {
+	f := Parse([]byte(sample))
+	if got := string(f.Bytes()); got != sample {
+		t.Fatalf("round trip changed the file:\n%s", got)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	f := Parse([]byte(sample))
+	tests := map[string]string{
+		"motd":          "A Minecraft Server",
+		"max-players":   "20",
+		"level-name":    "my world",
+		"key:with=seps": "value",
+		"pvp":           "false", // The last occurrence wins
+	}
+	for key, want := range tests {
+		if got, ok := f.Get(key); !ok || got != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+	if got := f.Map()["pvp"]; got != "false" {
+		t.Errorf("Map()[pvp] = %q, want false", got)
+	}
+	if keys := f.Keys(); len(keys) != 5 || keys[0] != "motd" || keys[4] != "pvp" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+func TestSetAndDelete(t *testing.T) {
+	f := Parse([]byte(sample))
+	f.Set("pvp", "true")
+	f.Set("motd", "Hello: world")
+	f.Set("difficulty", "hard")
+	if !f.Delete("max-players") || f.Delete("max-players") {
+		t.Fatal("expected max-players to be deleted once")
+	}
+
+	want := `#Minecraft server properties
+#Mon Jan 01 00:00:00 UTC 2024
+motd=Hello: world
+  level-name = my\ world
+key\:with\=seps=value
+pvp=true
+difficulty=hard
+`
+	if got := string(f.Bytes()); got != want {
+		t.Fatalf("unexpected file:\n%s", got)
+	}
+
+	again := Parse(f.Bytes())
+	if v, _ := again.Get("motd"); v != "Hello: world" {
+		t.Errorf("motd did not survive a round trip: %q", v)
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	f := Parse([]byte(`greeting=café\ttab`))
+	if v, _ := f.Get("greeting"); v != "café\ttab" {
+		t.Errorf("unexpected value %q", v)
+	}
+
+	f = &File{}
+	f.Set("odd key", " leading space")
+	if v, _ := Parse(f.Bytes()).Get("odd key"); v != " leading space" {
+		t.Errorf("value did not survive escaping: %q", v)
+	}
+}
@@ -0,0 +1,232 @@
+package properties
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value types of the keys in Schema.
+const (
+	TypeBoolean = "boolean"
+	TypeInteger = "integer"
+	TypeString  = "string"
+	TypeEnum    = "enum"
+)
+
+// Property describes a vanilla server.properties key.
+type Property struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	Default     string   `json:"default"`
+	Description string   `json:"description"`
+	Min         *int64   `json:"min,omitempty"`
+	Max         *int64   `json:"max,omitempty"`
+	Values      []string `json:"values,omitempty"` // Allowed values of an enum
+	Since       string   `json:"since,omitempty"`  // First release with the key
+	Removed     string   `json:"removed,omitempty"`
+}
+
+func boolean(key, def, since, removed, description string) Property {
+	return Property{Key: key, Type: TypeBoolean, Default: def, Since: since, Removed: removed, Description: description}
+}
+
+func integer(key, def string, min, max int64, since, description string) Property {
+	p := Property{Key: key, Type: TypeInteger, Default: def, Since: since, Description: description}
+	p.Min, p.Max = &min, &max
+	return p
+}
+
+func text(key, def, since, description string) Property {
+	return Property{Key: key, Type: TypeString, Default: def, Since: since, Description: description}
+}
+
+func enum(key, def string, values []string, since, description string) Property {
+	return Property{Key: key, Type: TypeEnum, Default: def, Values: values, Since: since, Description: description}
+}
+
+const maxInt = 2147483647
+
+// Schema lists the keys of the vanilla Java Edition server. Keys added by
+// mods or plugins aren't in it and are treated as free-form strings.
+var Schema = []Property{
+	boolean("accepts-transfers", "false", "1.20.5", "", "Accept players transferred from other servers"),
+	boolean("allow-flight", "false", "", "", "Allow flying in survival with mods"),
+	boolean("allow-nether", "true", "", "", "Allow players to travel to the Nether"),
+	boolean("broadcast-console-to-ops", "true", "", "", "Send console command output to online operators"),
+	boolean("broadcast-rcon-to-ops", "true", "", "", "Send RCON command output to online operators"),
+	text("bug-report-link", "", "1.21", "Link shown on the disconnect screen for reporting bugs"),
+	enum("difficulty", "easy", []string{"peaceful", "easy", "normal", "hard"}, "", "Difficulty of the world"),
+	boolean("enable-command-block", "false", "", "", "Allow command blocks to run"),
+	boolean("enable-jmx-monitoring", "false", "1.16", "", "Expose tick time metrics over JMX"),
+	boolean("enable-query", "false", "", "", "Answer GameSpy4 queries"),
+	boolean("enable-rcon", "false", "", "", "Accept remote console connections"),
+	boolean("enable-status", "true", "1.16", "", "Show the server as online in the server list"),
+	boolean("enforce-secure-profile", "true", "1.19", "", "Require players to have a Mojang-signed public key"),
+	boolean("enforce-whitelist", "false", "", "", "Kick players who aren't whitelisted when the whitelist is reloaded"),
+	integer("entity-broadcast-range-percentage", "100", 10, 1000, "1.16", "How far away entities are sent to clients, in percent"),
+	boolean("force-gamemode", "false", "", "", "Put players in the default game mode whenever they join"),
+	integer("function-permission-level", "2", 1, 4, "1.14.4", "Permission level of functions"),
+	enum("gamemode", "survival", []string{"survival", "creative", "adventure", "spectator"}, "", "Default game mode"),
+	boolean("generate-structures", "true", "", "", "Generate structures such as villages"),
+	text("generator-settings", "{}", "", "Settings for customized world generation"),
+	boolean("hardcore", "false", "", "", "Ban players when they die"),
+	boolean("hide-online-players", "false", "1.18", "", "Hide the player list from status requests"),
+	text("initial-disabled-packs", "", "1.19.3", "Datapacks not enabled when the world is created"),
+	text("initial-enabled-packs", "vanilla", "1.19.3", "Datapacks enabled when the world is created"),
+	text("level-name", "world", "", "Name of the world folder"),
+	text("level-seed", "", "", "Seed of the world, random if empty"),
+	text("level-type", "minecraft:normal", "", "World preset used to generate the world"),
+	boolean("log-ips", "true", "1.20.2", "", "Log the addresses of connecting players"),
+	integer("max-chained-neighbor-updates", "1000000", -1, maxInt, "1.19", "Limit on consecutive neighbor updates"),
+	integer("max-players", "20", 0, maxInt, "", "Maximum number of players online at once"),
+	integer("max-tick-time", "60000", -1, 9223372036854775807, "", "Milliseconds a tick may take before the watchdog stops the server, -1 disables"),
+	integer("max-world-size", "29999984", 1, 29999984, "", "Radius of the world border in blocks"),
+	text("motd", "A Minecraft Server", "", "Message shown in the server list"),
+	integer("network-compression-threshold", "256", -1, maxInt, "", "Packet size in bytes above which packets are compressed, -1 disables"),
+	boolean("online-mode", "true", "", "", "Verify players with Mojang"),
+	integer("op-permission-level", "4", 0, 4, "", "Default permission level of operators"),
+	integer("pause-when-empty-seconds", "60", 0, maxInt, "1.21.2", "Seconds without players before the server pauses, 0 disables"),
+	integer("player-idle-timeout", "0", 0, maxInt, "", "Minutes before idle players are kicked, 0 disables"),
+	boolean("prevent-proxy-connections", "false", "", "", "Kick players whose address differs from the one Mojang saw"),
+	boolean("previews-chat", "false", "1.19", "1.19.3", "Enable chat previews"),
+	boolean("pvp", "true", "", "", "Allow players to damage each other"),
+	integer("query.port", "25565", 1, 65535, "", "Port of the query listener"),
+	integer("rate-limit", "0", 0, maxInt, "", "Packets a client may send per second before being kicked, 0 disables"),
+	text("rcon.password", "", "", "Password of the remote console"),
+	integer("rcon.port", "25575", 1, 65535, "", "Port of the remote console"),
+	enum("region-file-compression", "deflate", []string{"deflate", "lz4", "none"}, "1.20.5", "Compression of region files"),
+	boolean("require-resource-pack", "false", "", "", "Kick players who decline the resource pack"),
+	text("resource-pack", "", "", "URL of the server resource pack"),
+	text("resource-pack-id", "", "1.20.3", "UUID of the server resource pack"),
+	text("resource-pack-prompt", "", "1.17", "Message shown when asking for the resource pack"),
+	text("resource-pack-sha1", "", "", "SHA-1 of the resource pack"),
+	text("server-ip", "", "", "Address to listen on, all addresses if empty"),
+	integer("server-port", "25565", 1, 65535, "", "Port to listen on"),
+	integer("simulation-distance", "10", 3, 32, "1.18", "Distance in chunks around players where entities are ticked"),
+	boolean("spawn-animals", "true", "", "1.21.2", "Spawn animals"),
+	boolean("spawn-monsters", "true", "", "", "Spawn monsters"),
+	boolean("spawn-npcs", "true", "", "1.21.2", "Spawn villagers"),
+	integer("spawn-protection", "16", 0, maxInt, "", "Radius around spawn that only operators can build in"),
+	boolean("sync-chunk-writes", "true", "1.16", "", "Write chunks to disk synchronously"),
+	text("text-filtering-config", "", "1.17", "Configuration of the chat text filter"),
+	boolean("use-native-transport", "true", "", "", "Use optimized networking on Linux"),
+	integer("view-distance", "10", 3, 32, "", "Distance in chunks sent to clients"),
+	boolean("white-list", "false", "", "", "Only let whitelisted players join"),
+}
+
+var schemaIndex = func() map[string]*Property {
+	index := make(map[string]*Property, len(Schema))
+	for i := range Schema {
+		index[Schema[i].Key] = &Schema[i]
+	}
+	return index
+}()
+
+// Lookup returns the schema of key, or nil for keys it doesn't know.
+func Lookup(key string) *Property {
+	return schemaIndex[key]
+}
+
+// AvailableIn reports whether the key exists in a Minecraft version. Versions
+// that don't look like a release, such as snapshots, have every key.
+func (p *Property) AvailableIn(version string) bool {
+	if p.Since != "" && compareVersions(version, p.Since) < 0 {
+		return false
+	}
+	if p.Removed != "" && compareVersions(version, p.Removed) >= 0 {
+		return false
+	}
+	return true
+}
+
+// Validate checks value against the schema of key for a server version.
+// Unknown keys accept any single-line value.
+func Validate(key, value, version string) error {
+	if key == "" || strings.ContainsAny(key, "\n\r") {
+		return fmt.Errorf("invalid key")
+	}
+	if strings.ContainsAny(value, "\n\r") {
+		return fmt.Errorf("value must be on one line")
+	}
+
+	p := Lookup(key)
+	if p == nil {
+		return nil
+	}
+	if !p.AvailableIn(version) {
+		return fmt.Errorf("not available in %s", version)
+	}
+
+	switch p.Type {
+	case TypeBoolean:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("must be true or false")
+		}
+	case TypeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		if p.Min != nil && n < *p.Min {
+			return fmt.Errorf("must be at least %d", *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return fmt.Errorf("must be at most %d", *p.Max)
+		}
+	case TypeEnum:
+		for i, allowed := range p.Values {
+			// Older servers write the numeric id instead of the name.
+			if value == allowed || value == strconv.Itoa(i) {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(p.Values, ", "))
+	}
+	return nil
+}
+
+// compareVersions compares release versions such as 1.20.4. It returns 0 when
+// either of them isn't one, so that unknown versions don't hide keys.
+func compareVersions(a, b string) int {
+	pa, ok := parseVersion(a)
+	if !ok {
+		return 0
+	}
+	pb, ok := parseVersion(b)
+	if !ok {
+		return 0
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(v string) ([]int, bool) {
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
@@ -0,0 +1,52 @@
+package properties
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		key, value, version string
+		ok                  bool
+	}{
+		{"max-players", "50", "1.20.4", true},
+		{"max-players", "abc", "1.20.4", false},
+		{"max-players", "-1", "1.20.4", false},
+		{"server-port", "70000", "1.20.4", false},
+		{"pvp", "yes", "1.20.4", false},
+		{"pvp", "false", "1.20.4", true},
+		{"difficulty", "hard", "1.20.4", true},
+		{"difficulty", "2", "1.12.2", true},
+		{"difficulty", "nightmare", "1.20.4", false},
+		{"simulation-distance", "10", "1.17.1", false},
+		{"simulation-distance", "10", "1.18", true},
+		{"simulation-distance", "10", "24w14a", true},
+		{"spawn-animals", "true", "1.21.4", false},
+		{"spawn-animals", "true", "1.21.1", true},
+		{"motd", "two\nlines", "1.20.4", false},
+		{"some-plugin-key", "anything", "1.20.4", true},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.key, tt.value, tt.version)
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%q, %q, %q) = %v, want ok=%v", tt.key, tt.value, tt.version, err, tt.ok)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.20.4", "1.20.4", 0},
+		{"1.20", "1.20.0", 0},
+		{"1.9", "1.10", -1},
+		{"1.21.2", "1.21.1", 1},
+		{"imported", "1.18", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
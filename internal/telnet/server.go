@@ -128,3 +128,8 @@ func (c *TelnetClient) WriteMessage(messageType int, data []byte) error {
 	_, err := c.conn.Write(append(data, '\r', '\n'))
 	return err
 }
+
+// Close lets the console fan-out drop a client that cannot keep up.
+func (c *TelnetClient) Close() error {
+	return c.conn.Close()
+}
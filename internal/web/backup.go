@@ -2,6 +2,7 @@ package web
 
 import (
 	"jjmc/internal/auth"
+	"jjmc/internal/backup"
 	"jjmc/internal/instances"
 	"net/url"
 
@@ -22,20 +23,147 @@ func RegisterBackupRoutes(router fiber.Router, authManager *auth.AuthManager, im
 
 	g.Post("/", func(c *fiber.Ctx) error {
 		id := c.Params("id")
-		if err := im.CreateBackup(id); err != nil {
+		var opts instances.BackupOptions
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&opts); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+			}
+		}
+		run, err := im.RunBackup(id, opts, instances.BackupManual)
+		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
-		return c.JSON(fiber.Map{"status": "success"})
+		return c.JSON(fiber.Map{"status": "success", "run": run})
+	})
+
+	g.Get("/runs", func(c *fiber.Ctx) error {
+		runs, err := im.BackupRuns(c.Params("id"), c.QueryInt("limit", 50))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(runs)
+	})
+
+	g.Get("/retention", func(c *fiber.Ctx) error {
+		retention, err := im.BackupRetention(c.Params("id"))
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(retention)
+	})
+
+	g.Put("/retention", func(c *fiber.Ctx) error {
+		var retention backup.Retention
+		if err := c.BodyParser(&retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+		}
+		if err := im.UpdateBackupRetention(c.Params("id"), retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(retention)
+	})
+
+	// With ?dryRun=true nothing is deleted and the backups that would be
+	// are returned.
+	g.Post("/prune", func(c *fiber.Ctx) error {
+		dryRun := c.QueryBool("dryRun")
+		backups, err := im.PruneBackups(c.Params("id"), dryRun)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"dryRun": dryRun, "pruned": backups})
+	})
+
+	g.Get("/excludes", func(c *fiber.Ctx) error {
+		patterns, isDefault, err := im.BackupExcludes(c.Params("id"))
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"patterns": patterns, "default": isDefault})
+	})
+
+	// Gitignore-style patterns. Without patterns, the defaults apply again.
+	g.Put("/excludes", func(c *fiber.Ctx) error {
+		var payload struct {
+			Patterns []string `json:"patterns"`
+		}
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+		}
+		if err := im.UpdateBackupExcludes(c.Params("id"), payload.Patterns); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		patterns, isDefault, _ := im.BackupExcludes(c.Params("id"))
+		return c.JSON(fiber.Map{"patterns": patterns, "default": isDefault})
 	})
 
+	g.Put("/mode", func(c *fiber.Ctx) error {
+		var payload struct {
+			Mode string `json:"mode"`
+		}
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+		}
+		if err := im.UpdateBackupMode(c.Params("id"), payload.Mode); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"mode": payload.Mode})
+	})
+
+	// Removes the snapshot chunks no backup uses anymore.
+	g.Post("/gc", func(c *fiber.Ctx) error {
+		result, err := im.GCBackups(c.Params("id"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(result)
+	})
+
+	g.Get("/:filename/files", func(c *fiber.Ctx) error {
+		filename, err := url.QueryUnescape(c.Params("filename"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid filename encoding"})
+		}
+		entries, err := im.BrowseBackup(c.Params("id"), filename, c.Query("path"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(entries)
+	})
+
+	g.Get("/:filename/diff", func(c *fiber.Ctx) error {
+		filename, err := url.QueryUnescape(c.Params("filename"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid filename encoding"})
+		}
+		against := c.Query("against")
+		if against == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "against is required"})
+		}
+		diff, err := im.DiffBackups(c.Params("id"), filename, against)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(diff)
+	})
+
+	// Snapshots can be restored in part by giving paths in the body.
 	g.Post("/:filename/restore", func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		filename, err := url.QueryUnescape(c.Params("filename"))
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "Invalid filename encoding"})
 		}
+		var payload struct {
+			Paths []string `json:"paths"`
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&payload); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+			}
+		}
 
-		if err := im.RestoreBackup(id, filename); err != nil {
+		if err := im.RestoreBackupPaths(id, filename, payload.Paths); err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 		}
 		return c.JSON(fiber.Map{"status": "success"})
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+
+	"jjmc/internal/instances"
+	"jjmc/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GroupHandler manages the player lists shared across a group of instances.
+type GroupHandler struct {
+	Manager *instances.InstanceManager
+}
+
+func NewGroupHandler(im *instances.InstanceManager) *GroupHandler {
+	return &GroupHandler{Manager: im}
+}
+
+func groupParam(c *fiber.Ctx) string {
+	group, err := url.PathUnescape(c.Params("group"))
+	if err != nil {
+		return c.Params("group")
+	}
+	return group
+}
+
+func sharedListError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, instances.ErrUnknownList):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, instances.ErrListNotShared):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return accessError(c, err)
+}
+
+func (h *GroupHandler) ListSharedLists(c *fiber.Ctx) error {
+	lists, err := h.Manager.SharedLists(groupParam(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(lists)
+}
+
+// ShareList starts syncing a list across the group. Body: list (whitelist,
+// ops, bans or ip-bans).
+func (h *GroupHandler) ShareList(c *fiber.Ctx) error {
+	var payload struct {
+		List string `json:"list"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	shared, err := h.Manager.ShareList(groupParam(c), payload.List)
+	if err != nil {
+		return sharedListError(c, err)
+	}
+	return c.JSON(shared)
+}
+
+func (h *GroupHandler) UnshareList(c *fiber.Ctx) error {
+	if err := h.Manager.UnshareList(groupParam(c), c.Params("list")); err != nil {
+		return sharedListError(c, err)
+	}
+	return c.SendStatus(200)
+}
+
+func (h *GroupHandler) GetEntries(c *fiber.Ctx) error {
+	entries, err := h.Manager.SharedListEntries(groupParam(c), c.Params("list"))
+	if err != nil {
+		return sharedListError(c, err)
+	}
+	return c.JSON(entries)
+}
+
+// AddEntry adds to a shared list and applies it to every member. Body: name
+// (or ip for ip-bans), level and bypassesPlayerLimit for ops, reason and
+// expires (unix seconds, 0 for permanent) for bans.
+func (h *GroupHandler) AddEntry(c *fiber.Ctx) error {
+	var entry models.SharedListEntry
+	if err := c.BodyParser(&entry); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	entry.List = c.Params("list")
+	entry.Source = instances.SourceAPI
+
+	results, err := h.Manager.AddSharedEntry(groupParam(c), entry, "")
+	if err != nil {
+		return sharedListError(c, err)
+	}
+	return c.JSON(fiber.Map{"members": results})
+}
+
+func (h *GroupHandler) RemoveEntry(c *fiber.Ctx) error {
+	results, err := h.Manager.RemoveSharedEntry(groupParam(c), c.Params("list"), c.Params("key"), instances.SourceAPI, "")
+	if err != nil {
+		return sharedListError(c, err)
+	}
+	return c.JSON(fiber.Map{"members": results})
+}
+
+// GetChanges returns the audit trail of a shared list. Query: limit.
+func (h *GroupHandler) GetChanges(c *fiber.Ctx) error {
+	changes, err := h.Manager.SharedListChanges(groupParam(c), c.Params("list"), c.QueryInt("limit", 200))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(changes)
+}
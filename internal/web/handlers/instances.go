@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"jjmc/internal/instances"
+	"jjmc/internal/services/scheduler"
 )
 
 type InstanceHandler struct {
-	Manager *instances.InstanceManager
+	Manager   *instances.InstanceManager
+	Scheduler *scheduler.Scheduler
 }
 
-func NewInstanceHandler(im *instances.InstanceManager) *InstanceHandler {
-	return &InstanceHandler{Manager: im}
+func NewInstanceHandler(im *instances.InstanceManager, s *scheduler.Scheduler) *InstanceHandler {
+	return &InstanceHandler{Manager: im, Scheduler: s}
 }
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"jjmc/internal/instances"
+	"jjmc/internal/players"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func accessError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, players.ErrInvalidName), errors.Is(err, instances.ErrInvalidIP), errors.Is(err, instances.ErrInvalidOpLevel):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, players.ErrPlayerNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, instances.ErrNeedsOffline):
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+}
+
+// accessResponse returns the result of a list change, or the error.
+func accessResponse(c *fiber.Ctx, result instances.AccessResult, err error) error {
+	if err != nil {
+		return accessError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func expiresAt(unix int64) time.Time {
+	if unix <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
+
+func (h *InstanceHandler) GetWhitelist(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	list, err := inst.GetWhitelist()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func (h *InstanceHandler) AddToWhitelist(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	result, err := inst.AddToWhitelist(payload.Name)
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) RemoveFromWhitelist(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	result, err := inst.RemoveFromWhitelist(c.Params("name"))
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) GetOps(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	list, err := inst.GetOps()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func (h *InstanceHandler) AddOp(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	var payload struct {
+		Name                string `json:"name"`
+		Level               int    `json:"level"`
+		BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	result, err := inst.AddOp(payload.Name, payload.Level, payload.BypassesPlayerLimit)
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) RemoveOp(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	result, err := inst.RemoveOp(c.Params("name"))
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) GetBans(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	list, err := inst.GetBans()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// BanPlayer bans a player. Body: name, reason, expires (unix seconds, 0 for
+// a permanent ban).
+func (h *InstanceHandler) BanPlayer(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	var payload struct {
+		Name    string `json:"name"`
+		Reason  string `json:"reason"`
+		Expires int64  `json:"expires"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	result, err := inst.BanPlayer(payload.Name, payload.Reason, expiresAt(payload.Expires))
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) PardonPlayer(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	result, err := inst.PardonPlayer(c.Params("name"))
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) GetIPBans(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	list, err := inst.GetIPBans()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// BanIP bans an address. Body: ip, reason, expires (unix seconds, 0 for a
+// permanent ban).
+func (h *InstanceHandler) BanIP(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	var payload struct {
+		IP      string `json:"ip"`
+		Reason  string `json:"reason"`
+		Expires int64  `json:"expires"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	result, err := inst.BanIP(payload.IP, payload.Reason, expiresAt(payload.Expires))
+	return accessResponse(c, result, err)
+}
+
+func (h *InstanceHandler) PardonIP(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	result, err := inst.PardonIP(c.Params("ip"))
+	return accessResponse(c, result, err)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"jjmc/internal/instances"
+	"jjmc/internal/services/scheduler"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BundleHandler moves instances between JJMC hosts as zip bundles.
+type BundleHandler struct {
+	Manager   *instances.InstanceManager
+	Scheduler *scheduler.Scheduler
+}
+
+func NewBundleHandler(im *instances.InstanceManager, s *scheduler.Scheduler) *BundleHandler {
+	return &BundleHandler{Manager: im, Scheduler: s}
+}
+
+// Export sends the instance as a bundle. It is built into a temporary file
+// first, so that a failure part way through is an error response rather
+// than a truncated download.
+func (h *BundleHandler) Export(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	tmp, err := os.CreateTemp("", "jjmc-export-*.zip")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	bundle := &tempFile{tmp}
+	if err := h.Manager.ExportBundle(inst.ID, tmp); err != nil {
+		bundle.Close()
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to export: %v", err)})
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		bundle.Close()
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.jjmc.zip"`, inst.ID))
+	// The response closes the stream once it is sent, which removes the file.
+	return c.SendStream(bundle, int(size))
+}
+
+// tempFile removes the file when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// Import recreates an instance from an uploaded bundle. Form fields: bundle,
+// and optionally id and name to use instead of the ones in the bundle.
+func (h *BundleHandler) Import(c *fiber.Ctx) error {
+	file, err := c.FormFile("bundle")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Bundle file required"})
+	}
+
+	tmp, err := os.CreateTemp("", "jjmc-bundle-*.zip")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := c.SaveFile(file, tmp.Name()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to save upload: %v", err)})
+	}
+
+	result, err := h.Manager.ImportBundle(tmp.Name(), c.FormValue("id"), c.FormValue("name"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	for _, schedule := range result.Schedules {
+		if err := h.Scheduler.AddJob(schedule); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Schedule %s was not started: %v", schedule.Name, err))
+		}
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+
+	"jjmc/internal/manager"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetConsole pages through the console scrollback.
+// Query: since/before (sequence numbers), grep (regexp), level (minimum), limit.
+func (h *InstanceHandler) GetConsole(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	query := manager.ScrollbackQuery{
+		Since:  uint64(c.QueryInt("since", 0)),
+		Before: uint64(c.QueryInt("before", 0)),
+		Level:  c.Query("level"),
+		Limit:  c.QueryInt("limit", 500),
+	}
+	if grep := c.Query("grep"); grep != "" {
+		re, err := regexp.Compile("(?i)" + grep)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid grep pattern: " + err.Error()})
+		}
+		query.Grep = re
+	}
+
+	return c.JSON(inst.Manager.QueryScrollback(query))
+}
+
+// ConsoleWebSocket streams console output. Clients that reconnect pass the
+// last sequence number they saw as ?since=N to resume without gaps or
+// duplicates; ?format=json sends LogLine objects (which carry seq) instead
+// of raw text.
+func (h *InstanceHandler) ConsoleWebSocket(c *websocket.Conn) {
+	id := c.Params("id")
+	inst, err := h.Manager.GetInstance(id)
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	opts := manager.SubscriberOptions{
+		Policy: manager.Disconnect,
+		Replay: true,
+	}
+	if since, err := strconv.ParseUint(c.Query("since"), 10, 64); err == nil {
+		opts.Since = since
+	}
+	if c.Query("format") == "json" {
+		opts.Format = manager.FormatJSON
+	}
+
+	inst.Manager.RegisterClientWithOptions(c, opts)
+	defer inst.Manager.UnregisterClient(c)
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
@@ -18,10 +18,11 @@ func (h *InstanceHandler) Stop(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
 	}
-	if err := inst.Manager.Stop(); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	phase, err := inst.Manager.Stop()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "phase": phase})
 	}
-	return c.JSON(fiber.Map{"status": "stopped"})
+	return c.JSON(fiber.Map{"status": "stopped", "phase": phase})
 }
 
 func (h *InstanceHandler) Restart(c *fiber.Ctx) error {
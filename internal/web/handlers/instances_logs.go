@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bufio"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *InstanceHandler) ListLogs(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	logs, err := inst.ListLogs()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(logs)
+}
+
+// ReadLog streams a log file as plain text, decompressed. With ?grep= only
+// matching lines are sent, so large archives never have to leave the host.
+func (h *InstanceHandler) ReadLog(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	var grep *regexp.Regexp
+	if pattern := c.Query("grep"); pattern != "" {
+		grep, err = regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid grep pattern: " + err.Error()})
+		}
+	}
+
+	reader, err := inst.OpenLog(c.Params("source"), c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer reader.Close()
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if grep != nil && !grep.Match(line) {
+				continue
+			}
+			w.Write(line)
+			w.WriteByte('\n')
+		}
+		w.Flush()
+	})
+	return nil
+}
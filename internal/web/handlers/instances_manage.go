@@ -1,6 +1,12 @@
 package handlers
 
 import (
+	"fmt"
+	"mime/multipart"
+	"os"
+
+	"jjmc/internal/instances"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -33,7 +39,14 @@ func (h *InstanceHandler) Create(c *fiber.Ctx) error {
 	return c.JSON(inst)
 }
 
+// Import creates an instance from an existing server. Either a JSON body with
+// id, name and sourcePath, a folder on this host, or a multipart form with id,
+// name and a zip of the server folder as file.
 func (h *InstanceHandler) Import(c *fiber.Ctx) error {
+	if file, err := c.FormFile("file"); err == nil {
+		return h.importArchive(c, file)
+	}
+
 	var payload struct {
 		ID         string `json:"id"`
 		Name       string `json:"name"`
@@ -49,6 +62,79 @@ func (h *InstanceHandler) Import(c *fiber.Ctx) error {
 	return c.JSON(inst)
 }
 
+func (h *InstanceHandler) importArchive(c *fiber.Ctx, file *multipart.FileHeader) error {
+	tmp, err := os.CreateTemp("", "jjmc-import-*.zip")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := c.SaveFile(file, tmp.Name()); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to save upload: %v", err)})
+	}
+
+	inst, err := h.Manager.ImportArchive(c.FormValue("id"), c.FormValue("name"), tmp.Name())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(inst)
+}
+
+// Clone copies an instance. Body: id, name, includeWorlds and includeMods,
+// which both default to true.
+func (h *InstanceHandler) Clone(c *fiber.Ctx) error {
+	var payload struct {
+		ID            string `json:"id"`
+		Name          string `json:"name"`
+		IncludeWorlds *bool  `json:"includeWorlds"`
+		IncludeMods   *bool  `json:"includeMods"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+	}
+	opts := instances.CloneOptions{
+		ID:            payload.ID,
+		Name:          payload.Name,
+		IncludeWorlds: payload.IncludeWorlds == nil || *payload.IncludeWorlds,
+		IncludeMods:   payload.IncludeMods == nil || *payload.IncludeMods,
+	}
+	inst, err := h.Manager.CloneInstance(c.Params("id"), opts)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(inst)
+}
+
+func (h *InstanceHandler) Rename(c *fiber.Ctx) error {
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+	}
+	if err := h.Manager.RenameInstance(c.Params("id"), payload.Name); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": "renamed"})
+}
+
+// ChangeID moves an instance to a new ID. Body: id.
+func (h *InstanceHandler) ChangeID(c *fiber.Ctx) error {
+	var payload struct {
+		ID string `json:"id"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
+	}
+	inst, err := h.Manager.ChangeID(c.Params("id"), payload.ID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	// Scheduled jobs still carry the old ID.
+	h.Scheduler.LoadSchedules()
+	return c.JSON(inst)
+}
+
 func (h *InstanceHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if err := h.Manager.DeleteInstance(id); err != nil {
@@ -67,6 +153,19 @@ func (h *InstanceHandler) UpdateSettings(c *fiber.Ctx) error {
 		WebhookURL string `json:"webhookUrl"`
 		Group      string `json:"group"`
 		FolderID   string `json:"folderId"`
+
+		StopCommand *string `json:"stopCommand"`
+		StopTimeout *int    `json:"stopTimeout"`
+
+		ScrollbackLines int  `json:"scrollbackLines"`
+		LogMaxSizeMB    *int `json:"logMaxSizeMb"`
+		LogMaxAgeHours  *int `json:"logMaxAgeHours"`
+		LogRetention    *int `json:"logRetention"`
+
+		RestartPolicy     *string `json:"restartPolicy"`
+		RestartMaxRetries *int    `json:"restartMaxRetries"`
+		RestartWindow     *int    `json:"restartWindow"`
+		RestartBackoff    *int    `json:"restartBackoff"`
 	}
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
@@ -75,5 +174,38 @@ func (h *InstanceHandler) UpdateSettings(c *fiber.Ctx) error {
 	if err := h.Manager.UpdateSettings(id, payload.MaxMemory, payload.JavaArgs, payload.JarFile, payload.JavaPath, payload.WebhookURL, payload.Group, payload.FolderID); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+
+	// Older clients don't send the console, stop or restart settings; leave them untouched.
+	if payload.ScrollbackLines > 0 {
+		if err := h.Manager.UpdateConsoleSettings(id, payload.ScrollbackLines); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if payload.LogMaxSizeMB != nil || payload.LogMaxAgeHours != nil || payload.LogRetention != nil {
+		inst, err := h.Manager.GetInstance(id)
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+		}
+		maxSize, maxAge, retention := inst.LogMaxSizeMB, inst.LogMaxAgeHours, inst.LogRetention
+		if payload.LogMaxSizeMB != nil {
+			maxSize = *payload.LogMaxSizeMB
+		}
+		if payload.LogMaxAgeHours != nil {
+			maxAge = *payload.LogMaxAgeHours
+		}
+		if payload.LogRetention != nil {
+			retention = *payload.LogRetention
+		}
+		if err := h.Manager.UpdateLogRotation(id, maxSize, maxAge, retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if err := h.Manager.UpdateStopSettings(id, payload.StopCommand, payload.StopTimeout); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Manager.UpdateRestartPolicy(id, payload.RestartPolicy, payload.RestartMaxRetries, payload.RestartWindow, payload.RestartBackoff); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	return c.JSON(fiber.Map{"status": "updated"})
 }
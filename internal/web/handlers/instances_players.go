@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"jjmc/internal/players"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPlayers returns who is online now, recent sessions and total playtime
+// per player. Query: from/to (unix seconds) narrow the history to sessions
+// overlapping that window, player filters by name or UUID, limit.
+func (h *InstanceHandler) GetPlayers(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.Manager.GetInstance(id); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	history, err := h.Manager.Players.Sessions(players.SessionQuery{
+		InstanceID: id,
+		Player:     c.Query("player"),
+		From:       int64(c.QueryInt("from", 0)),
+		To:         int64(c.QueryInt("to", 0)),
+		Limit:      c.QueryInt("limit", 100),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	playtime, err := h.Manager.Players.Playtime(id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"online":   h.Manager.Players.Online(id),
+		"history":  history,
+		"playtime": playtime,
+	})
+}
+
+// GetPlayerEvents lists joins, leaves, deaths, chat and advancements.
+// Query: type, player, from/to (unix seconds), limit.
+func (h *InstanceHandler) GetPlayerEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.Manager.GetInstance(id); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	events, err := h.Manager.Players.Events(players.EventQuery{
+		InstanceID: id,
+		Player:     c.Query("player"),
+		Type:       c.Query("type"),
+		From:       int64(c.QueryInt("from", 0)),
+		To:         int64(c.QueryInt("to", 0)),
+		Limit:      c.QueryInt("limit", 200),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(events)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+
+	"jjmc/internal/instances"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *InstanceHandler) GetPorts(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	ports, err := h.Manager.Ports(inst.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(ports)
+}
+
+// ReservePort sets aside an extra port. Body: name, protocol (tcp or udp)
+// and port, or 0 to pick a free one from the range.
+func (h *InstanceHandler) ReservePort(c *fiber.Ctx) error {
+	var payload struct {
+		Name     string `json:"name"`
+		Protocol string `json:"protocol"`
+		Port     int    `json:"port"`
+	}
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	allocation, err := h.Manager.ReservePort(c.Params("id"), payload.Name, payload.Protocol, payload.Port)
+	if err != nil {
+		if errors.Is(err, instances.ErrPortTaken) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(allocation)
+}
+
+func (h *InstanceHandler) ReleasePort(c *fiber.Ctx) error {
+	if err := h.Manager.ReleasePort(c.Params("id"), c.Params("name")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(200)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"jjmc/internal/instances"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *InstanceHandler) GetProperties(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+	values, err := inst.GetProperties()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"version": inst.Version, "properties": values})
+}
+
+// UpdateProperties takes an object of keys to new values. Strings, numbers
+// and booleans are accepted; null removes the key. Nothing is written unless
+// every value is valid.
+func (h *InstanceHandler) UpdateProperties(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	changes := make(map[string]*string, len(payload))
+	for key, raw := range payload {
+		var value string
+		switch v := raw.(type) {
+		case nil:
+			changes[key] = nil
+			continue
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		case json.Number:
+			value = v.String()
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "Value of " + key + " must be a string, number, boolean or null"})
+		}
+		changes[key] = &value
+	}
+
+	if err := inst.UpdateProperties(changes); err != nil {
+		var invalid instances.PropertyErrors
+		if errors.As(err, &invalid) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "fields": invalid})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	values, err := inst.GetProperties()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"properties":      values,
+		"restartRequired": inst.Manager.GetState().IsActive(),
+	})
+}
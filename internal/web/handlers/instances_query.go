@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+
+	"jjmc/internal/instances"
+	"jjmc/internal/manager"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetQuery returns the full query stat: every online player, plugins, map
+// and game type.
+func (h *InstanceHandler) GetQuery(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	stat, err := inst.Query()
+	if err != nil {
+		if errors.Is(err, manager.ErrNotRunning) || errors.Is(err, instances.ErrQueryDisabled) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(502).JSON(fiber.Map{"error": "Query failed: " + err.Error()})
+	}
+
+	return c.JSON(stat)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+func (h *InstanceHandler) StateWebSocket(c *websocket.Conn) {
+	id := c.Params("id")
+	inst, err := h.Manager.GetInstance(id)
+	if err != nil {
+		log.Printf("State WS: Instance not found: %s", id)
+		c.Close()
+		return
+	}
+
+	inst.Manager.RegisterStateClient(c)
+	defer inst.Manager.UnregisterStateClient(c)
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetStatus pings the server and returns its MOTD, version, players and
+// latency.
+func (h *InstanceHandler) GetStatus(c *fiber.Ctx) error {
+	inst, err := h.Manager.GetInstance(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Instance not found"})
+	}
+
+	return c.JSON(inst.Probe())
+}
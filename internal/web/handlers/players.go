@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"jjmc/internal/players"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PlayerHandler struct {
+	Tracker *players.Tracker
+}
+
+func NewPlayerHandler(tracker *players.Tracker) *PlayerHandler {
+	return &PlayerHandler{Tracker: tracker}
+}
+
+// Search finds sessions across all instances. Query: q (name or UUID),
+// from/to (unix seconds) for sessions overlapping a window, instance, limit.
+func (h *PlayerHandler) Search(c *fiber.Ctx) error {
+	sessions, err := h.Tracker.Sessions(players.SessionQuery{
+		InstanceID: c.Query("instance"),
+		Player:     c.Query("q"),
+		From:       int64(c.QueryInt("from", 0)),
+		To:         int64(c.QueryInt("to", 0)),
+		Limit:      c.QueryInt("limit", 100),
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(sessions)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"jjmc/internal/instances"
 	"jjmc/internal/models"
 	"jjmc/internal/services/scheduler"
 
@@ -16,6 +17,15 @@ func NewScheduleHandler(s *scheduler.Scheduler) *ScheduleHandler {
 	return &ScheduleHandler{Scheduler: s}
 }
 
+// validateSchedule checks the payload of task types that take options.
+func validateSchedule(schedule models.Schedule) error {
+	if schedule.Type == "backup" {
+		_, err := instances.ParseBackupOptions(schedule.Payload)
+		return err
+	}
+	return nil
+}
+
 func (h *ScheduleHandler) List(c *fiber.Ctx) error {
 	instanceID := c.Params("id")
 	var schedules []models.Schedule
@@ -32,6 +42,10 @@ func (h *ScheduleHandler) Create(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
 	}
 
+	if err := validateSchedule(schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	schedule.ID = uuid.New().String()
 	schedule.InstanceID = instanceID
 	schedule.Enabled = true
@@ -56,6 +70,10 @@ func (h *ScheduleHandler) Update(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload"})
 	}
 
+	if err := validateSchedule(payload); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	var schedule models.Schedule
 	if err := h.Scheduler.DB.First(&schedule, "id = ?", scheduleID).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
@@ -1,14 +1,15 @@
 package handlers
 
 import (
-	"crypto/md5"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 
+	"jjmc/internal/players"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -109,50 +110,15 @@ func (h *SystemHandler) GetUUID(c *fiber.Ctx) error {
 	}
 
 	if offline {
-
-		data := []byte("OfflinePlayer:" + name)
-		hash := md5.Sum(data)
-		hash[6] = (hash[6] & 0x0f) | 0x30
-		hash[8] = (hash[8] & 0x3f) | 0x80
-
-		uuid := fmt.Sprintf("%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:])
-
-		return c.JSON(fiber.Map{
-			"uuid":     uuid,
-			"username": name,
-		})
-	}
-
-	resp, err := http.Get(fmt.Sprintf("https://api.mojang.com/users/profiles/minecraft/%s", name))
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to contact Mojang API"})
+		return c.JSON(players.Profile{UUID: players.OfflineUUID(name), Name: name})
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
+	profile, err := players.LookupProfile(name)
+	if errors.Is(err, players.ErrPlayerNotFound) {
 		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
-	} else if resp.StatusCode != 200 {
-		return c.Status(resp.StatusCode).JSON(fiber.Map{"error": "Mojang API error"})
-	}
-
-	var mojangResp struct {
-		Name string `json:"name"`
-		ID   string `json:"id"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&mojangResp); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse Mojang response"})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	uuidRaw := mojangResp.ID
-	var uuid string
-	if len(uuidRaw) == 32 {
-		uuid = fmt.Sprintf("%s-%s-%s-%s-%s", uuidRaw[0:8], uuidRaw[8:12], uuidRaw[12:16], uuidRaw[16:20], uuidRaw[20:])
-	} else {
-		uuid = uuidRaw
-	}
-
-	return c.JSON(fiber.Map{
-		"uuid":     uuid,
-		"username": mojangResp.Name,
-	})
+	return c.JSON(profile)
 }
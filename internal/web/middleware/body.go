@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit refuses request bodies larger than limit, except on the paths in
+// larger, which have a limit of their own. The server has to stream bodies
+// over its own limit instead of refusing them for those paths to work, so
+// this is what holds every other route to limit.
+func BodyLimit(limit int, larger map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		max := limit
+		if l, ok := larger[c.Path()]; ok {
+			max = l
+		}
+
+		req := c.Request()
+		n := req.Header.ContentLength()
+		if n > max {
+			return refuseBody(c, fiber.StatusRequestEntityTooLarge, "Request body too large")
+		}
+		if n == -1 && req.IsBodyStream() {
+			// Chunked bodies have no length up front, so small ones are read
+			// here and large ones have to say how long they are.
+			if max > limit {
+				return refuseBody(c, fiber.StatusLengthRequired, "Content-Length required")
+			}
+			body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(max)+1))
+			if err != nil {
+				return refuseBody(c, 400, err.Error())
+			}
+			if len(body) > max {
+				return refuseBody(c, fiber.StatusRequestEntityTooLarge, "Request body too large")
+			}
+			req.SetBody(body)
+		}
+		return c.Next()
+	}
+}
+
+// refuseBody answers without reading the rest of the body, which leaves the
+// connection unusable for further requests.
+func refuseBody(c *fiber.Ctx, status int, message string) error {
+	c.Context().SetConnectionClose()
+	return c.Status(status).JSON(fiber.Map{"error": message})
+}
@@ -17,6 +17,9 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 )
 
+// bundleLimit is the largest bundle that can be imported.
+const bundleLimit = 4 << 30
+
 func RegisterRoutes(app *fiber.App, authManager *auth.AuthManager, instanceManager *instances.InstanceManager, scheduler *scheduler.Scheduler, javaManager *java_manager.JavaManager) {
 
 	app.Use(cors.New())
@@ -26,6 +29,9 @@ func RegisterRoutes(app *fiber.App, authManager *auth.AuthManager, instanceManag
 		CrossOriginEmbedderPolicy: "unsafe-none",
 	}))
 	app.Use(middleware.AuthMiddleware(authManager))
+	app.Use(middleware.BodyLimit(fiber.DefaultBodyLimit, map[string]int{
+		"/api/instances/import-bundle": bundleLimit,
+	}))
 
 	// Rate Limiter for Login
 	loginLimiter := limiter.New(limiter.Config{
@@ -50,8 +56,9 @@ func RegisterRoutes(app *fiber.App, authManager *auth.AuthManager, instanceManag
 	app.Delete("/api/folders/:id", folderHandler.Delete)
 	app.Patch("/api/folders/:id", folderHandler.Rename)
 
-	instHandler := handlers.NewInstanceHandler(instanceManager)
+	instHandler := handlers.NewInstanceHandler(instanceManager, scheduler)
 	scheduleHandler := handlers.NewScheduleHandler(scheduler)
+	bundleHandler := handlers.NewBundleHandler(instanceManager, scheduler)
 
 	authGroup := app.Group("/api/auth")
 	authGroup.Get("/status", authHandler.GetStatus)
@@ -77,20 +84,66 @@ func RegisterRoutes(app *fiber.App, authManager *auth.AuthManager, instanceManag
 	javaGroup.Post("/install", javaHandler.Install)
 	javaGroup.Delete("/:name", javaHandler.Delete)
 
+	playerHandler := handlers.NewPlayerHandler(instanceManager.Players)
+	app.Get("/api/players", playerHandler.Search)
+
+	groupHandler := handlers.NewGroupHandler(instanceManager)
+	sharedLists := app.Group("/api/groups/:group/lists")
+	sharedLists.Get("/", groupHandler.ListSharedLists)
+	sharedLists.Post("/", groupHandler.ShareList)
+	sharedLists.Get("/:list", groupHandler.GetEntries)
+	sharedLists.Delete("/:list", groupHandler.UnshareList)
+	sharedLists.Post("/:list/entries", groupHandler.AddEntry)
+	sharedLists.Delete("/:list/entries/:key", groupHandler.RemoveEntry)
+	sharedLists.Get("/:list/changes", groupHandler.GetChanges)
+
 	instGroup := app.Group("/api/instances")
 	instGroup.Get("/", instHandler.List)
 	instGroup.Post("/", instHandler.Create)
 	instGroup.Post("/import", instHandler.Import)
+	instGroup.Post("/import-bundle", bundleHandler.Import)
 
 	inst := instGroup.Group("/:id")
 	inst.Get("/", instHandler.Get)
 	inst.Delete("/", instHandler.Delete)
 	inst.Patch("/", instHandler.UpdateSettings)
 	inst.Post("/type", instHandler.ChangeType)
+	inst.Post("/rename", instHandler.Rename)
+	inst.Post("/change-id", instHandler.ChangeID)
+	inst.Post("/clone", instHandler.Clone)
+	inst.Get("/export", bundleHandler.Export)
 	inst.Post("/start", instHandler.Start)
 	inst.Post("/stop", instHandler.Stop)
 	inst.Post("/restart", instHandler.Restart)
 	inst.Post("/command", instHandler.Command)
+	inst.Get("/console", instHandler.GetConsole)
+	inst.Get("/status", instHandler.GetStatus)
+	inst.Get("/query", instHandler.GetQuery)
+	inst.Get("/players", instHandler.GetPlayers)
+	inst.Get("/players/events", instHandler.GetPlayerEvents)
+
+	inst.Get("/properties", instHandler.GetProperties)
+	inst.Patch("/properties", instHandler.UpdateProperties)
+	inst.Get("/ports", instHandler.GetPorts)
+	inst.Post("/ports", instHandler.ReservePort)
+	inst.Delete("/ports/:name", instHandler.ReleasePort)
+
+	inst.Get("/whitelist", instHandler.GetWhitelist)
+	inst.Post("/whitelist", instHandler.AddToWhitelist)
+	inst.Delete("/whitelist/:name", instHandler.RemoveFromWhitelist)
+	inst.Get("/ops", instHandler.GetOps)
+	inst.Post("/ops", instHandler.AddOp)
+	inst.Delete("/ops/:name", instHandler.RemoveOp)
+	inst.Get("/bans", instHandler.GetBans)
+	inst.Post("/bans", instHandler.BanPlayer)
+	inst.Delete("/bans/:name", instHandler.PardonPlayer)
+	inst.Get("/ip-bans", instHandler.GetIPBans)
+	inst.Post("/ip-bans", instHandler.BanIP)
+	inst.Delete("/ip-bans/:ip", instHandler.PardonIP)
+
+	logs := inst.Group("/logs")
+	logs.Get("/", instHandler.ListLogs)
+	logs.Get("/:source/:name", instHandler.ReadLog)
 	inst.Post("/install", instHandler.Install)
 
 	// Schedules
@@ -132,25 +185,10 @@ func RegisterRoutes(app *fiber.App, authManager *auth.AuthManager, instanceManag
 		return fiber.ErrUpgradeRequired
 	})
 
-	app.Get("/ws/instances/:id/console", websocket.New(func(c *websocket.Conn) {
-		id := c.Params("id")
-		inst, err := instanceManager.GetInstance(id)
-		if err != nil {
-			c.Close()
-			return
-		}
-
-		inst.Manager.RegisterClient(c)
-		defer inst.Manager.UnregisterClient(c)
-
-		for {
-			if _, _, err := c.ReadMessage(); err != nil {
-				break
-			}
-		}
-	}))
+	app.Get("/ws/instances/:id/console", websocket.New(instHandler.ConsoleWebSocket))
 
 	app.Get("/ws/instances/:id/stats", websocket.New(instHandler.StatsWebSocket))
+	app.Get("/ws/instances/:id/state", websocket.New(instHandler.StateWebSocket))
 
 	RegisterBackupRoutes(app, authManager, instanceManager)
 	handlers.RegisterNetworkRoutes(app, instanceManager)
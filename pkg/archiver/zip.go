@@ -9,6 +9,13 @@ import (
 )
 
 func ZipDirectory(source, target string) error {
+	return ZipDirectoryFiltered(source, target, nil)
+}
+
+// ZipDirectoryFiltered is ZipDirectory that only archives the paths, relative
+// to source and slash-separated, for which include returns true. Directories
+// it leaves out are not descended into.
+func ZipDirectoryFiltered(source, target string, include func(rel string, info os.FileInfo) bool) error {
 	zipfile, err := os.Create(target)
 	if err != nil {
 		return err
@@ -20,7 +27,7 @@ func ZipDirectory(source, target string) error {
 
 	info, err := os.Stat(source)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	var baseDir string
@@ -28,10 +35,26 @@ func ZipDirectory(source, target string) error {
 		baseDir = filepath.Base(source)
 	}
 
-	filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if include != nil && path != source {
+			rel, err := filepath.Rel(source, path)
+			if err != nil {
+				return err
+			}
+			if !include(filepath.ToSlash(rel), info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		// Opening a FIFO such as console.in would block forever.
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
 
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
@@ -70,6 +93,17 @@ func ZipDirectory(source, target string) error {
 }
 
 func Unzip(source, destination string) error {
+	return unzip(source, destination, false)
+}
+
+// UnzipStripped is Unzip without the first folder of every entry, so that
+// an archive made by ZipDirectory is unpacked into destination itself
+// whatever the source directory was called.
+func UnzipStripped(source, destination string) error {
+	return unzip(source, destination, true)
+}
+
+func unzip(source, destination string, strip bool) error {
 	r, err := zip.OpenReader(source)
 	if err != nil {
 		return err
@@ -77,7 +111,16 @@ func Unzip(source, destination string) error {
 	defer r.Close()
 
 	for _, f := range r.File {
-		fpath := filepath.Join(destination, f.Name)
+		name := f.Name
+		if strip {
+			// Archives made on Windows can use either separator.
+			i := strings.IndexAny(name, `/\`)
+			if i < 0 || i == len(name)-1 {
+				continue
+			}
+			name = name[i+1:]
+		}
+		fpath := filepath.Join(destination, name)
 
 		if !strings.HasPrefix(fpath, filepath.Clean(destination)+string(os.PathSeparator)) {
 			continue
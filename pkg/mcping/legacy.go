@@ -0,0 +1,114 @@
+package mcping
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// legacyProtocol is the protocol number 1.6 clients send with the ping.
+const legacyProtocol = 74
+
+// PingLegacy sends the 1.6 server list ping, which pre-1.7 servers answer
+// and most modern ones still understand.
+func PingLegacy(addr string, timeout time.Duration) (*Status, error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var req bytes.Buffer
+	req.Write([]byte{0xFE, 0x01, 0xFA})
+	writeUTF16(&req, "MC|PingHost")
+	hostUTF16 := utf16.Encode([]rune(host))
+	binary.Write(&req, binary.BigEndian, uint16(7+2*len(hostUTF16)))
+	req.WriteByte(legacyProtocol)
+	writeUTF16(&req, host)
+	binary.Write(&req, binary.BigEndian, int32(port))
+
+	sent := time.Now()
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	kick, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if kick != 0xFF {
+		return nil, errInvalidResponse
+	}
+	body, err := readUTF16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := parseLegacyResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	status.Latency = time.Since(sent)
+	return status, nil
+}
+
+// parseLegacyResponse handles both the 1.4-1.6 layout
+// ("§1\x00protocol\x00version\x00motd\x00online\x00max") and the older
+// "motd§online§max".
+func parseLegacyResponse(body string) (*Status, error) {
+	status := &Status{Legacy: true}
+
+	if strings.HasPrefix(body, "§1\x00") {
+		fields := strings.Split(body, "\x00")
+		if len(fields) < 6 {
+			return nil, errInvalidResponse
+		}
+		status.Version.Protocol, _ = strconv.Atoi(fields[1])
+		status.Version.Name = fields[2]
+		status.MOTD = StripFormatting(fields[3])
+		status.Players.Online, _ = strconv.Atoi(fields[4])
+		status.Players.Max, _ = strconv.Atoi(fields[5])
+		return status, nil
+	}
+
+	fields := strings.Split(body, "§")
+	if len(fields) < 3 {
+		return nil, errInvalidResponse
+	}
+	n := len(fields)
+	status.MOTD = strings.Join(fields[:n-2], "§")
+	status.Players.Online, _ = strconv.Atoi(fields[n-2])
+	status.Players.Max, _ = strconv.Atoi(fields[n-1])
+	return status, nil
+}
+
+func writeUTF16(w *bytes.Buffer, s string) {
+	units := utf16.Encode([]rune(s))
+	binary.Write(w, binary.BigEndian, uint16(len(units)))
+	binary.Write(w, binary.BigEndian, units)
+}
+
+func readUTF16(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	units := make([]uint16, n)
+	if err := binary.Read(r, binary.BigEndian, units); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(units)), nil
+}
@@ -0,0 +1,270 @@
+// Package mcping implements the Minecraft Server List Ping: the status
+// exchange used by 1.7+ servers, with the 1.6 legacy ping as a fallback.
+package mcping
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Version struct {
+	Name     string `json:"name"`
+	Protocol int    `json:"protocol"`
+}
+
+type Player struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+type Players struct {
+	Max    int      `json:"max"`
+	Online int      `json:"online"`
+	Sample []Player `json:"sample,omitempty"`
+}
+
+type Status struct {
+	Version Version       `json:"version"`
+	Players Players       `json:"players"`
+	MOTD    string        `json:"motd"` // Description with formatting removed
+	Favicon string        `json:"favicon,omitempty"`
+	Latency time.Duration `json:"-"`
+	Legacy  bool          `json:"legacy"` // Answered the 1.6 ping only
+}
+
+var errInvalidResponse = errors.New("mcping: invalid response")
+
+// Ping asks the server at addr ("host:port") for its status, falling back
+// to the legacy ping for servers that don't speak the modern protocol.
+func Ping(addr string, timeout time.Duration) (*Status, error) {
+	status, err := PingModern(addr, timeout)
+	if err == nil {
+		return status, nil
+	}
+	if legacy, lerr := PingLegacy(addr, timeout); lerr == nil {
+		return legacy, nil
+	}
+	return nil, err
+}
+
+// PingModern runs the handshake, status request and ping/pong exchange of
+// the 1.7+ protocol.
+func PingModern(addr string, timeout time.Duration) (*Status, error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Handshake: protocol version -1 lets the server pick, next state 1 is status.
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	writeVarInt(&handshake, -1)
+	writeString(&handshake, host)
+	binary.Write(&handshake, binary.BigEndian, port)
+	writeVarInt(&handshake, 1)
+	if err := writePacket(conn, handshake.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writePacket(conn, []byte{0x00}); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	payload, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	pr := bytes.NewReader(payload)
+	if id, err := readVarInt(pr); err != nil || id != 0x00 {
+		return nil, errInvalidResponse
+	}
+	body, err := readString(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Version     Version         `json:"version"`
+		Players     Players         `json:"players"`
+		Description json.RawMessage `json:"description"`
+		Favicon     string          `json:"favicon"`
+	}
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return nil, fmt.Errorf("mcping: invalid status json: %v", err)
+	}
+	status := &Status{
+		Version: raw.Version,
+		Players: raw.Players,
+		MOTD:    StripFormatting(flattenChat(raw.Description)),
+		Favicon: raw.Favicon,
+	}
+
+	// Ping/pong for latency. Some proxies close the connection instead of
+	// answering, so a failure here still leaves a usable status.
+	var ping bytes.Buffer
+	writeVarInt(&ping, 0x01)
+	sent := time.Now()
+	binary.Write(&ping, binary.BigEndian, sent.UnixNano())
+	if err := writePacket(conn, ping.Bytes()); err == nil {
+		if pong, err := readPacket(r); err == nil && len(pong) > 0 && pong[0] == 0x01 {
+			status.Latency = time.Since(sent)
+		}
+	}
+
+	return status, nil
+}
+
+// flattenChat turns a chat component (a plain string, an object with
+// "text" and "extra", or an array of components) into plain text.
+func flattenChat(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		var sb strings.Builder
+		for _, part := range list {
+			sb.WriteString(flattenChat(part))
+		}
+		return sb.String()
+	}
+
+	var component struct {
+		Text  string            `json:"text"`
+		Extra []json.RawMessage `json:"extra"`
+	}
+	if err := json.Unmarshal(raw, &component); err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(component.Text)
+	for _, part := range component.Extra {
+		sb.WriteString(flattenChat(part))
+	}
+	return sb.String()
+}
+
+// StripFormatting removes legacy "§x" formatting codes.
+func StripFormatting(s string) string {
+	if !strings.ContainsRune(s, '§') {
+		return s
+	}
+	var sb strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '§' {
+			i++
+			continue
+		}
+		sb.WriteRune(runes[i])
+	}
+	return sb.String()
+}
+
+func splitHostPort(addr string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: %s", portStr)
+	}
+	return host, uint16(port), nil
+}
+
+func writeVarInt(w *bytes.Buffer, value int32) {
+	v := uint32(value)
+	for {
+		if v&^0x7F == 0 {
+			w.WriteByte(byte(v))
+			return
+		}
+		w.WriteByte(byte(v&0x7F | 0x80))
+		v >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var result uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(result), nil
+		}
+	}
+	return 0, errors.New("mcping: varint too long")
+}
+
+func writeString(w *bytes.Buffer, s string) {
+	writeVarInt(w, int32(len(s)))
+	w.WriteString(s)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || int(n) > r.Len() {
+		return "", errInvalidResponse
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func writePacket(w io.Writer, payload []byte) error {
+	var buf bytes.Buffer
+	writeVarInt(&buf, int32(len(payload)))
+	buf.Write(payload)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// maxPacketSize is generous for a status response, which can carry a
+// base64 favicon and a long player sample.
+const maxPacketSize = 2 * 1024 * 1024
+
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || n > maxPacketSize {
+		return nil, errInvalidResponse
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
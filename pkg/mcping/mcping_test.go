@@ -0,0 +1,168 @@
+package mcping
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts one connection per ping and hands it to handle.
+func fakeServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// modernServer answers the 1.7+ status exchange with statusJSON. If it
+// sees the legacy 0xFE ping instead it just closes the connection.
+func modernServer(statusJSON string) func(net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		if b, _ := r.Peek(1); len(b) == 1 && b[0] == 0xFE {
+			return
+		}
+		if _, err := readPacket(r); err != nil { // handshake
+			return
+		}
+		if _, err := readPacket(r); err != nil { // status request
+			return
+		}
+
+		var resp bytes.Buffer
+		writeVarInt(&resp, 0x00)
+		writeString(&resp, statusJSON)
+		writePacket(conn, resp.Bytes())
+
+		ping, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		writePacket(conn, ping)
+	}
+}
+
+func TestPingModern(t *testing.T) {
+	addr := fakeServer(t, modernServer(`{
+		"version": {"name": "Paper 1.21.1", "protocol": 767},
+		"players": {"max": 20, "online": 2, "sample": [{"name": "Steve", "id": "8667ba71-b85a-4004-af54-457a9734eed7"}]},
+		"description": {"text": "§aA ", "extra": [{"text": "Minecraft"}, " Server"]}
+	}`))
+
+	status, err := Ping(addr, time.Second)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if status.Legacy {
+		t.Error("expected a modern response")
+	}
+	if status.Version.Name != "Paper 1.21.1" || status.Version.Protocol != 767 {
+		t.Errorf("unexpected version %+v", status.Version)
+	}
+	if status.Players.Online != 2 || status.Players.Max != 20 {
+		t.Errorf("unexpected players %+v", status.Players)
+	}
+	if len(status.Players.Sample) != 1 || status.Players.Sample[0].Name != "Steve" {
+		t.Errorf("unexpected sample %+v", status.Players.Sample)
+	}
+	if status.MOTD != "A Minecraft Server" {
+		t.Errorf("unexpected motd %q", status.MOTD)
+	}
+	if status.Latency <= 0 {
+		t.Error("expected a latency from the ping/pong exchange")
+	}
+}
+
+func TestPingModernPlainDescription(t *testing.T) {
+	addr := fakeServer(t, modernServer(`{"version":{"name":"1.20.4","protocol":765},"players":{"max":10,"online":0},"description":"Hello"}`))
+
+	status, err := PingModern(addr, time.Second)
+	if err != nil {
+		t.Fatalf("PingModern failed: %v", err)
+	}
+	if status.MOTD != "Hello" {
+		t.Errorf("unexpected motd %q", status.MOTD)
+	}
+}
+
+// legacyServer answers only the 1.6 ping, as a pre-1.7 server would.
+func legacyServer(t *testing.T) func(net.Conn) {
+	return func(conn net.Conn) {
+		head := make([]byte, 3)
+		if _, err := io.ReadFull(conn, head); err != nil || head[0] != 0xFE {
+			return
+		}
+		channel, err := readUTF16(conn)
+		if err != nil || channel != "MC|PingHost" {
+			t.Errorf("unexpected plugin channel %q", channel)
+			return
+		}
+
+		var resp bytes.Buffer
+		resp.WriteByte(0xFF)
+		writeUTF16(&resp, "§1\x0078\x001.6.4\x00A §cLegacy§r Server\x003\x0016")
+		conn.Write(resp.Bytes())
+	}
+}
+
+func TestPingFallsBackToLegacy(t *testing.T) {
+	addr := fakeServer(t, legacyServer(t))
+
+	status, err := Ping(addr, time.Second)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if !status.Legacy {
+		t.Error("expected a legacy response")
+	}
+	if status.Version.Name != "1.6.4" || status.Version.Protocol != 78 {
+		t.Errorf("unexpected version %+v", status.Version)
+	}
+	if status.Players.Online != 3 || status.Players.Max != 16 {
+		t.Errorf("unexpected players %+v", status.Players)
+	}
+	if status.MOTD != "A Legacy Server" {
+		t.Errorf("unexpected motd %q", status.MOTD)
+	}
+}
+
+func TestParseLegacyBeta(t *testing.T) {
+	status, err := parseLegacyResponse("A Minecraft Server§4§20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.MOTD != "A Minecraft Server" || status.Players.Online != 4 || status.Players.Max != 20 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestVarIntRoundTrip(t *testing.T) {
+	for _, v := range []int32{0, 1, 127, 128, 25565, 2097151, -1} {
+		var buf bytes.Buffer
+		writeVarInt(&buf, v)
+		got, err := readVarInt(bufio.NewReader(&buf))
+		if err != nil || got != v {
+			t.Errorf("varint %d: got %d, %v", v, got, err)
+		}
+	}
+}
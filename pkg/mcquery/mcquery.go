@@ -0,0 +1,201 @@
+// Package mcquery is a client for the GameSpy4 based UDP Query protocol that
+// Minecraft servers answer when enable-query is set.
+package mcquery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	typeStat      = 0x00
+	typeHandshake = 0x09
+
+	// Full stat responses are a few KiB even with hundreds of players.
+	maxResponseSize = 64 * 1024
+)
+
+var (
+	magic = []byte{0xFE, 0xFD}
+
+	// Fixed padding around the key/value section and the player list.
+	kvHeader     = []byte("splitnum\x00\x80\x00")
+	playerHeader = []byte("\x01player_\x00\x00")
+
+	errInvalidResponse = errors.New("mcquery: invalid response")
+)
+
+// FullStat is the full stat response. Keys the server sends beyond the
+// ones given their own field are kept in Raw.
+type FullStat struct {
+	MOTD       string            `json:"motd"`
+	GameType   string            `json:"gameType"`
+	GameID     string            `json:"gameId"`
+	Version    string            `json:"version"`
+	Plugins    string            `json:"plugins"`  // e.g. "Paper on Bukkit 1.21: A 1.0; B 2.0"
+	Software   string            `json:"software"` // The part of Plugins before the colon
+	PluginList []string          `json:"pluginList"`
+	Map        string            `json:"map"`
+	NumPlayers int               `json:"numPlayers"`
+	MaxPlayers int               `json:"maxPlayers"`
+	HostPort   int               `json:"hostPort"`
+	HostIP     string            `json:"hostIp"`
+	Players    []string          `json:"players"`
+	Raw        map[string]string `json:"raw"`
+}
+
+// FullStatQuery performs the challenge handshake and requests the full stat
+// from the query port at addr.
+func FullStatQuery(addr string, timeout time.Duration) (*FullStat, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Only the lower four bits of each byte are used by the server.
+	session := rand.Int31() & 0x0F0F0F0F
+
+	token, err := handshake(conn, session)
+	if err != nil {
+		return nil, err
+	}
+
+	req := request(typeStat, session)
+	binary.Write(req, binary.BigEndian, token)
+	req.Write([]byte{0, 0, 0, 0}) // Padding asks for the full stat
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	body, err := readResponse(conn, typeStat, session)
+	if err != nil {
+		return nil, err
+	}
+	return parseFullStat(body)
+}
+
+func request(typ byte, session int32) *bytes.Buffer {
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(typ)
+	binary.Write(&buf, binary.BigEndian, session)
+	return &buf
+}
+
+func handshake(conn net.Conn, session int32) (int32, error) {
+	if _, err := conn.Write(request(typeHandshake, session).Bytes()); err != nil {
+		return 0, err
+	}
+	body, err := readResponse(conn, typeHandshake, session)
+	if err != nil {
+		return 0, err
+	}
+	token, err := strconv.ParseInt(string(bytes.TrimRight(body, "\x00")), 10, 32)
+	if err != nil {
+		return 0, errInvalidResponse
+	}
+	return int32(token), nil
+}
+
+// readResponse reads one datagram and strips the type and session header.
+func readResponse(conn net.Conn, typ byte, session int32) ([]byte, error) {
+	buf := make([]byte, maxResponseSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	buf = buf[:n]
+	if len(buf) < 5 || buf[0] != typ || int32(binary.BigEndian.Uint32(buf[1:5])) != session {
+		return nil, errInvalidResponse
+	}
+	return buf[5:], nil
+}
+
+func parseFullStat(body []byte) (*FullStat, error) {
+	if !bytes.HasPrefix(body, kvHeader) {
+		return nil, errInvalidResponse
+	}
+	body = body[len(kvHeader):]
+
+	raw := make(map[string]string)
+	for {
+		key, rest, ok := cutString(body)
+		if !ok {
+			return nil, errInvalidResponse
+		}
+		body = rest
+		if key == "" {
+			break
+		}
+		value, rest, ok := cutString(body)
+		if !ok {
+			return nil, errInvalidResponse
+		}
+		body = rest
+		raw[key] = value
+	}
+
+	if !bytes.HasPrefix(body, playerHeader) {
+		return nil, errInvalidResponse
+	}
+	body = body[len(playerHeader):]
+
+	players := []string{}
+	for {
+		name, rest, ok := cutString(body)
+		if !ok || name == "" {
+			break
+		}
+		players = append(players, name)
+		body = rest
+	}
+
+	stat := &FullStat{
+		MOTD:     raw["hostname"],
+		GameType: raw["gametype"],
+		GameID:   raw["game_id"],
+		Version:  raw["version"],
+		Plugins:  raw["plugins"],
+		Map:      raw["map"],
+		HostIP:   raw["hostip"],
+		Players:  players,
+		Raw:      raw,
+	}
+	stat.NumPlayers, _ = strconv.Atoi(raw["numplayers"])
+	stat.MaxPlayers, _ = strconv.Atoi(raw["maxplayers"])
+	stat.HostPort, _ = strconv.Atoi(raw["hostport"])
+	stat.Software, stat.PluginList = splitPlugins(stat.Plugins)
+	return stat, nil
+}
+
+// splitPlugins splits "Software: Plugin 1.0; Other 2.0" into its parts.
+// Vanilla sends an empty string.
+func splitPlugins(plugins string) (string, []string) {
+	list := []string{}
+	software, rest, found := strings.Cut(plugins, ":")
+	if !found {
+		return strings.TrimSpace(plugins), list
+	}
+	for _, plugin := range strings.Split(rest, ";") {
+		if plugin = strings.TrimSpace(plugin); plugin != "" {
+			list = append(list, plugin)
+		}
+	}
+	return strings.TrimSpace(software), list
+}
+
+func cutString(b []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return string(b[:i]), b[i+1:], true
+}
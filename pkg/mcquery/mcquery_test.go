@@ -0,0 +1,126 @@
+package mcquery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+const challenge = 9513307
+
+// fakeServer answers handshakes and full stat requests the way the vanilla
+// query listener does.
+func fakeServer(t *testing.T, players []string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if n < 7 || !bytes.Equal(req[:2], magic) {
+				continue
+			}
+			session := req[3:7]
+
+			var resp bytes.Buffer
+			switch req[2] {
+			case typeHandshake:
+				resp.WriteByte(typeHandshake)
+				resp.Write(session)
+				fmt.Fprintf(&resp, "%d\x00", challenge)
+			case typeStat:
+				if n != 15 || int32(binary.BigEndian.Uint32(req[7:11])) != challenge {
+					continue
+				}
+				resp.WriteByte(typeStat)
+				resp.Write(session)
+				resp.Write(kvHeader)
+				for _, kv := range [][2]string{
+					{"hostname", "A Minecraft Server"},
+					{"gametype", "SMP"},
+					{"game_id", "MINECRAFT"},
+					{"version", "1.21.1"},
+					{"plugins", "Paper on Bukkit 1.21.1-R0.1-SNAPSHOT: LuckPerms 5.4.0; EssentialsX 2.20.1"},
+					{"map", "world"},
+					{"numplayers", fmt.Sprint(len(players))},
+					{"maxplayers", "100"},
+					{"hostport", "25565"},
+					{"hostip", "127.0.0.1"},
+				} {
+					resp.WriteString(kv[0] + "\x00" + kv[1] + "\x00")
+				}
+				resp.WriteByte(0)
+				resp.Write(playerHeader)
+				for _, p := range players {
+					resp.WriteString(p + "\x00")
+				}
+				resp.WriteByte(0)
+			default:
+				continue
+			}
+			conn.WriteTo(resp.Bytes(), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestFullStatQuery(t *testing.T) {
+	players := make([]string, 30)
+	for i := range players {
+		players[i] = fmt.Sprintf("Player%02d", i)
+	}
+	addr := fakeServer(t, players)
+
+	stat, err := FullStatQuery(addr, time.Second)
+	if err != nil {
+		t.Fatalf("FullStatQuery failed: %v", err)
+	}
+
+	if stat.MOTD != "A Minecraft Server" || stat.Version != "1.21.1" || stat.Map != "world" {
+		t.Errorf("unexpected stat %+v", stat)
+	}
+	if stat.NumPlayers != 30 || stat.MaxPlayers != 100 || stat.HostPort != 25565 {
+		t.Errorf("unexpected counts %d/%d port %d", stat.NumPlayers, stat.MaxPlayers, stat.HostPort)
+	}
+	if len(stat.Players) != 30 || stat.Players[29] != "Player29" {
+		t.Errorf("expected all 30 players, got %v", stat.Players)
+	}
+	if stat.Software != "Paper on Bukkit 1.21.1-R0.1-SNAPSHOT" {
+		t.Errorf("unexpected software %q", stat.Software)
+	}
+	if len(stat.PluginList) != 2 || stat.PluginList[1] != "EssentialsX 2.20.1" {
+		t.Errorf("unexpected plugins %v", stat.PluginList)
+	}
+}
+
+func TestFullStatQueryEmptyServer(t *testing.T) {
+	addr := fakeServer(t, nil)
+
+	stat, err := FullStatQuery(addr, time.Second)
+	if err != nil {
+		t.Fatalf("FullStatQuery failed: %v", err)
+	}
+	if len(stat.Players) != 0 {
+		t.Errorf("expected no players, got %v", stat.Players)
+	}
+}
+
+func TestSplitPluginsVanilla(t *testing.T) {
+	software, list := splitPlugins("")
+	if software != "" || len(list) != 0 {
+		t.Errorf("expected nothing for vanilla, got %q %v", software, list)
+	}
+}
@@ -0,0 +1,205 @@
+// Package mcrcon is a client for the Minecraft flavour of the Source RCON
+// protocol.
+package mcrcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	typeResponse = 0
+	typeCommand  = 2
+	typeAuth     = 3
+
+	// Any type the server doesn't know makes it answer "Unknown request",
+	// which marks the end of a fragmented command response.
+	typeSentinel = 100
+
+	// Responses are split into packets with bodies of at most this size.
+	maxBodySize = 4096
+
+	// Vanilla reads requests into a 1460 byte buffer, of which 14 bytes are
+	// taken by the length, id, type and terminators.
+	MaxCommandLength = 1446
+
+	maxPacketSize = maxBodySize + 10
+)
+
+var (
+	ErrAuthFailed      = errors.New("rcon: authentication failed")
+	ErrCommandTooLong  = fmt.Errorf("rcon: command longer than %d bytes", MaxCommandLength)
+	errInvalidResponse = errors.New("rcon: invalid response")
+)
+
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+	mu      sync.Mutex
+	nextID  int32
+}
+
+type packet struct {
+	id   int32
+	typ  int32
+	body []byte
+}
+
+// Dial connects to addr and authenticates with password. The timeout
+// applies to connecting and to every command afterwards.
+func Dial(addr, password string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn, timeout: timeout}
+	if err := c.auth(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) auth(password string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	id := c.newID()
+	if err := c.write(packet{id: id, typ: typeAuth, body: []byte(password)}); err != nil {
+		return err
+	}
+
+	for {
+		p, err := c.read()
+		if err != nil {
+			return err
+		}
+		// Source servers send an empty response value ahead of the auth
+		// response; Minecraft doesn't, but skipping it costs nothing.
+		if p.typ == typeResponse {
+			continue
+		}
+		if p.id == -1 {
+			return ErrAuthFailed
+		}
+		if p.id != id {
+			return errInvalidResponse
+		}
+		return nil
+	}
+}
+
+// Command runs cmd on the server and returns its response. Responses the
+// server splits across several packets are joined back together.
+func (c *Client) Command(cmd string) (string, error) {
+	if len(cmd) > MaxCommandLength {
+		return "", ErrCommandTooLong
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	id := c.newID()
+	if err := c.write(packet{id: id, typ: typeCommand, body: []byte(cmd)}); err != nil {
+		return "", err
+	}
+
+	// Vanilla handles one packet per read and drops the connection when a
+	// read holds more, so the sentinel is only sent once the first response
+	// is in. A short first response can't have been split.
+	p, err := c.readResponse(id)
+	if err != nil {
+		return "", err
+	}
+	if len(p.body) < maxBodySize {
+		return string(p.body), nil
+	}
+
+	sentinel := c.newID()
+	if err := c.write(packet{id: sentinel, typ: typeSentinel}); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	out.Write(p.body)
+	for {
+		p, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		switch p.id {
+		case id:
+			out.Write(p.body)
+		case sentinel:
+			return out.String(), nil
+		case -1:
+			return "", ErrAuthFailed
+		default:
+			return "", errInvalidResponse
+		}
+	}
+}
+
+func (c *Client) readResponse(id int32) (packet, error) {
+	p, err := c.read()
+	if err != nil {
+		return packet{}, err
+	}
+	switch p.id {
+	case id:
+		return p, nil
+	case -1:
+		return packet{}, ErrAuthFailed
+	default:
+		return packet{}, errInvalidResponse
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) newID() int32 {
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+func (c *Client) write(p packet) error {
+	buf := make([]byte, 14+len(p.body))
+	binary.LittleEndian.PutUint32(buf[0:], uint32(10+len(p.body)))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(p.id))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(p.typ))
+	copy(buf[12:], p.body)
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func (c *Client) read() (packet, error) {
+	var size int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &size); err != nil {
+		return packet{}, err
+	}
+	if size < 10 || size > maxPacketSize {
+		return packet{}, errInvalidResponse
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return packet{}, err
+	}
+
+	return packet{
+		id:   int32(binary.LittleEndian.Uint32(payload[0:4])),
+		typ:  int32(binary.LittleEndian.Uint32(payload[4:8])),
+		body: bytes.TrimRight(payload[8:], "\x00"),
+	}, nil
+}
@@ -0,0 +1,158 @@
+package mcrcon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers like the vanilla RconClient: it handles one packet per
+// read and hangs up when a read holds more, responses are split into 4096
+// byte packets and unknown request types get "Unknown request".
+func fakeServer(t *testing.T, password string, handle func(cmd string) string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(conn, password, handle)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFake(conn net.Conn, password string, handle func(cmd string) string) {
+	defer conn.Close()
+	send := func(id, typ int32, body string) {
+		buf := make([]byte, 14+len(body))
+		binary.LittleEndian.PutUint32(buf[0:], uint32(10+len(body)))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(id))
+		binary.LittleEndian.PutUint32(buf[8:], uint32(typ))
+		copy(buf[12:], body)
+		conn.Write(buf)
+	}
+
+	authed := false
+	buf := make([]byte, 1460)
+	for {
+		// Give packets sent back to back the time to arrive in one read.
+		time.Sleep(10 * time.Millisecond)
+		n, err := conn.Read(buf)
+		if err != nil || n < 14 {
+			return
+		}
+		if size := int32(binary.LittleEndian.Uint32(buf[0:4])); int(size) != n-4 {
+			return
+		}
+		payload := buf[4:n]
+		id := int32(binary.LittleEndian.Uint32(payload[0:4]))
+		typ := int32(binary.LittleEndian.Uint32(payload[4:8]))
+		body := string(payload[8 : len(payload)-2])
+
+		switch {
+		case typ == typeAuth:
+			if body != password {
+				send(-1, typeCommand, "")
+				return
+			}
+			authed = true
+			send(id, typeCommand, "")
+		case typ == typeCommand && authed:
+			out := handle(body)
+			for len(out) > 4096 {
+				send(id, typeResponse, out[:4096])
+				out = out[4096:]
+			}
+			send(id, typeResponse, out)
+		case authed:
+			send(id, typeResponse, fmt.Sprintf("Unknown request %x", typ))
+		default:
+			send(-1, typeCommand, "")
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	addr := fakeServer(t, "secret", func(cmd string) string {
+		return "ran " + cmd
+	})
+
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+
+	for _, cmd := range []string{"list", "say hi"} {
+		out, err := c.Command(cmd)
+		if err != nil {
+			t.Fatalf("Command(%q) failed: %v", cmd, err)
+		}
+		if out != "ran "+cmd {
+			t.Errorf("Command(%q) = %q", cmd, out)
+		}
+	}
+}
+
+func TestCommandJoinsFragments(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	addr := fakeServer(t, "secret", func(cmd string) string { return long })
+
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+
+	out, err := c.Command("help")
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if out != long {
+		t.Errorf("expected %d bytes, got %d", len(long), len(out))
+	}
+}
+
+func TestCommandFullFragment(t *testing.T) {
+	// A response of exactly one full packet looks split, so it is only done
+	// when the sentinel comes back.
+	full := strings.Repeat("x", maxBodySize)
+	addr := fakeServer(t, "secret", func(cmd string) string { return full })
+
+	c, err := Dial(addr, "secret", time.Second)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		out, err := c.Command("help")
+		if err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		if out != full {
+			t.Errorf("expected %d bytes, got %d", len(full), len(out))
+		}
+	}
+}
+
+func TestDialWrongPassword(t *testing.T) {
+	addr := fakeServer(t, "secret", func(cmd string) string { return "" })
+
+	_, err := Dial(addr, "wrong", time.Second)
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrAuthFailed, got %v", err)
+	}
+}